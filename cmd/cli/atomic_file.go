@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ErrFileExists is returned when writing a file which already exists, and
+// replacing it wasn't asked for
+var ErrFileExists = errors.New("file already exists")
+
+// Writes data to path through a temporary file in the same directory, which
+// is synced and then renamed over path, so readers see the old or the new
+// contents and never a partial write. The file gets perm, even when it
+// replaces one with a looser mode. Unless replace is set an existing file is
+// left alone and ErrFileExists returned.
+func writeFileAtomic(path string, data []byte, perm fs.FileMode, replace bool) error {
+	return writeFileAtomicMode(path, data, perm, replace, false)
+}
+
+// Replaces path with data the same way as writeFileAtomic, but an existing
+// file keeps its mode and owner, for files others are meant to read. A new
+// one is created with perm.
+func replaceFileAtomicKeepingMode(path string, data []byte, perm fs.FileMode) error {
+	return writeFileAtomicMode(path, data, perm, true, true)
+}
+
+func writeFileAtomicMode(path string, data []byte, perm fs.FileMode, replace, keepMode bool) error {
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && !replace:
+		return fmt.Errorf("%w (%s)", ErrFileExists, path)
+	case err == nil && keepMode:
+		perm = info.Mode().Perm()
+	case err == nil:
+		// a replaced file is written like a new one, its owner isn't kept
+		info = nil
+	case !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("failed to check %s (%w)", path, err)
+	}
+
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+name+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file (%w)", err)
+	}
+	defer os.Remove(tmp.Name()) // fails once renamed, which is fine
+	defer tmp.Close()
+
+	if err := tmp.Chmod(perm); err != nil {
+		return fmt.Errorf("failed to set mode of temporary file (%w)", err)
+	}
+
+	if info != nil {
+		if err := copyOwner(tmp, info); err != nil {
+			return fmt.Errorf("failed to keep the owner of %s (%w)", path, err)
+		}
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		return fmt.Errorf("failed to write temporary file (%w)", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to sync temporary file (%w)", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file (%w)", err)
+	}
+
+	if replace {
+		err = os.Rename(tmp.Name(), path)
+	} else {
+		// unlike a rename, a link fails if path was created in the meantime
+		err = os.Link(tmp.Name(), path)
+		if err != nil && !errors.Is(err, fs.ErrExist) {
+			// filesystems such as FAT have no hard links
+			err = writeFileExclusive(path, data, perm)
+		}
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("%w (%s)", ErrFileExists, path)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to move temporary file to %s (%w)", path, err)
+	}
+
+	return syncDir(dir)
+}
+
+// Writes data to a new file at path, failing if it already exists, for when it
+// can't be linked into place. A partial write is removed.
+func writeFileExclusive(path string, data []byte, perm fs.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+
+	err = f.Chmod(perm)
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	return nil
+}
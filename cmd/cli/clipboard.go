@@ -0,0 +1,359 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/eljamo/libpass/v8/config/option"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+)
+
+// Constants for the clipboard flag keys
+const (
+	clipKey        string = "clip"
+	clipTimeoutKey string = "clip_timeout"
+)
+
+// How long a copied password stays on the clipboard by default
+const defaultClipTimeout time.Duration = 45 * time.Second
+
+// How long to wait for the terminal to answer an OSC 52 query
+const osc52QueryTimeout time.Duration = time.Second
+
+// OSC 52 sequence asking the terminal for the clipboard
+const osc52Query string = "\x1b]52;c;?\x07"
+
+// ErrClipboardUnreadable is returned when the clipboard can't be read back,
+// so it can't be checked before clearing
+var ErrClipboardUnreadable = errors.New("clipboard can't be read")
+
+// clipboard is a way of setting and reading the system clipboard
+type clipboard interface {
+	// Name of the mechanism, shown to the user
+	name() string
+	copy(text string) error
+	paste() (string, error)
+	// Empties the clipboard, without leaving an empty entry in a clipboard
+	// manager's history where the tool allows
+	clear() error
+}
+
+// osc52Clipboard sets the clipboard with the OSC 52 terminal escape sequence,
+// which the terminal emulator handles, so it works over SSH. Reading it back
+// needs a terminal which answers OSC 52 queries.
+type osc52Clipboard struct {
+	tty *os.File
+}
+
+func (c *osc52Clipboard) name() string {
+	return "OSC 52"
+}
+
+func (c *osc52Clipboard) copy(text string) error {
+	if _, err := io.WriteString(c.tty, osc52Sequence(text)); err != nil {
+		return fmt.Errorf("failed to write to terminal (%w)", err)
+	}
+
+	return nil
+}
+
+// OSC 52 has no way to clear the clipboard other than setting it empty
+func (c *osc52Clipboard) clear() error {
+	return c.copy("")
+}
+
+func (c *osc52Clipboard) Close() error {
+	return c.tty.Close()
+}
+
+// Asks the terminal for the clipboard, it answers on the terminal's input so
+// it's put in raw mode to read the answer without echoing it
+func (c *osc52Clipboard) paste() (string, error) {
+	fd := int(c.tty.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", fmt.Errorf("%w, failed to set terminal to raw mode (%v)", ErrClipboardUnreadable, err)
+	}
+	defer term.Restore(fd, state)
+
+	if _, err := io.WriteString(c.tty, osc52Query); err != nil {
+		return "", fmt.Errorf("failed to write to terminal (%w)", err)
+	}
+
+	if err := c.tty.SetReadDeadline(time.Now().Add(osc52QueryTimeout)); err != nil {
+		return "", fmt.Errorf("%w, failed to set terminal read deadline (%v)", ErrClipboardUnreadable, err)
+	}
+	defer c.tty.SetReadDeadline(time.Time{})
+
+	var reply []byte
+	buf := make([]byte, 256)
+	for {
+		n, err := c.tty.Read(buf)
+		reply = append(reply, buf[:n]...)
+		if text, ok := parseOSC52Reply(reply); ok {
+			return text, nil
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("%w, the terminal didn't answer an OSC 52 query", ErrClipboardUnreadable)
+		}
+	}
+}
+
+// Returns the OSC 52 sequence setting the clipboard to text
+func osc52Sequence(text string) string {
+	return "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\x07"
+}
+
+// Returns the clipboard text from a terminal's answer to an OSC 52 query,
+// terminated by BEL or ST, and whether a whole answer has been read
+func parseOSC52Reply(reply []byte) (string, bool) {
+	_, rest, ok := bytes.Cut(reply, []byte("\x1b]52;"))
+	if !ok {
+		return "", false
+	}
+
+	end := bytes.IndexAny(rest, "\x07\x1b")
+	if end == -1 || (rest[end] == '\x1b' && !bytes.HasPrefix(rest[end:], []byte("\x1b\\"))) {
+		return "", false
+	}
+
+	// the selection, usually c, comes before the data
+	_, data, _ := bytes.Cut(rest[:end], []byte(";"))
+	text, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return "", true
+	}
+
+	return string(text), true
+}
+
+// commandClipboard sets, reads and clears the clipboard with external
+// commands, such as wl-copy and wl-paste
+type commandClipboard struct {
+	copyCmd  []string
+	pasteCmd []string
+	clearCmd []string
+}
+
+func (c *commandClipboard) name() string {
+	return c.copyCmd[0]
+}
+
+func (c *commandClipboard) copy(text string) error {
+	return runClipboardCommand(c.copyCmd, text)
+}
+
+func (c *commandClipboard) clear() error {
+	return runClipboardCommand(c.clearCmd, "")
+}
+
+// Runs a command which sets the clipboard, with text on its stdin. Output
+// isn't captured, as wl-copy and xclip fork a process which keeps serving the
+// clipboard and would hold the pipe open
+func runClipboardCommand(args []string, text string) error {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed (%w)", args[0], err)
+	}
+
+	return nil
+}
+
+func (c *commandClipboard) paste() (string, error) {
+	out, err := exec.Command(c.pasteCmd[0], c.pasteCmd[1:]...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%w, %s failed (%v)", ErrClipboardUnreadable, c.pasteCmd[0], err)
+	}
+
+	return string(out), nil
+}
+
+// Returns the clipboard to copy with: wl-copy on Wayland or xclip on X11 in a
+// local session, as many terminals ignore OSC 52 and would leave nothing to
+// clear, otherwise OSC 52 when there's a terminal, so it works over SSH
+func findClipboard(
+	getenv func(string) string,
+	lookPath func(string) (string, error),
+	openTTY func() (*os.File, error),
+) (clipboard, error) {
+	if getenv("SSH_CONNECTION") == "" && getenv("SSH_TTY") == "" {
+		if c, err := findCommandClipboard(getenv, lookPath); err == nil {
+			return c, nil
+		}
+	}
+
+	if getenv("TERM") != "dumb" {
+		if tty, err := openTTY(); err == nil {
+			return &osc52Clipboard{tty}, nil
+		}
+	}
+
+	return findCommandClipboard(getenv, lookPath)
+}
+
+// Opens the terminal for OSC 52, non-blocking so reads honour the deadline set
+// while waiting for the answer to a query
+func openOSC52TTY() (*os.File, error) {
+	return os.OpenFile(ttyPath, os.O_RDWR|syscall.O_NONBLOCK, 0)
+}
+
+// Returns a clipboard using the commands for the current display server
+func findCommandClipboard(getenv func(string) string, lookPath func(string) (string, error)) (clipboard, error) {
+	if getenv("WAYLAND_DISPLAY") != "" {
+		if _, err := lookPath("wl-copy"); err == nil {
+			return &commandClipboard{
+				[]string{"wl-copy"},
+				[]string{"wl-paste", "--no-newline"},
+				[]string{"wl-copy", "--clear"},
+			}, nil
+		}
+	}
+
+	if getenv("DISPLAY") != "" {
+		if _, err := lookPath("xclip"); err == nil {
+			return &commandClipboard{
+				[]string{"xclip", "-selection", "clipboard"},
+				[]string{"xclip", "-selection", "clipboard", "-out"},
+				[]string{"xclip", "-selection", "clipboard", "-in", os.DevNull},
+			}, nil
+		}
+	}
+
+	return nil, errors.New("no clipboard found, run in a terminal supporting OSC 52, or install wl-copy or xclip")
+}
+
+// Clears the clipboard if it still holds text, leaving anything copied since
+// alone. Returns whether it was cleared.
+func clearClipboardIfUnchanged(c clipboard, text string) (bool, error) {
+	current, err := c.paste()
+	if err != nil {
+		return false, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(current), []byte(text)) != 1 {
+		return false, nil
+	}
+
+	if err := c.clear(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Copies pw to the clipboard, then unless the timeout is 0, waits for it to
+// pass, or for an interrupt, termination or hangup, and clears the clipboard if it still holds pw
+func clipPassword(cmd *cobra.Command, pw string) error {
+	timeout, err := cmd.Flags().GetDuration(clipTimeoutKey)
+	if err != nil {
+		return fmt.Errorf("failed to get %s flag: %w", clipTimeoutKey, err)
+	}
+
+	if timeout < 0 {
+		return fmt.Errorf("%s (%s) must be greater than or equal to 0", clipTimeoutKey, timeout)
+	}
+
+	c, err := findClipboard(os.Getenv, exec.LookPath, openOSC52TTY)
+	if err != nil {
+		return err
+	}
+	// the OSC 52 terminal stays open until the clipboard has been cleared
+	if closer, ok := c.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	if err := c.copy(pw); err != nil {
+		return fmt.Errorf("failed to copy to the clipboard: %w", err)
+	}
+
+	if timeout == 0 {
+		cmd.Printf("Copied the password to the clipboard with %s\n", c.name())
+		return nil
+	}
+
+	cmd.Printf("Copied the password to the clipboard with %s, clearing it in %s, interrupt to clear it now\n", c.name(), timeout)
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer stop()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(timeout):
+	}
+
+	cleared, err := clearClipboardIfUnchanged(c, pw)
+	switch {
+	case errors.Is(err, ErrClipboardUnreadable):
+		cmd.Printf("Left the clipboard alone as it can't be checked: %v\n", err)
+	case err != nil:
+		return fmt.Errorf("failed to clear the clipboard: %w", err)
+	case cleared:
+		cmd.Println("Cleared the clipboard")
+	default:
+		cmd.Println("Left the clipboard alone as something else has been copied since")
+	}
+
+	return nil
+}
+
+// Adds the clipboard flags to a flag set
+func addClipFlags(flags *pflag.FlagSet) {
+	flags.Bool(
+		clipKey,
+		false,
+		"copy the password to the clipboard instead of printing it, with wl-copy or xclip in a local session, otherwise OSC 52",
+	)
+	addClipTimeoutFlag(flags)
+}
+
+// Adds the clipboard timeout flag to a flag set
+func addClipTimeoutFlag(flags *pflag.FlagSet) {
+	flags.Duration(
+		clipTimeoutKey,
+		defaultClipTimeout,
+		"how long to wait before clearing the clipboard, if it still holds the password, 0 to never clear it",
+	)
+}
+
+// Returns whether the clip flag is set, rejecting it along with asking for
+// more than one password, as only one can be copied
+func getClipFlag(cmd *cobra.Command) (bool, error) {
+	clip, err := cmd.Flags().GetBool(clipKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to get %s flag: %w", clipKey, err)
+	}
+
+	if !clip || !isFlagSet(cmd, option.ConfigKeyNumPasswords) {
+		return clip, nil
+	}
+
+	n, err := cmd.Flags().GetInt(option.ConfigKeyNumPasswords)
+	if err != nil {
+		return false, fmt.Errorf("failed to get %s flag: %w", option.ConfigKeyNumPasswords, err)
+	}
+
+	if n > 1 {
+		return false, fmt.Errorf(
+			"--%s copies a single password, it can't be used with --%s %d",
+			clipKey,
+			option.ConfigKeyNumPasswords,
+			n,
+		)
+	}
+
+	return clip, nil
+}
@@ -0,0 +1,263 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestParseOSC52Reply(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		reply    string
+		want     string
+		wantDone bool
+	}{
+		{"bel", "\x1b]52;c;aHVudGVyMg==\x07", "hunter2", true},
+		{"st", "junk\x1b]52;c;aHVudGVyMg==\x1b\\", "hunter2", true},
+		{"empty", "\x1b]52;c;\x07", "", true},
+		{"partial", "\x1b]52;c;aHVud", "", false},
+		{"partial st", "\x1b]52;c;aHVudGVyMg==\x1b", "", false},
+		{"other reply", "\x1b[12;1R", "", false},
+	}
+
+	for _, tt := range tests {
+		got, done := parseOSC52Reply([]byte(tt.reply))
+		if got != tt.want || done != tt.wantDone {
+			t.Errorf("%s: parseOSC52Reply(%q) = %q, %t, want %q, %t", tt.name, tt.reply, got, done, tt.want, tt.wantDone)
+		}
+	}
+}
+
+func TestOSC52Sequence(t *testing.T) {
+	t.Parallel()
+
+	if got, want := osc52Sequence("hunter2"), "\x1b]52;c;aHVudGVyMg==\x07"; got != want {
+		t.Errorf("osc52Sequence() = %q, want %q", got, want)
+	}
+
+	if got, done := parseOSC52Reply([]byte(osc52Sequence("pa$$ word"))); got != "pa$$ word" || !done {
+		t.Errorf("parseOSC52Reply(osc52Sequence()) = %q, %t, want the text back", got, done)
+	}
+}
+
+func TestFindCommandClipboard(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		env       map[string]string
+		installed []string
+		want      string
+	}{
+		{"wayland", map[string]string{"WAYLAND_DISPLAY": "wayland-0", "DISPLAY": ":0"}, []string{"wl-copy", "xclip"}, "wl-copy"},
+		{"xwayland without wl-copy", map[string]string{"WAYLAND_DISPLAY": "wayland-0", "DISPLAY": ":0"}, []string{"xclip"}, "xclip"},
+		{"x11", map[string]string{"DISPLAY": ":0"}, []string{"wl-copy", "xclip"}, "xclip"},
+		{"no display", map[string]string{}, []string{"wl-copy", "xclip"}, ""},
+	}
+
+	for _, tt := range tests {
+		getenv := func(key string) string { return tt.env[key] }
+		lookPath := func(file string) (string, error) {
+			for _, f := range tt.installed {
+				if f == file {
+					return "/usr/bin/" + f, nil
+				}
+			}
+
+			return "", exec.ErrNotFound
+		}
+
+		c, err := findCommandClipboard(getenv, lookPath)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%s: findCommandClipboard() = %s, want an error", tt.name, c.name())
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: findCommandClipboard() error = %v", tt.name, err)
+			continue
+		}
+
+		if got := c.name(); got != tt.want {
+			t.Errorf("%s: findCommandClipboard() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFindClipboard(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		tty  bool
+		want string
+	}{
+		{"local wayland", map[string]string{"WAYLAND_DISPLAY": "wayland-0", "TERM": "xterm"}, true, "wl-copy"},
+		{"local x11", map[string]string{"DISPLAY": ":0", "TERM": "xterm"}, true, "xclip"},
+		{"local console", map[string]string{"TERM": "linux"}, true, "OSC 52"},
+		{"ssh", map[string]string{"SSH_CONNECTION": "10.0.0.2 50000 10.0.0.1 22", "TERM": "xterm"}, true, "OSC 52"},
+		{"ssh with x forwarding", map[string]string{"SSH_TTY": "/dev/pts/1", "DISPLAY": "localhost:10.0", "TERM": "xterm"}, true, "OSC 52"},
+		{"ssh with x forwarding and no terminal", map[string]string{"SSH_TTY": "/dev/pts/1", "DISPLAY": "localhost:10.0"}, false, "xclip"},
+		{"dumb terminal", map[string]string{"TERM": "dumb"}, true, ""},
+	}
+
+	lookPath := func(file string) (string, error) { return "/usr/bin/" + file, nil }
+
+	for _, tt := range tests {
+		getenv := func(key string) string { return tt.env[key] }
+		openTTY := func() (*os.File, error) {
+			if !tt.tty {
+				return nil, os.ErrNotExist
+			}
+
+			return os.CreateTemp(t.TempDir(), "tty")
+		}
+
+		c, err := findClipboard(getenv, lookPath, openTTY)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%s: findClipboard() = %s, want an error", tt.name, c.name())
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: findClipboard() error = %v", tt.name, err)
+			continue
+		}
+
+		if got := c.name(); got != tt.want {
+			t.Errorf("%s: findClipboard() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// fakeClipboard holds the clipboard in memory
+type fakeClipboard struct {
+	text     string
+	pasteErr error
+}
+
+func (c *fakeClipboard) name() string {
+	return "fake"
+}
+
+func (c *fakeClipboard) copy(text string) error {
+	c.text = text
+	return nil
+}
+
+func (c *fakeClipboard) paste() (string, error) {
+	return c.text, c.pasteErr
+}
+
+func (c *fakeClipboard) clear() error {
+	c.text = ""
+	return nil
+}
+
+func TestClearClipboardIfUnchanged(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		clipboard   *fakeClipboard
+		wantCleared bool
+		wantText    string
+		wantErr     error
+	}{
+		{"unchanged", &fakeClipboard{text: "hunter2"}, true, "", nil},
+		{"copied over", &fakeClipboard{text: "something else"}, false, "something else", nil},
+		{"unreadable", &fakeClipboard{text: "hunter2", pasteErr: ErrClipboardUnreadable}, false, "hunter2", ErrClipboardUnreadable},
+	}
+
+	for _, tt := range tests {
+		cleared, err := clearClipboardIfUnchanged(tt.clipboard, "hunter2")
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: clearClipboardIfUnchanged() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+
+		if cleared != tt.wantCleared {
+			t.Errorf("%s: clearClipboardIfUnchanged() = %t, want %t", tt.name, cleared, tt.wantCleared)
+		}
+
+		if tt.clipboard.text != tt.wantText {
+			t.Errorf("%s: clipboard = %q, want %q", tt.name, tt.clipboard.text, tt.wantText)
+		}
+	}
+}
+
+func TestCommandClipboard(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is required")
+	}
+
+	path := filepath.Join(t.TempDir(), "clipboard")
+	// clearing leaves a marker, to tell it from copying an empty string
+	c := &commandClipboard{
+		[]string{"sh", "-c", `cat > "$0"`, path},
+		[]string{"sh", "-c", `cat "$0"`, path},
+		[]string{"sh", "-c", `printf cleared > "$0"`, path},
+	}
+
+	if err := c.copy("hunter2"); err != nil {
+		t.Fatalf("copy() error = %v", err)
+	}
+
+	cleared, err := clearClipboardIfUnchanged(c, "hunter2")
+	if err != nil || !cleared {
+		t.Fatalf("clearClipboardIfUnchanged() = %t, %v, want true", cleared, err)
+	}
+
+	if got, err := c.paste(); err != nil || got != "cleared" {
+		t.Errorf("paste() = %q, %v, want the clipboard cleared by the clear command", got, err)
+	}
+}
+
+func TestGetClipFlag(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		args    []string
+		want    bool
+		wantErr bool
+	}{
+		{nil, false, false},
+		{[]string{"--clip"}, true, false},
+		{[]string{"--clip", "--num_passwords", "1"}, true, false},
+		{[]string{"--clip", "--num_passwords", "3"}, false, true},
+		{[]string{"--num_passwords", "3"}, false, false},
+	}
+
+	for _, tt := range tests {
+		cmd := &cobra.Command{}
+		addClipFlags(cmd.Flags())
+		addConfigFlags(cmd.Flags())
+		if err := cmd.ParseFlags(tt.args); err != nil {
+			t.Fatalf("ParseFlags(%q) error = %v", tt.args, err)
+		}
+
+		got, err := getClipFlag(cmd)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("getClipFlag(%q) error = %v, wantErr %t", tt.args, err, tt.wantErr)
+		}
+
+		if got != tt.want {
+			t.Errorf("getClipFlag(%q) = %t, want %t", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and compare password generator configs",
+	Long: `Inspect and compare password generator configs. A config can be given as the
+name of a built-in preset or the path of a custom config file`,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,203 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/eljamo/libpass/v8/config"
+	"github.com/eljamo/libpass/v8/service"
+	"github.com/spf13/cobra"
+)
+
+// Constant for the samples flag key
+const samplesKey string = "samples"
+
+// Default number of passwords generated to build each score distribution
+const defaultNumSamples int = 100
+
+var configDiffCmd = &cobra.Command{
+	Use:   "diff A B",
+	Short: "Show how two presets or custom config files differ",
+	Long: `Show how two presets or custom config files differ. Each one is resolved to
+the settings it produces, layered on its base preset the same way as when
+generating passwords, so only effective changes are reported. The entropy,
+typical length and a sampled score distribution of both are shown alongside`,
+	Args: cobra.ExactArgs(2),
+	RunE: runConfigDiffCmd,
+}
+
+// A single setting which differs between two configs
+type settingChange struct {
+	Key    string
+	Before string
+	After  string
+}
+
+// The statistics of a config which are compared side by side
+type configStats struct {
+	entropy entropyEstimate
+	scores  []int
+}
+
+func runConfigDiffCmd(cmd *cobra.Command, args []string) error {
+	numSamples, err := cmd.Flags().GetInt(samplesKey)
+	if err != nil {
+		return fmt.Errorf("failed to get samples flag: %w", err)
+	}
+
+	if numSamples < 1 {
+		return fmt.Errorf("%s (%d) must be greater than 0", samplesKey, numSamples)
+	}
+
+	before, err := resolveConfigSource(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", args[0], err)
+	}
+
+	after, err := resolveConfigSource(args[1])
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", args[1], err)
+	}
+
+	changes, err := diffSettings(before, after)
+	if err != nil {
+		return err
+	}
+
+	beforeStats, err := collectConfigStats(before, numSamples)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate %s: %w", args[0], err)
+	}
+
+	afterStats, err := collectConfigStats(after, numSamples)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate %s: %w", args[1], err)
+	}
+
+	return writeConfigDiff(cmd.OutOrStdout(), args[0], args[1], changes, beforeStats, afterStats, numSamples)
+}
+
+// diffSettings compares two configs key by key, in field order, and returns
+// the settings whose values differ. Values are rendered as JSON so strings,
+// numbers and alphabets are unambiguous.
+func diffSettings(before, after *config.Settings) ([]settingChange, error) {
+	bv := reflect.ValueOf(before).Elem()
+	av := reflect.ValueOf(after).Elem()
+	t := bv.Type()
+
+	var changes []settingChange
+	for i := range t.NumField() {
+		b, a := bv.Field(i).Interface(), av.Field(i).Interface()
+		if reflect.DeepEqual(b, a) {
+			continue
+		}
+
+		bj, err := marshalSetting(b)
+		if err != nil {
+			return nil, err
+		}
+
+		aj, err := marshalSetting(a)
+		if err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, settingChange{
+			Key:    t.Field(i).Tag.Get("key"),
+			Before: bj,
+			After:  aj,
+		})
+	}
+
+	return changes, nil
+}
+
+// Renders a setting value as JSON without escaping the HTML characters found
+// in alphabets
+func marshalSetting(v any) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", fmt.Errorf("failed to encode setting (%w)", err)
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// Estimates the entropy of a config and scores a sample of its passwords
+func collectConfigStats(cfg *config.Settings, numSamples int) (configStats, error) {
+	est, err := estimateEntropy(cfg)
+	if err != nil {
+		return configStats{}, err
+	}
+
+	pgs, err := service.NewPasswordGeneratorService(cfg)
+	if err != nil {
+		return configStats{}, fmt.Errorf("failed to create password generator service: %w", err)
+	}
+
+	pws, err := samplePasswords(pgs, numSamples)
+	if err != nil {
+		return configStats{}, err
+	}
+
+	return configStats{est, scoreDistribution(pws)}, nil
+}
+
+func writeConfigDiff(
+	w io.Writer,
+	beforeName, afterName string,
+	changes []settingChange,
+	before, after configStats,
+	numSamples int,
+) error {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+
+	if len(changes) == 0 {
+		fmt.Fprintln(tw, "No settings changed")
+	} else {
+		fmt.Fprintln(tw, "Changed settings:")
+		for _, c := range changes {
+			fmt.Fprintf(tw, "  %s\t%s\t->\t%s\n", c.Key, c.Before, c.After)
+		}
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintf(tw, "\t%s\t%s\n", beforeName, afterName)
+	fmt.Fprintf(tw, "Entropy (bits)\t%.1f\t%.1f\n", before.entropy.Bits, after.entropy.Bits)
+	fmt.Fprintf(tw, "Typical length\t%d\t%d\n", before.entropy.TypicalLength, after.entropy.TypicalLength)
+	fmt.Fprintf(tw, "Unthrottled scores (%d samples)\t\t\n", numSamples)
+	for score := range scoreLabels {
+		fmt.Fprintf(tw, "  %d/4, %s\t%d\t%d\n", score, scoreLabel(score), before.scores[score], after.scores[score])
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write config diff: %w", err)
+	}
+
+	// tabwriter pads the empty cells of the score header
+	for line := range strings.Lines(buf.String()) {
+		if _, err := io.WriteString(w, strings.TrimRight(line, " \n")+"\n"); err != nil {
+			return fmt.Errorf("failed to write config diff: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	configDiffCmd.Flags().Int(
+		samplesKey,
+		defaultNumSamples,
+		"number of passwords to generate from each config for the score distribution, valid values: 1+",
+	)
+
+	configCmd.AddCommand(configDiffCmd)
+}
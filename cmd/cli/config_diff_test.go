@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/eljamo/libpass/v8/config/option"
+)
+
+func TestResolveConfigSourcePreset(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := resolveConfigSource("xkcd")
+	if err != nil {
+		t.Fatalf("resolveConfigSource(\"xkcd\") error = %v", err)
+	}
+
+	// the XKCD preset uses a fixed dash separator and 4 words
+	if cfg.SeparatorCharacter != "-" || cfg.NumWords != 4 {
+		t.Errorf("resolveConfigSource(\"xkcd\") = %+v, want the XKCD preset", cfg)
+	}
+}
+
+func TestResolveConfigSourceMissingFile(t *testing.T) {
+	t.Parallel()
+
+	if _, err := resolveConfigSource("does/not/exist.json"); err == nil {
+		t.Error("resolveConfigSource() error = nil, want an error for a missing file")
+	}
+}
+
+func TestDiffSettings(t *testing.T) {
+	t.Parallel()
+
+	before, err := resolveConfigSource(option.PresetDefault)
+	if err != nil {
+		t.Fatalf("resolveConfigSource() error = %v", err)
+	}
+
+	after, err := resolveConfigSource(option.PresetDefault)
+	if err != nil {
+		t.Fatalf("resolveConfigSource() error = %v", err)
+	}
+
+	changes, err := diffSettings(before, after)
+	if err != nil {
+		t.Fatalf("diffSettings() error = %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("diffSettings() of identical configs = %v, want no changes", changes)
+	}
+
+	after.NumWords = 5
+	after.SymbolAlphabet = []string{"&"}
+
+	changes, err = diffSettings(before, after)
+	if err != nil {
+		t.Fatalf("diffSettings() error = %v", err)
+	}
+
+	want := []settingChange{
+		{Key: option.ConfigKeyNumWords, Before: "3", After: "5"},
+		{Key: option.ConfigKeySymbolAlphabet, Before: `["!","@","$","%","^","&","*","-","+","=",":","|","~","?","/",".",";"]`, After: `["&"]`},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("diffSettings() = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("diffSettings()[%d] = %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+}
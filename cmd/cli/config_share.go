@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var configShareCmd = &cobra.Command{
+	Use:   "share",
+	Short: "Print a settings code for the current config",
+	Long: `Print a settings code for the config built from the given preset, custom
+config and flags. Load it on another machine with --settings_code to
+reproduce the same config. The code carries settings only, never passwords`,
+	Args: cobra.NoArgs,
+	RunE: runConfigShareCmd,
+}
+
+func runConfigShareCmd(cmd *cobra.Command, args []string) error {
+	cfg, err := generateConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to generate config: %w", err)
+	}
+
+	code, err := encodeSettingsCode(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create settings code: %w", err)
+	}
+
+	cmd.Println(code)
+
+	return nil
+}
+
+func init() {
+	addConfigFlags(configShareCmd.Flags())
+
+	configCmd.AddCommand(configShareCmd)
+}
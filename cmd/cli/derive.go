@@ -0,0 +1,262 @@
+package cli
+
+import (
+	"bufio"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/eljamo/libpass/v8/asset"
+	"github.com/eljamo/libpass/v8/config"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/term"
+)
+
+// Constants for the derive flag keys
+const (
+	siteKey          string = "site"
+	loginKey         string = "login"
+	counterKey       string = "counter"
+	deriveVersionKey string = "derive_version"
+)
+
+// Version of the derivation, mixed into every derived key. It must be bumped
+// whenever a libpass upgrade, or a change here, would generate a different
+// password from the same inputs and settings, so the change is reported
+// instead of silently handing out a different password. The passwords of this
+// version are pinned by TestDerivePasswordKnownAnswers, and a digest of the
+// filtered word list is mixed in too, so a word list changed by an upgrade
+// never lines up a seed with a different word.
+const deriveVersion int = 1
+
+// Argon2id parameters, the second recommended option of RFC 9106
+const (
+	deriveArgon2Time    uint32 = 3
+	deriveArgon2Memory  uint32 = 64 * 1024
+	deriveArgon2Threads uint8  = 4
+	deriveKeyLen        int    = 32
+)
+
+var ErrEmptyMasterPassphrase = errors.New("master passphrase cannot be empty")
+
+var deriveCmd = &cobra.Command{
+	Use:   "derive",
+	Short: "Derive a site password from a master passphrase",
+	Long: `Derive a memorable password for a site from a master passphrase, without
+storing anything. The same passphrase, site, login, counter and settings always
+derive the same password, bump the counter to rotate it. The master passphrase
+is stretched with Argon2id and expanded with HKDF into the seed of a
+deterministic RNG which drives the usual password generator`,
+	Args: cobra.NoArgs,
+	RunE: runDeriveCmd,
+}
+
+// The inputs which, along with the master passphrase, identify a derived
+// password
+type deriveInput struct {
+	Site    string
+	Login   string
+	Counter int
+	Version int
+}
+
+func runDeriveCmd(cmd *cobra.Command, args []string) error {
+	in, err := getDeriveInput(cmd)
+	if err != nil {
+		return err
+	}
+
+	out, err := getPasswordOutput(cmd)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := generateConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to generate config: %w", err)
+	}
+	cfg.NumPasswords = 1
+
+	passphrase, err := readSecret(cmd, "Master passphrase: ")
+	if err != nil {
+		return fmt.Errorf("failed to read master passphrase: %w", err)
+	}
+
+	pw, err := derivePassword(passphrase, in, cfg)
+	if err != nil {
+		return err
+	}
+
+	return out.write(cmd, []string{pw})
+}
+
+// Returns the derive flags, rejecting a derive version other than the one this
+// build implements
+func getDeriveInput(cmd *cobra.Command) (deriveInput, error) {
+	site, err := cmd.Flags().GetString(siteKey)
+	if err != nil {
+		return deriveInput{}, fmt.Errorf("failed to get %s flag: %w", siteKey, err)
+	}
+
+	site = strings.ToLower(strings.TrimSpace(site))
+	if site == "" {
+		return deriveInput{}, fmt.Errorf("%s cannot be empty", siteKey)
+	}
+
+	login, err := cmd.Flags().GetString(loginKey)
+	if err != nil {
+		return deriveInput{}, fmt.Errorf("failed to get %s flag: %w", loginKey, err)
+	}
+
+	counter, err := cmd.Flags().GetInt(counterKey)
+	if err != nil {
+		return deriveInput{}, fmt.Errorf("failed to get %s flag: %w", counterKey, err)
+	}
+
+	if counter < 1 {
+		return deriveInput{}, fmt.Errorf("%s (%d) must be greater than 0", counterKey, counter)
+	}
+
+	version, err := cmd.Flags().GetInt(deriveVersionKey)
+	if err != nil {
+		return deriveInput{}, fmt.Errorf("failed to get %s flag: %w", deriveVersionKey, err)
+	}
+
+	if version != deriveVersion {
+		return deriveInput{}, fmt.Errorf(
+			"%s (%d) is not supported by this build, which derives version %d passwords",
+			deriveVersionKey,
+			version,
+			deriveVersion,
+		)
+	}
+
+	return deriveInput{site, login, counter, version}, nil
+}
+
+// derivePassword deterministically generates a password from the master
+// passphrase, the derive inputs and cfg
+func derivePassword(passphrase []byte, in deriveInput, cfg *config.Settings) (string, error) {
+	seed, err := deriveSeed(passphrase, in, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	pgs, err := newPasswordGeneratorService(cfg, newDeterministicRNGService(seed))
+	if err != nil {
+		return "", fmt.Errorf("failed to create password generator service: %w", err)
+	}
+
+	pws, err := pgs.Generate()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+
+	return pws[0], nil
+}
+
+// deriveSeed stretches the master passphrase with Argon2id, salted with the
+// version, site and login, then expands it with HKDF over the counter, every
+// setting and the filtered word list into a 32 byte RNG seed. Each field is
+// length prefixed so no two distinct inputs share an encoding.
+func deriveSeed(passphrase []byte, in deriveInput, cfg *config.Settings) ([32]byte, error) {
+	var seed [32]byte
+	if len(passphrase) == 0 {
+		return seed, ErrEmptyMasterPassphrase
+	}
+
+	label := fmt.Sprintf("mempass/derive/v%d", in.Version)
+
+	salt := sha256.Sum256(appendLengthPrefixed(nil, label, in.Site, in.Login))
+	masterKey := argon2.IDKey(passphrase, salt[:], deriveArgon2Time, deriveArgon2Memory, deriveArgon2Threads, uint32(deriveKeyLen))
+
+	settings, err := json.Marshal(settingsToMap(cfg))
+	if err != nil {
+		return seed, fmt.Errorf("failed to encode settings (%w)", err)
+	}
+
+	words, err := wordListDigest(cfg)
+	if err != nil {
+		return seed, err
+	}
+
+	info := appendLengthPrefixed(nil, label, fmt.Sprint(in.Counter), string(settings), string(words[:]))
+	key, err := hkdf.Key(sha256.New, masterKey, nil, string(info), len(seed))
+	if err != nil {
+		return seed, fmt.Errorf("failed to expand master key (%w)", err)
+	}
+
+	copy(seed[:], key)
+
+	return seed, nil
+}
+
+// Returns the SHA-256 digest of the word list cfg picks words from, in order,
+// as the RNG picks words by their index in it
+func wordListDigest(cfg *config.Settings) ([32]byte, error) {
+	wl, err := asset.GetFilteredWordList(cfg.WordList, cfg.WordLengthMin, cfg.WordLengthMax)
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("failed to load word list (%w)", err)
+	}
+
+	return sha256.Sum256(appendLengthPrefixed(nil, wl...)), nil
+}
+
+// Appends each field to b prefixed with its length as a big endian uint32
+func appendLengthPrefixed(b []byte, fields ...string) []byte {
+	for _, f := range fields {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(f)))
+		b = append(b, f...)
+	}
+
+	return b
+}
+
+// readSecret prompts for a secret on stderr and reads it from stdin without
+// echoing it when stdin is a terminal, otherwise it reads the first line so
+// the secret can be piped in
+func readSecret(cmd *cobra.Command, prompt string) ([]byte, error) {
+	in := cmd.InOrStdin()
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		cmd.PrintErr(prompt)
+		secret, err := term.ReadPassword(int(f.Fd()))
+		cmd.PrintErrln()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read from terminal (%w)", err)
+		}
+
+		return secret, nil
+	}
+
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read from stdin (%w)", err)
+	}
+
+	return []byte(strings.TrimRight(line, "\r\n")), nil
+}
+
+func init() {
+	deriveCmd.Flags().String(siteKey, "", "site the password is for, e.g. example.com")
+	deriveCmd.Flags().String(loginKey, "", "login or username on the site")
+	deriveCmd.Flags().Int(counterKey, 1, "counter to bump when the password needs to change, valid values: 1+")
+	deriveCmd.Flags().Int(
+		deriveVersionKey,
+		deriveVersion,
+		"derivation version the password was created with, derive refuses versions which would give a different password",
+	)
+	_ = deriveCmd.MarkFlagRequired(siteKey) // only fails for an unknown flag
+
+	addPasswordOutputFlags(deriveCmd)
+
+	addConfigFlags(deriveCmd.Flags())
+
+	rootCmd.AddCommand(deriveCmd)
+}
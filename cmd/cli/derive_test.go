@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/eljamo/libpass/v8/config"
+	"github.com/eljamo/libpass/v8/config/option"
+)
+
+func TestDerivePassword(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.DefaultSettings()
+	cfg.NumPasswords = 1
+	in := deriveInput{Site: "example.com", Login: "alice", Counter: 2, Version: deriveVersion}
+	passphrase := []byte("correct horse battery staple")
+
+	first, err := derivePassword(passphrase, in, cfg)
+	if err != nil {
+		t.Fatalf("derivePassword() error = %v", err)
+	}
+
+	second, err := derivePassword(passphrase, in, cfg)
+	if err != nil {
+		t.Fatalf("derivePassword() error = %v", err)
+	}
+
+	if first != second {
+		t.Errorf("derivePassword() = %q then %q, want the same password for the same inputs", first, second)
+	}
+
+	bumped := in
+	bumped.Counter++
+	if pw, err := derivePassword(passphrase, bumped, cfg); err != nil || pw == first {
+		t.Errorf("derivePassword() with a bumped counter = %q, %v, want a different password than %q", pw, err, first)
+	}
+
+	changed := *cfg
+	changed.NumWords++
+	if pw, err := derivePassword(passphrase, in, &changed); err != nil || pw == first {
+		t.Errorf("derivePassword() with different settings = %q, %v, want a different password than %q", pw, err, first)
+	}
+}
+
+// Pins the passwords of the current derive version, a libpass upgrade or a
+// change to the derivation which fails this must bump deriveVersion. They're
+// the same on 32 bit platforms, checked with GOARCH=386 go test
+func TestDerivePasswordKnownAnswers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		preset string
+		want   string
+	}{
+		{option.PresetDefault, ";;20+LENDING+sitemap+REWARDS+50;;"},
+		{option.PresetAppleID, "&04-arthur-BODIES-SNIPPET-95&"},
+		{option.PresetWeb32, "|73_move_WAGER_void_CHEF_30|"},
+		{option.PresetWiFi, "8029,acorn,chicago,fees,relates,indexed,MARRIOTT,4087!!!!!!!!!!"},
+		{option.PresetXKCD, "HEADROOM-viewed-SADNESS-coffee-57/"},
+	}
+
+	in := deriveInput{Site: "example.com", Login: "alice", Counter: 1, Version: deriveVersion}
+	passphrase := []byte("correct horse battery staple")
+
+	for _, tt := range tests {
+		basePreset, err := loadBasePreset(tt.preset)
+		if err != nil {
+			t.Fatalf("loadBasePreset(%s) error = %v", tt.preset, err)
+		}
+
+		cfg, err := config.New(basePreset)
+		if err != nil {
+			t.Fatalf("config.New(%s) error = %v", tt.preset, err)
+		}
+		cfg.NumPasswords = 1
+
+		got, err := derivePassword(passphrase, in, cfg)
+		if err != nil {
+			t.Errorf("derivePassword(%s) error = %v", tt.preset, err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("derivePassword(%s) = %q, want %q", tt.preset, got, tt.want)
+		}
+	}
+}
+
+func TestDeriveSeedSeparatesFields(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.DefaultSettings()
+	passphrase := []byte("correct horse battery staple")
+
+	// the same concatenation of site and login must not derive the same seed
+	a, err := deriveSeed(passphrase, deriveInput{Site: "example.co", Login: "malice", Counter: 1, Version: deriveVersion}, cfg)
+	if err != nil {
+		t.Fatalf("deriveSeed() error = %v", err)
+	}
+
+	b, err := deriveSeed(passphrase, deriveInput{Site: "example.com", Login: "alice", Counter: 1, Version: deriveVersion}, cfg)
+	if err != nil {
+		t.Fatalf("deriveSeed() error = %v", err)
+	}
+
+	if a == b {
+		t.Error("deriveSeed() gave the same seed for different site and login splits")
+	}
+}
+
+func TestDeriveSeedEmptyPassphrase(t *testing.T) {
+	t.Parallel()
+
+	in := deriveInput{Site: "example.com", Counter: 1, Version: deriveVersion}
+	if _, err := deriveSeed(nil, in, config.DefaultSettings()); !errors.Is(err, ErrEmptyMasterPassphrase) {
+		t.Errorf("deriveSeed() error = %v, want %v", err, ErrEmptyMasterPassphrase)
+	}
+}
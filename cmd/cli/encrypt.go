@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Constant for the encrypt to flag key
+const encryptToKey string = "encrypt_to"
+
+// Returns the age recipients to encrypt the output to, none when it isn't
+// encrypted. Each flag value is a recipient, or a file listing them.
+func getEncryptToFlag(cmd *cobra.Command) ([]age.Recipient, error) {
+	values, err := cmd.Flags().GetStringArray(encryptToKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s flag: %w", encryptToKey, err)
+	}
+
+	var recipients []age.Recipient
+	for _, v := range values {
+		rs, err := parseRecipients(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s (%s): %w", encryptToKey, v, err)
+		}
+
+		recipients = append(recipients, rs...)
+	}
+
+	return recipients, nil
+}
+
+// Returns the recipient v, or those listed in the file v when it isn't one
+func parseRecipients(v string) ([]age.Recipient, error) {
+	if strings.HasPrefix(v, "age1") {
+		return age.ParseRecipients(strings.NewReader(v))
+	}
+
+	f, err := os.Open(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recipients file (%w)", err)
+	}
+	defer f.Close()
+
+	return age.ParseRecipients(f)
+}
+
+// Writes lines to w encrypted to the recipients, as an armored age file
+func encryptLines(w io.Writer, recipients []age.Recipient, lines []string) error {
+	aw := armor.NewWriter(w)
+	ew, err := age.Encrypt(aw, recipients...)
+	if err != nil {
+		return fmt.Errorf("failed to start encryption (%w)", err)
+	}
+
+	for _, l := range lines {
+		if _, err := io.WriteString(ew, l+"\n"); err != nil {
+			return fmt.Errorf("failed to encrypt (%w)", err)
+		}
+	}
+
+	if err := ew.Close(); err != nil {
+		return fmt.Errorf("failed to finish encryption (%w)", err)
+	}
+
+	if err := aw.Close(); err != nil {
+		return fmt.Errorf("failed to finish armor (%w)", err)
+	}
+
+	return nil
+}
+
+// Returns lines encrypted to the recipients, as an armored age file
+func encryptLinesToBytes(recipients []age.Recipient, lines []string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encryptLines(&buf, recipients, lines); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Adds the encrypt to flag to a flag set
+func addEncryptToFlag(flags *pflag.FlagSet) {
+	flags.StringArray(
+		encryptToKey,
+		nil,
+		"encrypt the output to an age recipient, an X25519 public key (age1...) or a file listing them, "+
+			"as an armored age file for the age tool to decrypt, can be given more than once",
+	)
+}
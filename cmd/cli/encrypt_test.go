@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+func decryptArmored(t *testing.T, data []byte, id age.Identity) string {
+	t.Helper()
+
+	r, err := age.Decrypt(armor.NewReader(bytes.NewReader(data)), id)
+	if err != nil {
+		t.Fatalf("Decrypt(%q) error = %v", data, err)
+	}
+
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+
+	return string(plain)
+}
+
+func TestEncryptLines(t *testing.T) {
+	t.Parallel()
+
+	alice, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("GenerateX25519Identity() error = %v", err)
+	}
+
+	bob, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("GenerateX25519Identity() error = %v", err)
+	}
+
+	lines := []string{"one-two", "three-four"}
+	var buf bytes.Buffer
+	if err := encryptLines(&buf, []age.Recipient{alice.Recipient(), bob.Recipient()}, lines); err != nil {
+		t.Fatalf("encryptLines() error = %v", err)
+	}
+
+	if !strings.HasPrefix(buf.String(), armor.Header) || strings.Contains(buf.String(), "one-two") {
+		t.Errorf("encryptLines() = %q, want an armored age file without the plaintext", buf.String())
+	}
+
+	for _, id := range []*age.X25519Identity{alice, bob} {
+		if got, want := decryptArmored(t, buf.Bytes(), id), "one-two\nthree-four\n"; got != want {
+			t.Errorf("decrypted encryptLines() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestParseRecipients(t *testing.T) {
+	t.Parallel()
+
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("GenerateX25519Identity() error = %v", err)
+	}
+
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("GenerateX25519Identity() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "recipients.txt")
+	content := "# team\n" + id.Recipient().String() + "\n\n" + other.Recipient().String() + "\n"
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{id.Recipient().String(), 1, false},
+		{file, 2, false},
+		{"age1notakey", 0, true},
+		{id.String(), 0, true},
+		{filepath.Join(dir, "missing.txt"), 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseRecipients(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRecipients(%q) error = %v, wantErr %t", tt.value, err, tt.wantErr)
+		}
+
+		if len(got) != tt.want {
+			t.Errorf("parseRecipients(%q) = %d recipients, want %d", tt.value, len(got), tt.want)
+		}
+	}
+}
+
+func TestPasswordOutputWriteEncrypted(t *testing.T) {
+	t.Parallel()
+
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("GenerateX25519Identity() error = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "passwords.csv.age")
+	pws := []string{"one-two", "three-four"}
+
+	tests := []struct {
+		args []string
+		file string
+	}{
+		{[]string{"--format", "csv", "--encrypt_to", id.Recipient().String()}, ""},
+		{[]string{"--format", "csv", "--encrypt_to", id.Recipient().String(), "--output_file", path}, path},
+	}
+
+	for _, tt := range tests {
+		cmd, out := newTestOutputCmd(t, tt.args...)
+		o, err := getPasswordOutput(cmd)
+		if err != nil {
+			t.Fatalf("getPasswordOutput(%q) error = %v", tt.args, err)
+		}
+
+		if err := o.write(cmd, pws); err != nil {
+			t.Fatalf("write(%q) error = %v", tt.args, err)
+		}
+
+		data := out.Bytes()
+		if tt.file != "" {
+			if out.Len() != 0 {
+				t.Errorf("write(%q) printed %q, want nothing on stdout", tt.args, out.String())
+			}
+
+			if data, err = os.ReadFile(tt.file); err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+		}
+
+		if got, want := decryptArmored(t, data, id), "password\none-two\nthree-four\n"; got != want {
+			t.Errorf("decrypted write(%q) = %q, want %q", tt.args, got, want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"github.com/eljamo/libpass/v8/asset"
+	"github.com/eljamo/libpass/v8/config"
+	"github.com/eljamo/libpass/v8/config/option"
+)
+
+// Number of possible values for a padding digit
+const digitChoices = 10
+
+// entropyEstimate describes the passwords a config generates, as seen by an
+// attacker who knows the config and only has to guess the random choices.
+type entropyEstimate struct {
+	// Bits of entropy in each password
+	Bits float64
+	// Mean password length in characters
+	TypicalLength int
+}
+
+// estimateEntropy works out the entropy and typical length of the passwords
+// generated from cfg, by counting the random choices libpass makes: one word
+// per NumWords from the filtered word list, the casing of each word for the
+// RANDOM case transform, a single separator and padding character when they
+// are RANDOM and padding is added, and every padding digit.
+func estimateEntropy(cfg *config.Settings) (entropyEstimate, error) {
+	wl, err := asset.GetFilteredWordList(cfg.WordList, cfg.WordLengthMin, cfg.WordLengthMax)
+	if err != nil {
+		return entropyEstimate{}, fmt.Errorf("failed to load word list (%w)", err)
+	}
+
+	if len(wl) == 0 {
+		return entropyEstimate{}, fmt.Errorf("no words found in %s (%s)", option.ConfigKeyWordList, cfg.WordList)
+	}
+
+	bits := float64(cfg.NumWords) * math.Log2(float64(len(wl)))
+	bits += caseTransformBits(cfg)
+
+	if cfg.SeparatorCharacter == option.SeparatorCharacterRandom && len(cfg.SeparatorAlphabet) > 0 {
+		bits += math.Log2(float64(len(cfg.SeparatorAlphabet)))
+	}
+
+	numDigits := cfg.PaddingDigitsBefore + cfg.PaddingDigitsAfter
+	bits += float64(numDigits) * math.Log2(digitChoices)
+
+	unpadded := unpaddedLength(cfg, wl)
+	if hasPaddingCharacters(cfg, unpadded) && cfg.PaddingCharacter == option.PaddingCharacterRandom && len(cfg.SymbolAlphabet) > 0 {
+		bits += math.Log2(float64(len(cfg.SymbolAlphabet)))
+	}
+
+	return entropyEstimate{
+		Bits:          bits,
+		TypicalLength: typicalLength(cfg, unpadded),
+	}, nil
+}
+
+// Returns the bits of entropy added by the case transform. Only RANDOM makes
+// a choice, per word, with even odds of upper or lower case. When every word
+// comes out the same, libpass flips one of them at random, so the patterns
+// with a single upper or lower case word are likelier than the rest, and the
+// entropy is that of this uneven distribution.
+func caseTransformBits(cfg *config.Settings) float64 {
+	n := cfg.NumWords
+	if cfg.CaseTransform != option.CaseTransformRandom || n < 2 {
+		return 0
+	}
+
+	var bits float64
+	for upper := 1; upper < n; upper++ {
+		p := randomCasePatternOdds(n, upper)
+		bits -= binomial(n, upper) * p * math.Log2(p)
+	}
+
+	return bits
+}
+
+// Returns the odds of one RANDOM case pattern of n words with upper of them
+// in upper case. A rejected all lowercase pattern gains a single uppercase
+// word, and a rejected all uppercase pattern a single lowercase word
+func randomCasePatternOdds(n, upper int) float64 {
+	if upper == 0 || upper == n {
+		return 0
+	}
+
+	each := math.Pow(2, -float64(n))
+	p := each
+	if upper == 1 {
+		p += each / float64(n)
+	}
+	if upper == n-1 {
+		p += each / float64(n)
+	}
+
+	return p
+}
+
+// Returns the number of ways to choose k of n
+func binomial(n, k int) float64 {
+	c := 1.0
+	for i := range k {
+		c = c * float64(n-i) / float64(i+1)
+	}
+
+	return c
+}
+
+// Checks if the padding type adds padding characters to a password of the
+// typical unpadded length, ADAPTIVE padding only adds them to shorter ones
+func hasPaddingCharacters(cfg *config.Settings, unpadded float64) bool {
+	switch cfg.PaddingType {
+	case option.PaddingTypeFixed:
+		return cfg.PaddingCharactersBefore+cfg.PaddingCharactersAfter > 0
+	case option.PaddingTypeAdaptive:
+		return float64(cfg.PadToLength) > unpadded
+	}
+
+	return false
+}
+
+// Returns the mean length of a password generated from cfg, before any
+// padding characters, using the mean length of the words in the filtered word
+// list
+func unpaddedLength(cfg *config.Settings, wl []string) float64 {
+	var wordRunes int
+	for _, w := range wl {
+		wordRunes += utf8.RuneCountInString(w)
+	}
+	meanWordLen := float64(wordRunes) / float64(len(wl))
+
+	// Separators sit between and around the words, the edge ones are only
+	// kept when there are digits beyond them
+	numSeparators := cfg.NumWords + 1
+	if cfg.PaddingDigitsBefore == 0 {
+		numSeparators--
+	}
+	if cfg.PaddingDigitsAfter == 0 {
+		numSeparators--
+	}
+
+	sepLen := 1
+	if cfg.SeparatorCharacter != option.SeparatorCharacterRandom {
+		sepLen = utf8.RuneCountInString(cfg.SeparatorCharacter)
+	}
+
+	return float64(cfg.NumWords)*meanWordLen +
+		float64(numSeparators*sepLen) +
+		float64(cfg.PaddingDigitsBefore+cfg.PaddingDigitsAfter)
+}
+
+// Returns the mean length of a password generated from cfg, from its mean
+// unpadded length
+func typicalLength(cfg *config.Settings, unpadded float64) int {
+	length := unpadded
+	switch cfg.PaddingType {
+	case option.PaddingTypeFixed:
+		length += float64(cfg.PaddingCharactersBefore + cfg.PaddingCharactersAfter)
+	case option.PaddingTypeAdaptive:
+		length = math.Max(length, float64(cfg.PadToLength))
+	}
+
+	return int(math.Round(length))
+}
@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"math"
+	"testing"
+
+	"github.com/eljamo/libpass/v8/asset"
+	"github.com/eljamo/libpass/v8/config"
+	"github.com/eljamo/libpass/v8/config/option"
+)
+
+func TestEstimateEntropyDefault(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.DefaultSettings()
+	wl, err := asset.GetFilteredWordList(cfg.WordList, cfg.WordLengthMin, cfg.WordLengthMax)
+	if err != nil {
+		t.Fatalf("GetFilteredWordList() error = %v", err)
+	}
+
+	// 3 words, RANDOM case with its 6 mixed-case patterns equally likely, a
+	// random separator and padding character from 17 symbols, and 4 digits
+	want := 3*math.Log2(float64(len(wl))) + math.Log2(6) + 2*math.Log2(17) + 4*math.Log2(10)
+
+	got, err := estimateEntropy(cfg)
+	if err != nil {
+		t.Fatalf("estimateEntropy() error = %v", err)
+	}
+
+	if math.Abs(got.Bits-want) > 1e-9 {
+		t.Errorf("estimateEntropy().Bits = %f, want %f", got.Bits, want)
+	}
+}
+
+func TestCaseTransformBits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		transform string
+		numWords  int
+		want      float64
+	}{
+		{option.CaseTransformUpper, 4, 0},
+		{option.CaseTransformRandom, 1, 0},
+		// both mixed-case patterns, whichever word libpass flips
+		{option.CaseTransformRandom, 2, 1},
+		{option.CaseTransformRandom, 3, math.Log2(6)},
+		// the 8 patterns with one word flipped have odds of 5/64, the 6 with
+		// two upper case words 4/64
+		{option.CaseTransformRandom, 4, 40.0/64*math.Log2(64.0/5) + 24.0/64*4},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Settings{CaseTransform: tt.transform, NumWords: tt.numWords}
+		if got := caseTransformBits(cfg); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("caseTransformBits(%s, %d words) = %f, want %f", tt.transform, tt.numWords, got, tt.want)
+		}
+	}
+}
+
+func TestEstimateEntropyFixedChoices(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.DefaultSettings()
+	cfg.CaseTransform = option.CaseTransformUpper
+	cfg.SeparatorCharacter = "-"
+	cfg.PaddingCharacter = "!"
+	cfg.PaddingDigitsBefore = 0
+	cfg.PaddingDigitsAfter = 0
+
+	wl, err := asset.GetFilteredWordList(cfg.WordList, cfg.WordLengthMin, cfg.WordLengthMax)
+	if err != nil {
+		t.Fatalf("GetFilteredWordList() error = %v", err)
+	}
+
+	got, err := estimateEntropy(cfg)
+	if err != nil {
+		t.Fatalf("estimateEntropy() error = %v", err)
+	}
+
+	// only the words are random
+	if want := 3 * math.Log2(float64(len(wl))); math.Abs(got.Bits-want) > 1e-9 {
+		t.Errorf("estimateEntropy().Bits = %f, want %f", got.Bits, want)
+	}
+}
+
+func TestEstimateEntropyAdaptiveLength(t *testing.T) {
+	t.Parallel()
+
+	basePreset, err := loadBasePreset(option.PresetWiFi)
+	if err != nil {
+		t.Fatalf("loadBasePreset() error = %v", err)
+	}
+
+	cfg, err := config.New(basePreset)
+	if err != nil {
+		t.Fatalf("config.New() error = %v", err)
+	}
+
+	got, err := estimateEntropy(cfg)
+	if err != nil {
+		t.Fatalf("estimateEntropy() error = %v", err)
+	}
+
+	if got.TypicalLength != cfg.PadToLength {
+		t.Errorf("estimateEntropy().TypicalLength = %d, want %d", got.TypicalLength, cfg.PadToLength)
+	}
+}
+
+func TestEstimateEntropyAdaptivePaddingCharacter(t *testing.T) {
+	t.Parallel()
+
+	estimate := func(padToLength int) float64 {
+		cfg := config.DefaultSettings()
+		cfg.PaddingType = option.PaddingTypeAdaptive
+		cfg.PaddingCharacter = option.PaddingCharacterRandom
+		cfg.PadToLength = padToLength
+
+		got, err := estimateEntropy(cfg)
+		if err != nil {
+			t.Fatalf("estimateEntropy() error = %v", err)
+		}
+
+		return got.Bits
+	}
+
+	// the typical password is already longer than 10 characters, so only
+	// padding to 64 adds a random padding character
+	none, short, long := estimate(0), estimate(10), estimate(64)
+	if short != none {
+		t.Errorf("estimateEntropy().Bits padding to 10 = %f, want %f as nothing is padded", short, none)
+	}
+
+	want := none + math.Log2(float64(len(config.DefaultSettings().SymbolAlphabet)))
+	if math.Abs(long-want) > 1e-9 {
+		t.Errorf("estimateEntropy().Bits padding to 64 = %f, want %f", long, want)
+	}
+}
+
+func TestEstimateEntropyNoWords(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.DefaultSettings()
+	cfg.WordLengthMin = 40
+	cfg.WordLengthMax = 40
+
+	if _, err := estimateEntropy(cfg); err == nil {
+		t.Error("estimateEntropy() error = nil, want an error for an empty word list")
+	}
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// Constants for the ephemeral display flag keys
+const (
+	ephemeralKey        string = "ephemeral"
+	ephemeralTimeoutKey string = "ephemeral_timeout"
+)
+
+// How long the passwords stay on screen by default
+const defaultEphemeralTimeout time.Duration = time.Minute
+
+// Shows lines on the terminal's alternate screen until a key is pressed or
+// the timeout passes, then returns to the normal screen, so they're not left
+// in the scrollback
+func showEphemeral(cmd *cobra.Command, lines []string) error {
+	timeout, err := cmd.Flags().GetDuration(ephemeralTimeoutKey)
+	if err != nil {
+		return fmt.Errorf("failed to get %s flag: %w", ephemeralTimeoutKey, err)
+	}
+
+	if timeout < 0 {
+		return fmt.Errorf("%s (%s) must be greater than or equal to 0", ephemeralTimeoutKey, timeout)
+	}
+
+	// Opened non-blocking so reads honour the timeout's deadline
+	tty, err := os.OpenFile(ttyPath, os.O_RDWR|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open terminal: %w", err)
+	}
+	defer tty.Close()
+
+	if err := showUntilKey(cmd.OutOrStdout(), tty, lines, timeout); err != nil {
+		return fmt.Errorf("failed to show passwords: %w", err)
+	}
+
+	return nil
+}
+
+// Draws lines on the alternate screen of out and waits for a key from tty,
+// clearing the screen before returning to the normal one
+func showUntilKey(out io.Writer, tty *os.File, lines []string, timeout time.Duration) error {
+	fd := int(tty.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to set terminal to raw mode (%w)", err)
+	}
+	defer term.Restore(fd, state)
+
+	if _, err := io.WriteString(out, enterAltScreen); err != nil {
+		return fmt.Errorf("failed to write to terminal (%w)", err)
+	}
+	defer io.WriteString(out, clearScreen+exitAltScreen)
+
+	prompt := "Press any key to clear the screen"
+	if timeout > 0 {
+		prompt += fmt.Sprintf(", clearing in %s", timeout)
+
+		if err := tty.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			return fmt.Errorf("failed to set terminal read deadline (%w)", err)
+		}
+	}
+
+	// raw mode doesn't turn \n into \r\n
+	view := clearScreen + strings.Join(lines, "\r\n") + "\r\n\r\n" + prompt
+	if _, err := io.WriteString(out, view); err != nil {
+		return fmt.Errorf("failed to write to terminal (%w)", err)
+	}
+
+	buf := make([]byte, 256)
+	if _, err := tty.Read(buf); err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+		return fmt.Errorf("failed to read from terminal (%w)", err)
+	}
+
+	return nil
+}
+
+// Adds the ephemeral display flags to a command, which can't be used along
+// with copying to the clipboard
+func addEphemeralFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.Bool(
+		ephemeralKey,
+		false,
+		"show the passwords on the terminal's alternate screen until a key is pressed, "+
+			"so they're not left in the scrollback, stdout must be a terminal",
+	)
+	flags.Duration(
+		ephemeralTimeoutKey,
+		defaultEphemeralTimeout,
+		"how long to show the passwords with --ephemeral before clearing the screen, 0 to wait for a key",
+	)
+	cmd.MarkFlagsMutuallyExclusive(clipKey, ephemeralKey)
+}
+
+// Returns whether the ephemeral flag is set, refusing it when stdout isn't a
+// terminal as the alternate screen would end up in the output
+func getEphemeralFlag(cmd *cobra.Command) (bool, error) {
+	ephemeral, err := cmd.Flags().GetBool(ephemeralKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to get %s flag: %w", ephemeralKey, err)
+	}
+
+	if !ephemeral {
+		return false, nil
+	}
+
+	if !isTerminalOutput(cmd) {
+		return false, fmt.Errorf("--%s needs stdout to be a terminal", ephemeralKey)
+	}
+
+	return true, nil
+}
+
+// Returns whether cmd's output is a terminal, rather than a file or pipe
+func isTerminalOutput(cmd *cobra.Command) bool {
+	f, ok := cmd.OutOrStdout().(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// Returns whether the command reads from a terminal
+func isTerminalInput(cmd *cobra.Command) bool {
+	f, ok := cmd.InOrStdin().(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
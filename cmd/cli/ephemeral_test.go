@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestGetEphemeralFlagNeedsTerminal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		args    []string
+		want    bool
+		wantErr bool
+	}{
+		{nil, false, false},
+		{[]string{"--ephemeral"}, false, true},
+	}
+
+	for _, tt := range tests {
+		cmd := &cobra.Command{}
+		addClipFlags(cmd.Flags())
+		addEphemeralFlags(cmd)
+		cmd.SetOut(&bytes.Buffer{})
+		if err := cmd.ParseFlags(tt.args); err != nil {
+			t.Fatalf("ParseFlags(%q) error = %v", tt.args, err)
+		}
+
+		got, err := getEphemeralFlag(cmd)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("getEphemeralFlag(%q) error = %v, wantErr %t", tt.args, err, tt.wantErr)
+		}
+
+		if got != tt.want {
+			t.Errorf("getEphemeralFlag(%q) = %t, want %t", tt.args, got, tt.want)
+		}
+	}
+}
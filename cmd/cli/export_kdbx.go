@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Constants for the export-kdbx flag keys
+const (
+	dbKey         string = "db"
+	entriesKey    string = "entries"
+	keyFileKey    string = "key_file"
+	noPasswordKey string = "no_password"
+)
+
+var exportKdbxCmd = &cobra.Command{
+	Use:   "export-kdbx",
+	Short: "Create a KeePass database with a generated password for each entry",
+	Long: `Create a KeePass KDBX 4 database, with an entry for each row of a CSV file of
+titles, and optionally usernames and URLs, each with a password generated with
+the given preset, custom config and flags. The database is encrypted with
+AES-256 under a master password, a KeePass key file or both, the key derived
+with Argon2id. The passwords are never printed.
+
+The entries file needs a header naming its title, username and url columns,
+only title is required`,
+	Args: cobra.NoArgs,
+	RunE: runExportKdbxCmd,
+}
+
+func runExportKdbxCmd(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+
+	db, err := flags.GetString(dbKey)
+	if err != nil {
+		return fmt.Errorf("failed to get %s flag: %w", dbKey, err)
+	}
+
+	entriesFile, err := flags.GetString(entriesKey)
+	if err != nil {
+		return fmt.Errorf("failed to get %s flag: %w", entriesKey, err)
+	}
+
+	keyFile, err := flags.GetString(keyFileKey)
+	if err != nil {
+		return fmt.Errorf("failed to get %s flag: %w", keyFileKey, err)
+	}
+
+	noPassword, err := flags.GetBool(noPasswordKey)
+	if err != nil {
+		return fmt.Errorf("failed to get %s flag: %w", noPasswordKey, err)
+	}
+
+	force, err := flags.GetBool(forceKey)
+	if err != nil {
+		return fmt.Errorf("failed to get %s flag: %w", forceKey, err)
+	}
+
+	if noPassword && keyFile == "" {
+		return fmt.Errorf("--%s needs a --%s to encrypt the database with instead", noPasswordKey, keyFileKey)
+	}
+
+	// checked now too, so a master password isn't asked for in vain
+	if _, err := os.Stat(db); err == nil && !force {
+		return fmt.Errorf("%s already exists, use --%s to overwrite it", db, forceKey)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to check database: %w", err)
+	}
+
+	entries, err := readPasswordEntriesFile(entriesFile)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", entriesKey, err)
+	}
+
+	var fileKey []byte
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read key file: %w", err)
+		}
+
+		if fileKey, err = kdbxKeyFileKey(data); err != nil {
+			return fmt.Errorf("invalid key file: %w", err)
+		}
+	}
+
+	var password []byte
+	if !noPassword {
+		if password, err = readNewSecret(cmd, "master password"); err != nil {
+			return err
+		}
+	}
+
+	pws, err := generatePasswords(cmd, len(entries))
+	if err != nil {
+		return err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(db), filepath.Ext(db))
+	key := kdbxCompositeKey(password, !noPassword, fileKey)
+	data, err := writeKdbx(name, entries, pws, key, defaultKdbxKDF, time.Now().UTC(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to create database: %w", err)
+	}
+
+	err = writeFileAtomic(db, data, 0o600, force)
+	if errors.Is(err, ErrFileExists) {
+		return fmt.Errorf("%s already exists, use --%s to overwrite it", db, forceKey)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write database: %w", err)
+	}
+
+	cmd.PrintErrf("Wrote %d entries to %s\n", len(entries), db)
+
+	return nil
+}
+
+func init() {
+	exportKdbxCmd.Flags().String(dbKey, "", "KeePass database file to create, readable only by you")
+	exportKdbxCmd.Flags().String(entriesKey, "", "CSV file with a title column, and optionally username and url columns")
+	exportKdbxCmd.Flags().String(keyFileKey, "", "KeePass key file to encrypt the database with, along with the master password")
+	exportKdbxCmd.Flags().Bool(noPasswordKey, false, "encrypt the database with only the --key_file, without a master password")
+	exportKdbxCmd.Flags().Bool(forceKey, false, "overwrite --db if it already exists")
+	_ = exportKdbxCmd.MarkFlagRequired(dbKey)      // only fails for an unknown flag
+	_ = exportKdbxCmd.MarkFlagRequired(entriesKey) // only fails for an unknown flag
+
+	addConfigFlags(exportKdbxCmd.Flags())
+
+	rootCmd.AddCommand(exportKdbxCmd)
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package cli
+
+import (
+	"io/fs"
+	"os"
+)
+
+// Does nothing, files don't have a Unix owner on this platform
+func copyOwner(_ *os.File, _ fs.FileInfo) error {
+	return nil
+}
+
+// Does nothing, directories can't be synced on this platform
+func syncDir(_ string) error {
+	return nil
+}
@@ -2,6 +2,8 @@ package cli
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/eljamo/libpass/v8/asset"
 	"github.com/eljamo/libpass/v8/config"
@@ -13,11 +15,42 @@ import (
 const CustomConfigPathKey string = "custom_config_path"
 
 func generateConfig(cmd *cobra.Command) (*config.Settings, error) {
+	layers, err := getConfigLayers(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return layers.merge(nil)
+}
+
+// The config layers given by a cmd's flags, which are merged in field order
+// on top of the base preset
+type configLayers struct {
+	preset    string
+	customCfg map[string]any
+	codeCfg   map[string]any
+	flagCfg   map[string]any
+	// The server section of the custom config, which isn't passed to libpass
+	serverCfg map[string]any
+}
+
+// Loads the config layers from a cmd's flags
+func getConfigLayers(cmd *cobra.Command) (*configLayers, error) {
 	customCfg, err := loadCustomConfig(cmd)
 	if err != nil {
 		return nil, err
 	}
 
+	serverCfg, err := splitServerConfig(customCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	codeCfg, err := loadSettingsCode(cmd)
+	if err != nil {
+		return nil, err
+	}
+
 	flagCfg, err := getCmdFlags(cmd)
 	if err != nil {
 		return nil, err
@@ -28,26 +61,73 @@ func generateConfig(cmd *cobra.Command) (*config.Settings, error) {
 		return nil, err
 	}
 
-	if presetValue == option.PresetDefault {
-		cfg, err := config.New(customCfg, flagCfg)
+	return &configLayers{presetValue, customCfg, codeCfg, flagCfg, serverCfg}, nil
+}
+
+// Merges the config layers into a single config, with an optional extra layer
+// on top. A preset in the extra layer replaces the base preset, the same way
+// one in a custom config does.
+func (l *configLayers) merge(extra map[string]any) (*config.Settings, error) {
+	presetValue := l.preset
+	if preset := getPresetFromCustomConfig(extra); preset != "" {
+		presetValue = preset
+	}
+
+	return newConfig(presetValue, l.customCfg, l.codeCfg, l.flagCfg, extra)
+}
+
+// Layers the base preset and then the given layers, in order, into a single
+// config
+func newConfig(presetValue string, layers ...map[string]any) (*config.Settings, error) {
+	if presetValue != option.PresetDefault {
+		basePreset, err := loadBasePreset(presetValue)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create config (%w)", err)
+			return nil, err
 		}
 
-		return cfg, nil
+		layers = append([]map[string]any{basePreset}, layers...)
 	}
 
-	basePreset, err := loadBasePreset(presetValue)
+	cfg, err := config.New(layers...)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to create config (%w)", err)
+	}
+
+	return cfg, nil
+}
+
+// Resolves a built-in preset name or a custom config file path to the config
+// it produces, layered the same way as generateConfig but without cmd flags
+func resolveConfigSource(source string) (*config.Settings, error) {
+	if preset, ok := lookupPreset(source); ok {
+		return newConfig(preset)
 	}
 
-	cfg, err := config.New(basePreset, customCfg, flagCfg)
+	customCfg, err := loadCustomConfigJSON(source)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create config (%w)", err)
+		return nil, err
 	}
 
-	return cfg, nil
+	if _, err := splitServerConfig(customCfg); err != nil {
+		return nil, err
+	}
+
+	presetValue := getPresetFromCustomConfig(customCfg)
+	if presetValue == "" {
+		presetValue = option.PresetDefault
+	}
+
+	return newConfig(presetValue, customCfg)
+}
+
+// Returns the built-in preset matching the given name, ignoring case
+func lookupPreset(name string) (string, bool) {
+	preset := strings.ToUpper(name)
+	if !slices.Contains(option.Presets, preset) {
+		return "", false
+	}
+
+	return preset, true
 }
 
 // Loads the base preset and the custom config from the JSON files
@@ -60,6 +140,25 @@ func loadCustomConfig(cmd *cobra.Command) (map[string]any, error) {
 	return customCfg, nil
 }
 
+// Loads the settings code flag as a config layer
+func loadSettingsCode(cmd *cobra.Command) (map[string]any, error) {
+	code, err := cmd.Flags().GetString(settingsCodeKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settings code (%w)", err)
+	}
+
+	if code == "" {
+		return nil, nil
+	}
+
+	codeCfg, err := decodeSettingsCode(code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings code (%w)", err)
+	}
+
+	return codeCfg, nil
+}
+
 // Loads the base preset and the custom config from the JSON files
 func loadBasePreset(presetValue string) (map[string]any, error) {
 	basePreset, err := asset.GetJSONPreset(presetValue)
@@ -88,12 +187,64 @@ func getCustomConfigJSON(cmd *cobra.Command) (map[string]any, error) {
 // CLI-only flags which must not be passed to libpass as config, it rejects
 // JSON containing unknown fields
 var nonConfigFlagKeys = map[string]struct{}{
-	customConfigPathKey: {},
-	scoreKey:            {},
+	customConfigPathKey:   {},
+	settingsCodeKey:       {},
+	insecureSeedKey:       {},
+	scoreKey:              {},
+	siteKey:               {},
+	loginKey:              {},
+	counterKey:            {},
+	deriveVersionKey:      {},
+	samplesKey:            {},
+	browserKey:            {},
+	chromeExtensionIDKey:  {},
+	firefoxExtensionIDKey: {},
+	clipKey:               {},
+	clipTimeoutKey:        {},
+	ephemeralKey:          {},
+	ephemeralTimeoutKey:   {},
+	qrKey:                 {},
+	qrPNGKey:              {},
+	qrSSIDKey:             {},
+	ssidKey:               {},
+	snippetKey:            {},
+	pskKey:                {},
+	hashKey:               {},
+	hashOnlyKey:           {},
+	bcryptCostKey:         {},
+	argon2TimeKey:         {},
+	argon2MemoryKey:       {},
+	argon2ThreadsKey:      {},
+	sha512RoundsKey:       {},
+	outputFileKey:         {},
+	formatKey:             {},
+	forceKey:              {},
+	encryptToKey:          {},
+	vaultFileKey:          {},
+	inputFileKey:          {},
+	dbKey:                 {},
+	entriesKey:            {},
+	keyFileKey:            {},
+	noPasswordKey:         {},
+}
+
+func init() {
+	for key := range serverSettingKeys {
+		nonConfigFlagKeys[key] = struct{}{}
+	}
 }
 
 // Returns a map of the cmd flags and their values
 func getCmdFlags(cmd *cobra.Command) (map[string]any, error) {
+	return collectCmdFlags(cmd, func(name string) bool {
+		_, ok := nonConfigFlagKeys[name]
+		return !ok
+	})
+}
+
+// Returns a map of the explicitly set cmd flags, for which include returns
+// true, and their values
+func collectCmdFlags(cmd *cobra.Command, include func(name string) bool) (map[string]any, error) {
 	flags := make(map[string]any)
 
 	var err error
@@ -102,7 +253,7 @@ func getCmdFlags(cmd *cobra.Command) (map[string]any, error) {
 			return
 		}
 
-		if _, ok := nonConfigFlagKeys[flag.Name]; ok {
+		if !include(flag.Name) {
 			return
 		}
 
@@ -111,6 +262,8 @@ func getCmdFlags(cmd *cobra.Command) (map[string]any, error) {
 			flags[flag.Name], err = cmd.Flags().GetString(flag.Name)
 		case "int":
 			flags[flag.Name], err = cmd.Flags().GetInt(flag.Name)
+		case "float64":
+			flags[flag.Name], err = cmd.Flags().GetFloat64(flag.Name)
 		case "bool":
 			flags[flag.Name], err = cmd.Flags().GetBool(flag.Name)
 		case "stringSlice":
@@ -139,6 +292,24 @@ func loadCustomConfigJSON(path string) (map[string]any, error) {
 	return js, nil
 }
 
+// Removes the server section from a custom config, so the rest can be passed
+// to libpass, and returns it
+func splitServerConfig(customCfgJSON map[string]any) (map[string]any, error) {
+	section, ok := customCfgJSON[serverConfigKey]
+	if !ok {
+		return nil, nil
+	}
+
+	delete(customCfgJSON, serverConfigKey)
+
+	serverCfg, ok := section.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s in custom config must be an object", serverConfigKey)
+	}
+
+	return serverCfg, nil
+}
+
 // Returns the preset value from the custom config if it exists
 func getPresetFromCustomConfig(customCfgJSON map[string]any) string {
 	if customCfgJSON == nil {
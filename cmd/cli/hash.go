@@ -0,0 +1,293 @@
+package cli
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/md4"
+)
+
+// Constants for the hash flag keys
+const (
+	hashKey          string = "hash"
+	hashOnlyKey      string = "hash_only"
+	bcryptCostKey    string = "bcrypt_cost"
+	argon2TimeKey    string = "argon2_time"
+	argon2MemoryKey  string = "argon2_memory"
+	argon2ThreadsKey string = "argon2_threads"
+	sha512RoundsKey  string = "sha512_rounds"
+)
+
+// Constants for the hash algorithms
+const (
+	hashBcrypt      string = "bcrypt"
+	hashArgon2id    string = "argon2id"
+	hashSHA512Crypt string = "sha512-crypt"
+	hashSSHA        string = "ssha"
+	hashNT          string = "nt"
+)
+
+var hashAlgorithms = []string{hashBcrypt, hashArgon2id, hashSHA512Crypt, hashSSHA, hashNT}
+
+// Default cost of bcrypt hashes, above bcrypt.DefaultCost as that was picked
+// for the hardware of 2010
+const defaultBcryptCost int = 12
+
+// Default Argon2id parameters, the second recommended option of RFC 9106,
+// and the salt and key lengths used
+const (
+	defaultArgon2Time    uint32 = 3
+	defaultArgon2Memory  uint32 = 64 * 1024
+	defaultArgon2Threads uint8  = 4
+	argon2SaltLength     int    = 16
+	argon2KeyLength      uint32 = 32
+)
+
+// Salt lengths of the salted hashes, in characters for SHA-512 crypt and
+// bytes for SSHA
+const (
+	sha512CryptSaltLength int = 16
+	sshaSaltLength        int = 8
+)
+
+// hashOptions are how to hash passwords
+type hashOptions struct {
+	// Hash algorithm, no hashing when empty
+	algorithm string
+	// Print only the hashes, leaving the passwords out
+	only          bool
+	bcryptCost    int
+	argon2Time    uint32
+	argon2Memory  uint32
+	argon2Threads uint8
+	sha512Rounds  int
+}
+
+// Returns the hash flags, checking the cost parameters are in range
+func getHashFlags(cmd *cobra.Command) (hashOptions, error) {
+	var o hashOptions
+	var err error
+	flags := cmd.Flags()
+
+	if o.algorithm, err = flags.GetString(hashKey); err != nil {
+		return o, fmt.Errorf("failed to get %s flag: %w", hashKey, err)
+	}
+
+	o.algorithm = strings.ToLower(o.algorithm)
+	if o.algorithm != "" && !slices.Contains(hashAlgorithms, o.algorithm) {
+		return o, fmt.Errorf("%s (%s) must be one of %s", hashKey, o.algorithm, strings.Join(hashAlgorithms, ", "))
+	}
+
+	if o.only, err = flags.GetBool(hashOnlyKey); err != nil {
+		return o, fmt.Errorf("failed to get %s flag: %w", hashOnlyKey, err)
+	}
+
+	if o.only && o.algorithm == "" {
+		return o, fmt.Errorf("--%s needs --%s", hashOnlyKey, hashKey)
+	}
+
+	if o.bcryptCost, err = getBcryptCostFlag(cmd); err != nil {
+		return o, err
+	}
+
+	if o.argon2Time, err = flags.GetUint32(argon2TimeKey); err != nil {
+		return o, fmt.Errorf("failed to get %s flag: %w", argon2TimeKey, err)
+	}
+
+	if o.argon2Time < 1 {
+		return o, fmt.Errorf("%s (%d) must be greater than 0", argon2TimeKey, o.argon2Time)
+	}
+
+	if o.argon2Threads, err = flags.GetUint8(argon2ThreadsKey); err != nil {
+		return o, fmt.Errorf("failed to get %s flag: %w", argon2ThreadsKey, err)
+	}
+
+	if o.argon2Threads < 1 {
+		return o, fmt.Errorf("%s (%d) must be greater than 0", argon2ThreadsKey, o.argon2Threads)
+	}
+
+	if o.argon2Memory, err = flags.GetUint32(argon2MemoryKey); err != nil {
+		return o, fmt.Errorf("failed to get %s flag: %w", argon2MemoryKey, err)
+	}
+
+	// Argon2 needs at least 8 KiB of memory per thread
+	if minMemory := 8 * uint32(o.argon2Threads); o.argon2Memory < minMemory {
+		return o, fmt.Errorf("%s (%d) must be at least %d with %d threads", argon2MemoryKey, o.argon2Memory, minMemory, o.argon2Threads)
+	}
+
+	if o.sha512Rounds, err = flags.GetInt(sha512RoundsKey); err != nil {
+		return o, fmt.Errorf("failed to get %s flag: %w", sha512RoundsKey, err)
+	}
+
+	if o.sha512Rounds < sha512CryptMinRounds || o.sha512Rounds > sha512CryptMaxRounds {
+		return o, fmt.Errorf(
+			"%s (%d) must be between %d and %d",
+			sha512RoundsKey,
+			o.sha512Rounds,
+			sha512CryptMinRounds,
+			sha512CryptMaxRounds,
+		)
+	}
+
+	return o, nil
+}
+
+// Returns the bcrypt cost flag, checking it's in range
+func getBcryptCostFlag(cmd *cobra.Command) (int, error) {
+	cost, err := cmd.Flags().GetInt(bcryptCostKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get %s flag: %w", bcryptCostKey, err)
+	}
+
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return 0, fmt.Errorf("%s (%d) must be between %d and %d", bcryptCostKey, cost, bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	return cost, nil
+}
+
+// Returns the hash of each password, or nil when no hash is wanted
+func (o hashOptions) hashAll(pws []string) ([]string, error) {
+	if o.algorithm == "" {
+		return nil, nil
+	}
+
+	hashes := make([]string, len(pws))
+	for i, pw := range pws {
+		h, err := o.hash(pw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash password: %w", err)
+		}
+
+		hashes[i] = h
+	}
+
+	return hashes, nil
+}
+
+// Returns lines with each password's hash after its line, or just the
+// hashes when only they are wanted
+func (o hashOptions) annotate(lines, hashes []string) []string {
+	if hashes == nil {
+		return lines
+	}
+
+	if o.only {
+		return hashes
+	}
+
+	annotated := make([]string, len(lines))
+	for i, l := range lines {
+		annotated[i] = l + "  " + hashes[i]
+	}
+
+	return annotated
+}
+
+// Returns the hash of pw in the standard encoding for the algorithm
+func (o hashOptions) hash(pw string) (string, error) {
+	switch o.algorithm {
+	case hashBcrypt:
+		h, err := bcrypt.GenerateFromPassword([]byte(pw), o.bcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("bcrypt failed (%w)", err)
+		}
+
+		return string(h), nil
+	case hashArgon2id:
+		salt := randomBytes(argon2SaltLength)
+		key := argon2.IDKey([]byte(pw), salt, o.argon2Time, o.argon2Memory, o.argon2Threads, argon2KeyLength)
+
+		return fmt.Sprintf(
+			"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version,
+			o.argon2Memory,
+			o.argon2Time,
+			o.argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key),
+		), nil
+	case hashSHA512Crypt:
+		return sha512Crypt(pw, cryptSalt(sha512CryptSaltLength), o.sha512Rounds), nil
+	case hashSSHA:
+		return sshaHash(pw, randomBytes(sshaSaltLength)), nil
+	case hashNT:
+		return ntHash(pw), nil
+	default:
+		return "", fmt.Errorf("unknown hash algorithm (%s)", o.algorithm)
+	}
+}
+
+// Returns n random bytes for a salt
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	_, _ = rand.Read(b) // never fails, it crashes the program instead
+
+	return b
+}
+
+// Returns a random salt of n characters from the crypt(3) alphabet
+func cryptSalt(n int) string {
+	b := randomBytes(n)
+	for i := range b {
+		b[i] = cryptAlphabet[b[i]&0x3f]
+	}
+
+	return string(b)
+}
+
+// Returns the salted SHA-1 hash of pw as an LDAP userPassword value
+func sshaHash(pw string, salt []byte) string {
+	h := sha1.New()
+	h.Write([]byte(pw))
+	h.Write(salt)
+
+	return "{SSHA}" + base64.StdEncoding.EncodeToString(append(h.Sum(nil), salt...))
+}
+
+// Returns the NT hash of pw, the MD4 of its UTF-16LE encoding, as hex
+func ntHash(pw string) string {
+	h := md4.New()
+	for _, u := range utf16.Encode([]rune(pw)) {
+		h.Write([]byte{byte(u), byte(u >> 8)})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Adds the hash flags to a flag set
+func addHashFlags(flags *pflag.FlagSet) {
+	flags.String(
+		hashKey,
+		"",
+		fmt.Sprintf(
+			"print the hash of each password next to it, for /etc/shadow, htpasswd, LDAP or NTLM, valid values: %s",
+			strings.Join(hashAlgorithms, ", "),
+		),
+	)
+	flags.Bool(hashOnlyKey, false, "print only the hashes, leaving the passwords out")
+	addBcryptCostFlag(flags)
+	flags.Uint32(argon2TimeKey, defaultArgon2Time, "Argon2id passes over the memory, valid values: 1+")
+	flags.Uint32(argon2MemoryKey, defaultArgon2Memory, "Argon2id memory in KiB, valid values: 8 per thread+")
+	flags.Uint8(argon2ThreadsKey, defaultArgon2Threads, "Argon2id threads, valid values: 1 to 255")
+	flags.Int(
+		sha512RoundsKey,
+		sha512CryptDefaultRounds,
+		"SHA-512 crypt rounds, valid values: 1000 to 999999999",
+	)
+}
+
+// Adds the bcrypt cost flag to a flag set
+func addBcryptCostFlag(flags *pflag.FlagSet) {
+	flags.Int(bcryptCostKey, defaultBcryptCost, "bcrypt cost, each step doubles the work, valid values: 4 to 31")
+}
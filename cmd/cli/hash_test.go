@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestSHA512Crypt(t *testing.T) {
+	t.Parallel()
+
+	// test vectors from "Unix crypt using SHA-256 and SHA-512", and from
+	// crypt(3) for the clamped rounds
+	tests := []struct {
+		pw     string
+		salt   string
+		rounds int
+		want   string
+	}{
+		{"Hello world!", "saltstring", 5000, "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1"},
+		{"Hello world!", "saltstringsaltstring", 10000, "$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v."},
+		{"a very much longer text to encrypt.  This one even stretches over morethan one line.", "anotherlongsaltstring", 1400, "$6$rounds=1400$anotherlongsalts$POfYwTEok97VWcjxIiSOjiykti.o/pQs.wPvMxQ6Fm7I6IoYN3CmLs66x9t0oSwbtEW7o7UmJEiDwGqd8p4ur1"},
+		{"we have a short salt string but not a short password", "roundstoolow", 10, "$6$rounds=1000$roundstoolow$yjTuW7RnC.d35QcVTFIb6uvh/7IQ1.GFtFN3i/.jwmeWEhzjf4uD/OPCb4jRl6atJGYhLst8IyR6YAtTrriMU1"},
+		{"x", "abcdefgh", 5000, "$6$abcdefgh$D7W7qyozKBT.t6FD3DVYHvADbIO0eSyI4.p20LaEUjro8PqTGYYo/EQcuNjhFbzo9Yg5ir1KIEqFY/yJpgFph0"},
+	}
+
+	for _, tt := range tests {
+		if got := sha512Crypt(tt.pw, tt.salt, tt.rounds); got != tt.want {
+			t.Errorf("sha512Crypt(%q, %q, %d) = %q, want %q", tt.pw, tt.salt, tt.rounds, got, tt.want)
+		}
+	}
+}
+
+func TestNTHash(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pw   string
+		want string
+	}{
+		{"", "31d6cfe0d16ae931b73c59d7e0c089c0"},
+		{"password", "8846f7eaee8fb117ad06bdd830b7586c"},
+	}
+
+	for _, tt := range tests {
+		if got := ntHash(tt.pw); got != tt.want {
+			t.Errorf("ntHash(%q) = %q, want %q", tt.pw, got, tt.want)
+		}
+	}
+}
+
+func TestSSHAHash(t *testing.T) {
+	t.Parallel()
+
+	salt := []byte("12345678")
+	got := sshaHash("secret", salt)
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(got, "{SSHA}"))
+	if err != nil {
+		t.Fatalf("sshaHash() = %q, not base64: %v", got, err)
+	}
+
+	sum := sha1.Sum([]byte("secret12345678"))
+	if want := append(sum[:], salt...); !strings.HasPrefix(got, "{SSHA}") || !bytes.Equal(raw, want) {
+		t.Errorf("sshaHash() = %q, want {SSHA} of %x", got, want)
+	}
+}
+
+func newTestHashCmd(t *testing.T, args ...string) *cobra.Command {
+	t.Helper()
+
+	cmd := &cobra.Command{}
+	addHashFlags(cmd.Flags())
+	if err := cmd.ParseFlags(args); err != nil {
+		t.Fatalf("ParseFlags(%q) error = %v", args, err)
+	}
+
+	return cmd
+}
+
+func TestGetHashFlags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		args    []string
+		wantErr bool
+	}{
+		{nil, false},
+		{[]string{"--hash", "BCRYPT", "--bcrypt_cost", "4"}, false},
+		{[]string{"--hash", "md5"}, true},
+		{[]string{"--hash_only"}, true},
+		{[]string{"--bcrypt_cost", "3"}, true},
+		{[]string{"--argon2_time", "0"}, true},
+		{[]string{"--argon2_threads", "0"}, true},
+		{[]string{"--argon2_threads", "4", "--argon2_memory", "16"}, true},
+		{[]string{"--sha512_rounds", "999"}, true},
+	}
+
+	for _, tt := range tests {
+		if _, err := getHashFlags(newTestHashCmd(t, tt.args...)); (err != nil) != tt.wantErr {
+			t.Errorf("getHashFlags(%q) error = %v, wantErr %t", tt.args, err, tt.wantErr)
+		}
+	}
+}
+
+func TestHashOptionsHash(t *testing.T) {
+	t.Parallel()
+
+	cmd := newTestHashCmd(t, "--bcrypt_cost", "4", "--argon2_time", "1", "--argon2_memory", "64", "--argon2_threads", "1", "--sha512_rounds", "1000")
+	o, err := getHashFlags(cmd)
+	if err != nil {
+		t.Fatalf("getHashFlags() error = %v", err)
+	}
+
+	pw := "correct-horse"
+	tests := []struct {
+		algorithm string
+		check     func(h string) bool
+	}{
+		{hashBcrypt, func(h string) bool {
+			return bcrypt.CompareHashAndPassword([]byte(h), []byte(pw)) == nil
+		}},
+		{hashArgon2id, func(h string) bool {
+			parts := strings.Split(h, "$")
+			if len(parts) != 6 || parts[3] != "m=64,t=1,p=1" {
+				return false
+			}
+
+			salt, _ := base64.RawStdEncoding.DecodeString(parts[4])
+			key := argon2.IDKey([]byte(pw), salt, 1, 64, 1, argon2KeyLength)
+
+			return parts[5] == base64.RawStdEncoding.EncodeToString(key) && parts[2] == fmt.Sprintf("v=%d", argon2.Version)
+		}},
+		{hashSHA512Crypt, func(h string) bool {
+			parts := strings.Split(h, "$")
+			return len(parts) == 5 && sha512Crypt(pw, parts[3], 1000) == h
+		}},
+		{hashSSHA, func(h string) bool {
+			raw, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(h, "{SSHA}"))
+			return len(raw) == sha1.Size+sshaSaltLength && sshaHash(pw, raw[sha1.Size:]) == h
+		}},
+		{hashNT, func(h string) bool { return h == ntHash(pw) }},
+	}
+
+	for _, tt := range tests {
+		o.algorithm = tt.algorithm
+		h, err := o.hash(pw)
+		if err != nil {
+			t.Errorf("hash() with %s error = %v", tt.algorithm, err)
+			continue
+		}
+
+		if !tt.check(h) {
+			t.Errorf("hash() with %s = %q, doesn't verify", tt.algorithm, h)
+		}
+	}
+}
+
+func TestHashOptionsAnnotate(t *testing.T) {
+	t.Parallel()
+
+	pws := []string{"one-two", "three-four"}
+	lines := []string{"one-two  (score)", "three-four  (score)"}
+
+	tests := []struct {
+		o    hashOptions
+		want []string
+	}{
+		{hashOptions{}, lines},
+		{hashOptions{algorithm: hashNT}, []string{lines[0] + "  " + ntHash(pws[0]), lines[1] + "  " + ntHash(pws[1])}},
+		{hashOptions{algorithm: hashNT, only: true}, []string{ntHash(pws[0]), ntHash(pws[1])}},
+	}
+
+	for _, tt := range tests {
+		hashes, err := tt.o.hashAll(pws)
+		if err != nil {
+			t.Fatalf("hashAll(%+v) error = %v", tt.o, err)
+		}
+
+		got := tt.o.annotate(lines, hashes)
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("annotate(%+v) = %q, want %q", tt.o, got, tt.want)
+		}
+	}
+}
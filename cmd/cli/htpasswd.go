@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Constant for the output file flag key
+const outputFileKey string = "output_file"
+
+var htpasswdCmd = &cobra.Command{
+	Use:   "htpasswd FILE USER [USER...]",
+	Short: "Set generated passwords for users in an htpasswd file",
+	Long: `Generate a password for each user and set its bcrypt hash in an Apache or
+nginx htpasswd file, adding users which aren't in it yet. Other entries and
+comments are kept, and the file is replaced atomically, keeping its mode and
+owner. A new file is created readable by everyone, so the web server can read
+it.
+
+The passwords are printed as user:password lines, only when stdout is a
+terminal, or written to --output_file, which is created readable only by you.
+With --encrypt_to they're encrypted to age recipients first, so they can be
+printed anywhere`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runHtpasswdCmd,
+}
+
+func runHtpasswdCmd(cmd *cobra.Command, args []string) error {
+	path, users := args[0], args[1:]
+	if err := validateHtpasswdUsers(users); err != nil {
+		return err
+	}
+
+	outputFile, err := cmd.Flags().GetString(outputFileKey)
+	if err != nil {
+		return fmt.Errorf("failed to get %s flag: %w", outputFileKey, err)
+	}
+
+	force, err := cmd.Flags().GetBool(forceKey)
+	if err != nil {
+		return fmt.Errorf("failed to get %s flag: %w", forceKey, err)
+	}
+
+	recipients, err := getEncryptToFlag(cmd)
+	if err != nil {
+		return err
+	}
+
+	if outputFile == "" && len(recipients) == 0 && !isTerminalOutput(cmd) {
+		return fmt.Errorf(
+			"stdout isn't a terminal, use --%s to save the passwords to a file or --%s to encrypt them",
+			outputFileKey,
+			encryptToKey,
+		)
+	}
+
+	cost, err := getBcryptCostFlag(cmd)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := generateConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to generate config: %w", err)
+	}
+
+	pws, err := generateInBatches(cmd, cfg, len(users))
+	if err != nil {
+		return err
+	}
+
+	if _, warning := evaluatePasswords(pws, false); warning != "" {
+		cmd.PrintErrln(warning)
+		cmd.PrintErrln()
+	}
+
+	hashes := make(map[string]string, len(users))
+	lines := make([]string, len(users))
+	for i, u := range users {
+		h, err := bcrypt.GenerateFromPassword([]byte(pws[i]), cost)
+		if err != nil {
+			return fmt.Errorf("failed to hash password: %w", err)
+		}
+
+		hashes[u] = string(h)
+		lines[i] = u + ":" + pws[i]
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read htpasswd file: %w", err)
+	}
+
+	updated, added := updateHtpasswd(string(current), users, hashes)
+
+	data := []byte(strings.Join(lines, "\n") + "\n")
+	if len(recipients) > 0 {
+		if data, err = encryptLinesToBytes(recipients, lines); err != nil {
+			return fmt.Errorf("failed to encrypt passwords: %w", err)
+		}
+	}
+
+	// the passwords are saved first, so a failure can't leave users with
+	// passwords nobody knows
+	if outputFile != "" {
+		err := writeFileAtomic(outputFile, data, 0o600, force)
+		if errors.Is(err, ErrFileExists) {
+			return fmt.Errorf("%s already exists, use --%s to overwrite it", outputFile, forceKey)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write passwords: %w", err)
+		}
+	}
+
+	if err := replaceFileAtomicKeepingMode(path, []byte(updated), 0o644); err != nil {
+		return fmt.Errorf("failed to write htpasswd file: %w", err)
+	}
+
+	cmd.PrintErrf("Set passwords for %d users in %s, %d of them new\n", len(users), path, added)
+
+	if outputFile != "" {
+		cmd.PrintErrf("Wrote the passwords to %s\n", outputFile)
+		return nil
+	}
+
+	if _, err := cmd.OutOrStdout().Write(data); err != nil {
+		return fmt.Errorf("failed to print passwords: %w", err)
+	}
+
+	return nil
+}
+
+// Returns an error for a user which can't be in an htpasswd file, or which
+// is given twice
+func validateHtpasswdUsers(users []string) error {
+	seen := make(map[string]bool, len(users))
+	for _, u := range users {
+		if u == "" || strings.ContainsFunc(u, func(r rune) bool { return r == ':' || r < ' ' || r == 0x7f }) {
+			return fmt.Errorf("user (%q) must not be empty, or contain colons or control characters", u)
+		}
+
+		if seen[u] {
+			return fmt.Errorf("user (%s) is given more than once", u)
+		}
+		seen[u] = true
+	}
+
+	return nil
+}
+
+// Returns the htpasswd file content with the hashes set for users, replacing
+// their entries, dropping any repeats of them, and adding those missing at
+// the end in order. Returns the number added.
+func updateHtpasswd(content string, users []string, hashes map[string]string) (string, int) {
+	var b strings.Builder
+	done := make(map[string]bool, len(users))
+
+	content = strings.TrimSuffix(content, "\n")
+	if content != "" {
+		for line := range strings.SplitSeq(content, "\n") {
+			user, _, ok := strings.Cut(line, ":")
+			if h, set := hashes[user]; ok && set {
+				if done[user] {
+					continue
+				}
+
+				done[user] = true
+				line = user + ":" + h
+			}
+
+			b.WriteString(line + "\n")
+		}
+	}
+
+	added := 0
+	for _, u := range users {
+		if !done[u] {
+			b.WriteString(u + ":" + hashes[u] + "\n")
+			added++
+		}
+	}
+
+	return b.String(), added
+}
+
+func init() {
+	htpasswdCmd.Flags().String(
+		outputFileKey,
+		"",
+		"file to write the passwords to instead of stdout, created readable only by you, it must not exist unless --force is given",
+	)
+	htpasswdCmd.Flags().Bool(forceKey, false, "overwrite --output_file if it already exists")
+	addBcryptCostFlag(htpasswdCmd.Flags())
+	addEncryptToFlag(htpasswdCmd.Flags())
+
+	addConfigFlags(htpasswdCmd.Flags())
+
+	rootCmd.AddCommand(htpasswdCmd)
+}
@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestUpdateHtpasswd(t *testing.T) {
+	t.Parallel()
+
+	hashes := map[string]string{"alice": "$2a$new-alice", "carol": "$2a$new-carol"}
+	tests := []struct {
+		name      string
+		content   string
+		want      string
+		wantAdded int
+	}{
+		{"new file", "", "alice:$2a$new-alice\ncarol:$2a$new-carol\n", 2},
+		{
+			"keeps others",
+			"# staff\nbob:$apr1$bob\nalice:$2y$old\n\ndave:{SHA}dave",
+			"# staff\nbob:$apr1$bob\nalice:$2a$new-alice\n\ndave:{SHA}dave\ncarol:$2a$new-carol\n",
+			1,
+		},
+		{"drops repeats", "alice:old\ncarol:old\nalice:older\n", "alice:$2a$new-alice\ncarol:$2a$new-carol\n", 0},
+		{"user prefix", "alicia:x\n", "alicia:x\nalice:$2a$new-alice\ncarol:$2a$new-carol\n", 2},
+	}
+
+	for _, tt := range tests {
+		got, added := updateHtpasswd(tt.content, []string{"alice", "carol"}, hashes)
+		if got != tt.want || added != tt.wantAdded {
+			t.Errorf("%s: updateHtpasswd() = %q, %d, want %q, %d", tt.name, got, added, tt.want, tt.wantAdded)
+		}
+	}
+}
+
+func TestValidateHtpasswdUsers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		users   []string
+		wantErr bool
+	}{
+		{[]string{"alice", "bob@example.com"}, false},
+		{[]string{""}, true},
+		{[]string{"a:b"}, true},
+		{[]string{"a\nb"}, true},
+		{[]string{"alice", "alice"}, true},
+	}
+
+	for _, tt := range tests {
+		if err := validateHtpasswdUsers(tt.users); (err != nil) != tt.wantErr {
+			t.Errorf("validateHtpasswdUsers(%q) error = %v, wantErr %t", tt.users, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRunHtpasswdCmdManyUsers(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "htpasswd")
+	outputFile := filepath.Join(dir, "passwords.txt")
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String(outputFileKey, "", "")
+	cmd.Flags().Bool(forceKey, false, "")
+	addBcryptCostFlag(cmd.Flags())
+	addEncryptToFlag(cmd.Flags())
+	addConfigFlags(cmd.Flags())
+	if err := cmd.ParseFlags([]string{"--output_file", outputFile, "--bcrypt_cost", "4"}); err != nil {
+		t.Fatalf("ParseFlags() error = %v", err)
+	}
+	cmd.SetErr(&bytes.Buffer{})
+
+	// more than libpass generates at once
+	users := make([]string, maxPasswordsPerBatch+2)
+	for i := range users {
+		users[i] = fmt.Sprintf("user%d", i)
+	}
+
+	if err := runHtpasswdCmd(cmd, append([]string{path}, users...)); err != nil {
+		t.Fatalf("runHtpasswdCmd() error = %v", err)
+	}
+
+	for _, f := range []string{path, outputFile} {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+
+		if lines := strings.Count(string(data), "\n"); lines != len(users) {
+			t.Errorf("%s has %d lines, want %d", filepath.Base(f), lines, len(users))
+		}
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets")
+
+	if err := writeFileAtomic(path, []byte("one"), 0o600, false); err != nil {
+		t.Fatalf("writeFileAtomic() error = %v", err)
+	}
+
+	if err := writeFileAtomic(path, []byte("two"), 0o600, false); !errors.Is(err, ErrFileExists) {
+		t.Errorf("writeFileAtomic() over an existing file error = %v, want %v", err, ErrFileExists)
+	}
+
+	tests := []struct {
+		name  string
+		write func() error
+		data  string
+		want  fs.FileMode
+	}{
+		{"replacing", func() error { return writeFileAtomic(path, []byte("three"), 0o600, true) }, "three", 0o600},
+		{"keeping the mode", func() error { return replaceFileAtomicKeepingMode(path, []byte("four"), 0o600) }, "four", 0o644},
+	}
+
+	for _, tt := range tests {
+		if err := os.Chmod(path, 0o644); err != nil {
+			t.Fatalf("Chmod() error = %v", err)
+		}
+
+		if err := tt.write(); err != nil {
+			t.Fatalf("%s: write error = %v", tt.name, err)
+		}
+
+		got, err := os.ReadFile(path)
+		if err != nil || string(got) != tt.data {
+			t.Errorf("%s: ReadFile() = %q, %v, want %q", tt.name, got, err, tt.data)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Stat() error = %v", err)
+		}
+
+		if perm := info.Mode().Perm(); perm != tt.want {
+			t.Errorf("%s: mode after replacing a 644 file = %o, want %o", tt.name, perm, tt.want)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("ReadDir() = %v, %v, want only the written file left", entries, err)
+	}
+}
+
+func TestWriteFileExclusive(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "secrets")
+	if err := writeFileExclusive(path, []byte("one"), 0o600); err != nil {
+		t.Fatalf("writeFileExclusive() error = %v", err)
+	}
+
+	if err := writeFileExclusive(path, []byte("two"), 0o600); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("writeFileExclusive() over an existing file error = %v, want %v", err, fs.ErrExist)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil || string(got) != "one" {
+		t.Errorf("ReadFile() = %q, %v, want %q", got, err, "one")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("writeFileExclusive() mode = %o, want 600", perm)
+	}
+}
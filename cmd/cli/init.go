@@ -0,0 +1,670 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/eljamo/libpass/v8/config"
+	"github.com/eljamo/libpass/v8/config/option"
+	"github.com/eljamo/libpass/v8/service"
+	"github.com/spf13/cobra"
+)
+
+// Constant for the force flag key
+const forceKey string = "force"
+
+// Path the config is saved to when none is given
+const defaultInitConfigPath string = "mempass.json"
+
+// Entropy the wizard adds words to reach, when the length limit allows
+const initTargetEntropyBits float64 = 64
+
+// Most words the wizard adds to reach initTargetEntropyBits
+const initMaxNumWords int = 8
+
+// Longest words are only shortened to this length before digits and words
+// are cut, and then to initMinWordLength
+const (
+	initShortWordLength int = 5
+	initMinWordLength   int = 4
+)
+
+// Number of sample passwords shown before saving
+const initNumSamples int = 3
+
+// Devices the password will be typed on
+const (
+	deviceKeyboard string = "keyboard"
+	devicePhone    string = "phone"
+	deviceTV       string = "tv"
+)
+
+var initDevices = []string{deviceKeyboard, devicePhone, deviceTV}
+
+// Symbols on the first symbol page of phone and TV on-screen keyboards
+var easyTypingSymbols = []string{"-", ".", "@", "!", "?", "&", ":", ";", "/", "$"}
+
+var initCmd = &cobra.Command{
+	Use:   "init [path]",
+	Short: "Answer a few questions to create a custom config",
+	Long: `Answer a few questions about where the passwords will be used to create a
+custom config, saved to mempass.json unless a path is given.
+
+It asks for the length limits and symbols the target system allows, and
+whether the passwords will be typed on a phone or TV. From the answers it
+picks a base preset, tunes the word count, padding and separators to fit,
+then shows the entropy and sample passwords before saving. Use the config with
+--custom_config_path`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runInitCmd,
+}
+
+// The answers to the init questions
+type initAnswers struct {
+	// Longest password the target system accepts, 0 for no limit
+	maxLength int
+	// Shortest password the target system accepts, 0 for no limit
+	minLength int
+	// Symbols the target system accepts, nil for any
+	symbols []string
+	// Device the password will be typed on
+	device string
+}
+
+func runInitCmd(cmd *cobra.Command, args []string) error {
+	path := defaultInitConfigPath
+	if len(args) == 1 {
+		path = args[0]
+	}
+
+	force, err := cmd.Flags().GetBool(forceKey)
+	if err != nil {
+		return fmt.Errorf("failed to get %s flag: %w", forceKey, err)
+	}
+
+	// Checked before asking anything, so the answers aren't wasted
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("%s already exists, use --%s to overwrite it", path, forceKey)
+	}
+
+	p := newPrompter(cmd.InOrStdin(), cmd.ErrOrStderr())
+	a, err := askInitQuestions(p)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := planInitConfig(a)
+	if err != nil {
+		return fmt.Errorf("failed to create config: %w", err)
+	}
+
+	if err := printInitSummary(cmd.ErrOrStderr(), cfg, a, service.NewRNGService()); err != nil {
+		return err
+	}
+
+	save, err := p.askYesNo(fmt.Sprintf("Save the config to %s?", path), true)
+	if err != nil {
+		return err
+	}
+
+	if !save {
+		return nil
+	}
+
+	if err := writeInitConfig(path, cfg); err != nil {
+		return err
+	}
+
+	cmd.Printf("Saved the config to %s, use it with --%s %s\n", path, customConfigPathKey, path)
+
+	return nil
+}
+
+// prompter asks questions on w and reads the answers from r, one per line
+type prompter struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+func newPrompter(r io.Reader, w io.Writer) *prompter {
+	return &prompter{bufio.NewReader(r), w}
+}
+
+// Asks a question and returns the trimmed answer, or def for an empty one
+func (p *prompter) ask(question string, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(p.w, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.w, "%s: ", question)
+	}
+
+	line, err := p.r.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", fmt.Errorf("failed to read answer (%w)", err)
+	}
+
+	answer := strings.TrimSpace(line)
+	if answer == "" {
+		return def, nil
+	}
+
+	return answer, nil
+}
+
+// Asks a question until parse accepts the answer
+func askUntilValid[T any](p *prompter, question string, def string, parse func(string) (T, error)) (T, error) {
+	for {
+		answer, err := p.ask(question, def)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+
+		v, err := parse(answer)
+		if err == nil {
+			return v, nil
+		}
+
+		fmt.Fprintf(p.w, "%v\n", err)
+	}
+}
+
+// Asks for a length, 0 for no limit
+func (p *prompter) askLength(question string) (int, error) {
+	return askUntilValid(p, question, "0", func(s string) (int, error) {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not a length, enter a number or 0 for no limit", s)
+		}
+
+		return n, nil
+	})
+}
+
+// Asks a yes or no question
+func (p *prompter) askYesNo(question string, def bool) (bool, error) {
+	d := "n"
+	if def {
+		d = "y"
+	}
+
+	return askUntilValid(p, question+" (y/n)", d, func(s string) (bool, error) {
+		switch strings.ToLower(s) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+
+		return false, fmt.Errorf("%q is not an answer, enter y or n", s)
+	})
+}
+
+// Asks the init questions
+func askInitQuestions(p *prompter) (initAnswers, error) {
+	var a initAnswers
+	var err error
+
+	a.maxLength, err = p.askLength("Longest password the system allows, 0 for no limit")
+	if err != nil {
+		return a, err
+	}
+
+	a.minLength, err = askUntilValid(p, "Shortest password the system allows, 0 for no limit", "0", func(s string) (int, error) {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not a length, enter a number or 0 for no limit", s)
+		}
+
+		if a.maxLength > 0 && n > a.maxLength {
+			return 0, fmt.Errorf("the shortest length can't be over the longest, %d", a.maxLength)
+		}
+
+		return n, nil
+	})
+	if err != nil {
+		return a, err
+	}
+
+	a.symbols, err = askUntilValid(p, "Symbols the system allows: all, none, or list them, e.g. -_.!", "all", parseAllowedSymbols)
+	if err != nil {
+		return a, err
+	}
+
+	a.device, err = askUntilValid(
+		p,
+		fmt.Sprintf("Where will it be typed: %s", strings.Join(initDevices, ", ")),
+		deviceKeyboard,
+		func(s string) (string, error) {
+			if d := strings.ToLower(s); slices.Contains(initDevices, d) {
+				return d, nil
+			}
+
+			return "", fmt.Errorf("%q is not a device, enter one of %s", s, strings.Join(initDevices, ", "))
+		},
+	)
+
+	return a, err
+}
+
+// Parses the allowed symbols answer, nil for all and empty for none
+func parseAllowedSymbols(s string) ([]string, error) {
+	switch strings.ToLower(s) {
+	case "all":
+		return nil, nil
+	case "none":
+		return []string{}, nil
+	}
+
+	var symbols []string
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			continue
+		}
+
+		if !unicode.IsPunct(r) && !unicode.IsSymbol(r) {
+			return nil, fmt.Errorf("%q is not a symbol, enter all, none, or only symbols", string(r))
+		}
+
+		if !slices.Contains(symbols, string(r)) {
+			symbols = append(symbols, string(r))
+		}
+	}
+
+	return symbols, nil
+}
+
+// Returns the base preset for the answers: the web presets for a length
+// limit, otherwise a preset suited to the device
+func chooseInitPreset(a initAnswers) string {
+	switch {
+	case a.maxLength > 0 && a.maxLength <= 16:
+		return option.PresetWeb16
+	case a.maxLength > 0 && a.maxLength <= 32:
+		return option.PresetWeb32
+	case a.device == devicePhone:
+		return option.PresetAppleID
+	case a.device == deviceTV:
+		return option.PresetXKCD
+	}
+
+	return option.PresetDefault
+}
+
+// Creates a config from the base preset tuned to the answers
+func planInitConfig(a initAnswers) (*config.Settings, error) {
+	preset := chooseInitPreset(a)
+	cfg, err := newConfig(preset)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Preset = preset
+	cfg.NumPasswords = initNumSamples
+
+	symbols := a.symbols
+	if a.device != deviceKeyboard {
+		tuneForDevice(cfg, a.device)
+		if easy := intersect(allowedOrAll(symbols), easyTypingSymbols); len(easy) > 0 {
+			symbols = easy
+		}
+	}
+
+	if symbols != nil {
+		restrictSymbols(cfg, symbols)
+	}
+
+	if err := fitMaxLength(cfg, a.maxLength); err != nil {
+		return nil, err
+	}
+
+	if err := strengthen(cfg, a.maxLength); err != nil {
+		return nil, err
+	}
+
+	if err := fitMinLength(cfg, a.minLength, a.maxLength); err != nil {
+		return nil, err
+	}
+
+	// Validate the tuned config the same way a loaded one is
+	return newConfig(option.PresetDefault, settingsToMap(cfg))
+}
+
+// Avoids the shift key and symbol page switches on an on-screen keyboard, a
+// TV remote being the slowest
+func tuneForDevice(cfg *config.Settings, device string) {
+	if device == deviceTV {
+		cfg.CaseTransform = option.CaseTransformLower
+		cfg.PaddingType = option.PaddingTypeNone
+	} else {
+		cfg.CaseTransform = option.CaseTransformSentence
+	}
+}
+
+// Returns the allowed symbols, with nil meaning every symbol
+func allowedOrAll(symbols []string) []string {
+	if symbols == nil {
+		return option.DefaultSpecialCharacters
+	}
+
+	return symbols
+}
+
+// Returns the elements of a which are also in b, in the order of a
+func intersect(a []string, b []string) []string {
+	var out []string
+	for _, s := range a {
+		if slices.Contains(b, s) {
+			out = append(out, s)
+		}
+	}
+
+	return out
+}
+
+// Limits the separator and padding characters to the allowed symbols. With
+// none allowed the words are joined directly and there's no padding.
+func restrictSymbols(cfg *config.Settings, allowed []string) {
+	if len(allowed) == 0 {
+		cfg.SeparatorCharacter = ""
+		cfg.SeparatorAlphabet = []string{}
+		cfg.PaddingCharacter = ""
+		cfg.SymbolAlphabet = []string{}
+		cfg.PaddingType = option.PaddingTypeNone
+		// Capitals mark where each word starts instead
+		if cfg.CaseTransform != option.CaseTransformRandom {
+			cfg.CaseTransform = option.CaseTransformCapitalise
+		}
+
+		return
+	}
+
+	cfg.SeparatorAlphabet = intersect(cfg.SeparatorAlphabet, allowed)
+	if len(cfg.SeparatorAlphabet) == 0 {
+		cfg.SeparatorAlphabet = slices.Clone(allowed)
+	}
+
+	cfg.SymbolAlphabet = intersect(cfg.SymbolAlphabet, allowed)
+	if len(cfg.SymbolAlphabet) == 0 {
+		cfg.SymbolAlphabet = slices.Clone(allowed)
+	}
+
+	if cfg.SeparatorCharacter != option.SeparatorCharacterRandom && !slices.Contains(allowed, cfg.SeparatorCharacter) {
+		cfg.SeparatorCharacter = option.SeparatorCharacterRandom
+	}
+
+	if cfg.PaddingCharacter != option.PaddingCharacterRandom && !slices.Contains(allowed, cfg.PaddingCharacter) {
+		cfg.PaddingCharacter = option.PaddingCharacterRandom
+	}
+}
+
+// Returns the length of the passwords generated from cfg when every word is
+// wordLen characters long
+func passwordLength(cfg *config.Settings, wordLen int) int {
+	numSeparators := cfg.NumWords - 1
+	if cfg.PaddingDigitsBefore > 0 {
+		numSeparators++
+	}
+	if cfg.PaddingDigitsAfter > 0 {
+		numSeparators++
+	}
+
+	sepLen := 1
+	if cfg.SeparatorCharacter != option.SeparatorCharacterRandom {
+		sepLen = utf8.RuneCountInString(cfg.SeparatorCharacter)
+	}
+
+	length := cfg.NumWords*wordLen + numSeparators*sepLen + cfg.PaddingDigitsBefore + cfg.PaddingDigitsAfter
+
+	switch cfg.PaddingType {
+	case option.PaddingTypeFixed:
+		length += cfg.PaddingCharactersBefore + cfg.PaddingCharactersAfter
+	case option.PaddingTypeAdaptive:
+		length = max(length, cfg.PadToLength)
+	}
+
+	return length
+}
+
+// Returns the longest password cfg can generate
+func maxPasswordLength(cfg *config.Settings) int {
+	return passwordLength(cfg, cfg.WordLengthMax)
+}
+
+// Returns the shortest password cfg can generate
+func minPasswordLength(cfg *config.Settings) int {
+	return passwordLength(cfg, cfg.WordLengthMin)
+}
+
+// Ways to shorten a config, least entropy lost first. Each returns false when
+// it can't shorten any further.
+var shortenSteps = []func(cfg *config.Settings) bool{
+	func(cfg *config.Settings) bool {
+		if cfg.PaddingType != option.PaddingTypeFixed || cfg.PaddingCharactersBefore+cfg.PaddingCharactersAfter == 0 {
+			return false
+		}
+
+		if cfg.PaddingCharactersBefore >= cfg.PaddingCharactersAfter {
+			cfg.PaddingCharactersBefore--
+		} else {
+			cfg.PaddingCharactersAfter--
+		}
+
+		return true
+	},
+	func(cfg *config.Settings) bool {
+		if cfg.WordLengthMax <= max(cfg.WordLengthMin, initShortWordLength) {
+			return false
+		}
+
+		cfg.WordLengthMax--
+
+		return true
+	},
+	func(cfg *config.Settings) bool {
+		if cfg.PaddingDigitsBefore+cfg.PaddingDigitsAfter <= 1 {
+			return false
+		}
+
+		if cfg.PaddingDigitsBefore >= cfg.PaddingDigitsAfter {
+			cfg.PaddingDigitsBefore--
+		} else {
+			cfg.PaddingDigitsAfter--
+		}
+
+		return true
+	},
+	func(cfg *config.Settings) bool {
+		if cfg.NumWords <= minNumWords {
+			return false
+		}
+
+		cfg.NumWords--
+
+		return true
+	},
+	func(cfg *config.Settings) bool {
+		if cfg.WordLengthMax <= initMinWordLength {
+			return false
+		}
+
+		cfg.WordLengthMax--
+		cfg.WordLengthMin = min(cfg.WordLengthMin, cfg.WordLengthMax)
+
+		return true
+	},
+}
+
+// Shortens cfg until its longest password fits in maxLength
+func fitMaxLength(cfg *config.Settings, maxLength int) error {
+	if maxLength == 0 {
+		return nil
+	}
+
+	for maxPasswordLength(cfg) > maxLength {
+		i := slices.IndexFunc(shortenSteps, func(step func(cfg *config.Settings) bool) bool {
+			return step(cfg)
+		})
+		if i == -1 {
+			return fmt.Errorf("no memorable password fits in %d characters", maxLength)
+		}
+	}
+
+	return nil
+}
+
+// Most padding digits the wizard adds to reach initTargetEntropyBits
+const initMaxPaddingDigits int = 4
+
+// Ways to strengthen a config, most entropy gained first. Each returns false
+// when it can't strengthen any further.
+var strengthenSteps = []func(cfg *config.Settings) bool{
+	func(cfg *config.Settings) bool {
+		if cfg.NumWords >= initMaxNumWords {
+			return false
+		}
+
+		cfg.NumWords++
+
+		return true
+	},
+	func(cfg *config.Settings) bool {
+		if cfg.PaddingDigitsBefore+cfg.PaddingDigitsAfter >= initMaxPaddingDigits {
+			return false
+		}
+
+		cfg.PaddingDigitsAfter++
+
+		return true
+	},
+}
+
+// Strengthens cfg until it reaches initTargetEntropyBits, as long as its
+// longest password still fits in maxLength
+func strengthen(cfg *config.Settings, maxLength int) error {
+	for {
+		est, err := estimateEntropy(cfg)
+		if err != nil {
+			return err
+		}
+
+		if est.Bits >= initTargetEntropyBits {
+			return nil
+		}
+
+		i := slices.IndexFunc(strengthenSteps, func(step func(cfg *config.Settings) bool) bool {
+			next := *cfg
+			if !step(&next) || (maxLength > 0 && maxPasswordLength(&next) > maxLength) {
+				return false
+			}
+
+			*cfg = next
+
+			return true
+		})
+		if i == -1 {
+			return nil
+		}
+	}
+}
+
+// Lengthens cfg until its shortest password is at least minLength long, by
+// padding to the length when it's padded and there's a symbol to pad with,
+// otherwise by adding words. A config without padding, such as one tuned for
+// a TV, is kept that way.
+func fitMinLength(cfg *config.Settings, minLength int, maxLength int) error {
+	if minPasswordLength(cfg) >= minLength {
+		return nil
+	}
+
+	if cfg.PaddingType != option.PaddingTypeNone && cfg.PaddingCharacter != "" &&
+		(cfg.PaddingCharacter != option.PaddingCharacterRandom || len(cfg.SymbolAlphabet) > 0) {
+		cfg.PaddingType = option.PaddingTypeAdaptive
+		cfg.PadToLength = minLength
+
+		return nil
+	}
+
+	for minPasswordLength(cfg) < minLength {
+		cfg.NumWords++
+		if maxLength > 0 && maxPasswordLength(cfg) > maxLength {
+			return fmt.Errorf("no memorable password without padding fits between %d and %d characters", minLength, maxLength)
+		}
+	}
+
+	return nil
+}
+
+// Prints the base preset, entropy, length and sample passwords of cfg
+func printInitSummary(w io.Writer, cfg *config.Settings, a initAnswers, rngSvc service.RNGService) error {
+	est, err := estimateEntropy(cfg)
+	if err != nil {
+		return err
+	}
+
+	pgs, err := newPasswordGeneratorService(cfg, rngSvc)
+	if err != nil {
+		return fmt.Errorf("failed to create password generator service (%w)", err)
+	}
+
+	pws, err := pgs.Generate()
+	if err != nil {
+		return fmt.Errorf("failed to generate passwords (%w)", err)
+	}
+
+	lines, warning := evaluatePasswords(pws, true)
+
+	fmt.Fprintf(w, "\nBased on the %s preset: %s\n\n", cfg.Preset, option.PresetDescriptionMap[cfg.Preset])
+	fmt.Fprintf(w, "Entropy: %.1f bits\n", est.Bits)
+	fmt.Fprintf(w, "Length: %d to %d characters, typically %d\n", minPasswordLength(cfg), maxPasswordLength(cfg), est.TypicalLength)
+	if est.Bits < initTargetEntropyBits && a.maxLength > 0 {
+		fmt.Fprintf(w, "The %d character limit keeps the entropy under %.0f bits\n", a.maxLength, initTargetEntropyBits)
+	}
+
+	fmt.Fprintln(w, "\nSamples:")
+	for _, l := range lines {
+		fmt.Fprintf(w, "  %s\n", l)
+	}
+
+	if warning != "" {
+		fmt.Fprintf(w, "\n%s\n", warning)
+	}
+
+	fmt.Fprintln(w)
+
+	return nil
+}
+
+// Writes cfg as a custom config with every setting included, so it doesn't
+// depend on the preset's defaults
+func writeInitConfig(path string, cfg *config.Settings) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false) // keep & readable in the symbol alphabet
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(settingsToMap(cfg)); err != nil {
+		return fmt.Errorf("failed to encode config (%w)", err)
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write config (%w)", err)
+	}
+
+	return nil
+}
+
+func init() {
+	initCmd.Flags().Bool(forceKey, false, "overwrite the config file if it already exists")
+
+	rootCmd.AddCommand(initCmd)
+}
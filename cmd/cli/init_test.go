@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/eljamo/libpass/v8/config/option"
+)
+
+func TestParseAllowedSymbols(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		answer  string
+		want    []string
+		wantErr bool
+	}{
+		{"all", nil, false},
+		{"NONE", []string{}, false},
+		{"-_ .-", []string{"-", "_", "."}, false},
+		{"-a", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseAllowedSymbols(tt.answer)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseAllowedSymbols(%q) error = %v, wantErr %t", tt.answer, err, tt.wantErr)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAllowedSymbols(%q) = %q, want %q", tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestAskInitQuestions(t *testing.T) {
+	t.Parallel()
+
+	// an invalid answer is asked again, an empty one takes the default
+	in := strings.NewReader("abc\n20\n30\n10\n-.\nfridge\nTV\n")
+	var out bytes.Buffer
+
+	got, err := askInitQuestions(newPrompter(in, &out))
+	if err != nil {
+		t.Fatalf("askInitQuestions() error = %v", err)
+	}
+
+	want := initAnswers{maxLength: 20, minLength: 10, symbols: []string{"-", "."}, device: deviceTV}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("askInitQuestions() = %+v, want %+v", got, want)
+	}
+
+	for _, msg := range []string{`"abc" is not a length`, "can't be over the longest", `"fridge" is not a device`} {
+		if !strings.Contains(out.String(), msg) {
+			t.Errorf("askInitQuestions() output = %q, want it to contain %q", out.String(), msg)
+		}
+	}
+}
+
+func TestPlanInitConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		answers initAnswers
+	}{
+		{"no limits", initAnswers{device: deviceKeyboard}},
+		{"web16", initAnswers{maxLength: 16, device: deviceKeyboard}},
+		{"max 20 no symbols", initAnswers{maxLength: 20, minLength: 12, symbols: []string{}, device: deviceKeyboard}},
+		{"min 40", initAnswers{minLength: 40, symbols: []string{"#"}, device: deviceKeyboard}},
+		{"phone", initAnswers{maxLength: 32, minLength: 20, symbols: []string{"-", "_", "!"}, device: devicePhone}},
+		{"tv", initAnswers{symbols: []string{"_", "-"}, device: deviceTV}},
+		{"tv min 30", initAnswers{minLength: 30, device: deviceTV}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg, err := planInitConfig(tt.answers)
+			if err != nil {
+				t.Fatalf("planInitConfig() error = %v", err)
+			}
+
+			cfg.NumPasswords = 10
+			pgs, err := newPasswordGeneratorService(cfg, newSeededRNGService(1))
+			if err != nil {
+				t.Fatalf("newPasswordGeneratorService() error = %v", err)
+			}
+
+			pws, err := samplePasswords(pgs, 50)
+			if err != nil {
+				t.Fatalf("samplePasswords() error = %v", err)
+			}
+
+			for _, p := range pws {
+				n := utf8.RuneCountInString(p)
+				if tt.answers.maxLength > 0 && n > tt.answers.maxLength {
+					t.Errorf("password %q is %d characters, want at most %d", p, n, tt.answers.maxLength)
+				}
+
+				if n < tt.answers.minLength {
+					t.Errorf("password %q is %d characters, want at least %d", p, n, tt.answers.minLength)
+				}
+
+				if tt.answers.symbols == nil {
+					continue
+				}
+
+				for _, r := range p {
+					if !isAlphanumeric(r) && !slices.Contains(tt.answers.symbols, string(r)) {
+						t.Errorf("password %q has symbol %q, want only %q", p, r, tt.answers.symbols)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPlanInitConfigKeepsDevicePadding(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := planInitConfig(initAnswers{minLength: 40, device: deviceTV})
+	if err != nil {
+		t.Fatalf("planInitConfig() error = %v", err)
+	}
+
+	if cfg.PaddingType != option.PaddingTypeNone {
+		t.Errorf("planInitConfig() padding type = %s, want the TV's %s", cfg.PaddingType, option.PaddingTypeNone)
+	}
+
+	if n := minPasswordLength(cfg); n < 40 {
+		t.Errorf("planInitConfig() shortest password = %d characters, want at least 40", n)
+	}
+}
+
+func isAlphanumeric(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+func TestPlanInitConfigTooShort(t *testing.T) {
+	t.Parallel()
+
+	if _, err := planInitConfig(initAnswers{maxLength: 8, device: deviceKeyboard}); err == nil {
+		t.Error("planInitConfig() error = nil, want an error for an 8 character limit")
+	}
+}
+
+func TestChooseInitPreset(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		answers initAnswers
+		want    string
+	}{
+		{initAnswers{maxLength: 16, device: devicePhone}, option.PresetWeb16},
+		{initAnswers{maxLength: 32}, option.PresetWeb32},
+		{initAnswers{device: devicePhone}, option.PresetAppleID},
+		{initAnswers{device: deviceTV}, option.PresetXKCD},
+		{initAnswers{maxLength: 64, device: deviceKeyboard}, option.PresetDefault},
+	}
+
+	for _, tt := range tests {
+		if got := chooseInitPreset(tt.answers); got != tt.want {
+			t.Errorf("chooseInitPreset(%+v) = %q, want %q", tt.answers, got, tt.want)
+		}
+	}
+}
+
+func TestWriteInitConfigRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := planInitConfig(initAnswers{maxLength: 24, symbols: []string{}, device: deviceKeyboard})
+	if err != nil {
+		t.Fatalf("planInitConfig() error = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "mempass.json")
+	if err := writeInitConfig(path, cfg); err != nil {
+		t.Fatalf("writeInitConfig() error = %v", err)
+	}
+
+	got, err := resolveConfigSource(path)
+	if err != nil {
+		t.Fatalf("resolveConfigSource() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, cfg) {
+		t.Errorf("resolveConfigSource() = %+v, want %+v", got, cfg)
+	}
+}
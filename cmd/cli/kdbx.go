@@ -0,0 +1,347 @@
+package cli
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20"
+)
+
+// KDBX 4 file signatures and version
+const (
+	kdbxSignature1 uint32 = 0x9AA2D903
+	kdbxSignature2 uint32 = 0xB54BFB67
+	kdbxVersion4   uint32 = 0x00040000
+)
+
+// KDBX 4 outer header field IDs
+const (
+	kdbxEndOfHeader      byte = 0
+	kdbxCipherID         byte = 2
+	kdbxCompressionFlags byte = 3
+	kdbxMasterSeed       byte = 4
+	kdbxEncryptionIV     byte = 7
+	kdbxKDFParameters    byte = 11
+)
+
+// KDBX 4 inner header field IDs
+const (
+	kdbxInnerEndOfHeader     byte = 0
+	kdbxInnerRandomStreamID  byte = 1
+	kdbxInnerRandomStreamKey byte = 2
+)
+
+// KDBX variant dictionary value types
+const (
+	kdbxVariantUInt32    byte = 0x04
+	kdbxVariantUInt64    byte = 0x05
+	kdbxVariantByteArray byte = 0x42
+)
+
+const (
+	kdbxVariantDictionaryVersion uint16 = 0x0100
+	kdbxGzipCompression          uint32 = 1
+	kdbxChaCha20Stream           uint32 = 3
+	kdbxArgon2Version            uint32 = 0x13
+	// Size of the HMAC protected blocks the payload is split into
+	kdbxBlockSize int = 1 << 20
+)
+
+// UUIDs of AES-256-CBC and the Argon2id KDF
+var (
+	kdbxAESCipherUUID = []byte{0x31, 0xc1, 0xf2, 0xe6, 0xbf, 0x71, 0x43, 0x50, 0xbe, 0x58, 0x05, 0x21, 0x6a, 0xfc, 0x5a, 0xff}
+	kdbxArgon2idUUID  = []byte{0x9e, 0x29, 0x8b, 0x19, 0x56, 0xdb, 0x47, 0x73, 0xb2, 0x3d, 0xfc, 0x3e, 0xc6, 0xf0, 0xa1, 0xe6}
+)
+
+// Seconds from 0001-01-01, when KDBX 4 times start, to the Unix epoch
+const kdbxEpochOffset int64 = 62135596800
+
+// kdbxKDF is the cost of the Argon2id key derivation of a KDBX database
+type kdbxKDF struct {
+	iterations uint64
+	// Memory in bytes, a multiple of 1 KiB
+	memory      uint64
+	parallelism uint32
+}
+
+// About a second on a laptop, like KeePassXC's default
+var defaultKdbxKDF = kdbxKDF{iterations: 10, memory: 64 << 20, parallelism: 2}
+
+// Returns the KDBX composite key of a master password, when there is one, and
+// the key from a key file, when there is one
+func kdbxCompositeKey(password []byte, hasPassword bool, keyFileKey []byte) []byte {
+	h := sha256.New()
+	if hasPassword {
+		pw := sha256.Sum256(password)
+		h.Write(pw[:])
+	}
+	h.Write(keyFileKey)
+
+	return h.Sum(nil)
+}
+
+// Returns the 32 byte key in a KeePass key file: an XML key file, 32 raw
+// bytes, 64 hex digits, or else the SHA-256 of any other file
+func kdbxKeyFileKey(data []byte) ([]byte, error) {
+	var kf struct {
+		XMLName xml.Name `xml:"KeyFile"`
+		Version string   `xml:"Meta>Version"`
+		Data    struct {
+			Hash string `xml:"Hash,attr"`
+			Text string `xml:",chardata"`
+		} `xml:"Key>Data"`
+	}
+
+	if xml.Unmarshal(data, &kf) == nil {
+		text := strings.Join(strings.Fields(kf.Data.Text), "")
+		switch {
+		case strings.HasPrefix(kf.Version, "1."):
+			key, err := base64.StdEncoding.DecodeString(text)
+			if err != nil || len(key) != 32 {
+				return nil, errors.New("key file 1.0 data isn't 32 bytes of base64")
+			}
+
+			return key, nil
+		case strings.HasPrefix(kf.Version, "2."):
+			key, err := hex.DecodeString(text)
+			if err != nil || len(key) != 32 {
+				return nil, errors.New("key file 2.0 data isn't 32 bytes of hex")
+			}
+
+			sum := sha256.Sum256(key)
+			if kf.Data.Hash != "" && !strings.EqualFold(kf.Data.Hash, hex.EncodeToString(sum[:4])) {
+				return nil, errors.New("key file 2.0 data doesn't match its hash")
+			}
+
+			return key, nil
+		default:
+			return nil, fmt.Errorf("key file version (%s) isn't supported", kf.Version)
+		}
+	}
+
+	if len(data) == 32 {
+		return data, nil
+	}
+
+	if len(data) == 64 {
+		if key, err := hex.DecodeString(string(data)); err == nil {
+			return key, nil
+		}
+	}
+
+	sum := sha256.Sum256(data)
+
+	return sum[:], nil
+}
+
+// Returns a new KDBX 4 database, encrypted with AES-256 under the composite
+// key, holding an entry for each password in its root group. Its seeds, keys
+// and UUIDs are read from random, crypto/rand.Reader outside of tests.
+func writeKdbx(
+	name string,
+	entries []passwordEntry,
+	pws []string,
+	compositeKey []byte,
+	kdf kdbxKDF,
+	now time.Time,
+	random io.Reader,
+) ([]byte, error) {
+	// read in one go, so a short read fails before anything is written
+	seeds := make([]byte, 32+aes.BlockSize+32+64)
+	if _, err := io.ReadFull(random, seeds); err != nil {
+		return nil, fmt.Errorf("failed to read random bytes (%w)", err)
+	}
+	masterSeed, iv, salt, streamKey := seeds[:32], seeds[32:48], seeds[48:80], seeds[80:]
+
+	var header bytes.Buffer
+	for _, v := range []uint32{kdbxSignature1, kdbxSignature2, kdbxVersion4} {
+		header.Write(binary.LittleEndian.AppendUint32(nil, v))
+	}
+	writeKdbxField(&header, kdbxCipherID, kdbxAESCipherUUID)
+	writeKdbxField(&header, kdbxCompressionFlags, binary.LittleEndian.AppendUint32(nil, kdbxGzipCompression))
+	writeKdbxField(&header, kdbxMasterSeed, masterSeed)
+	writeKdbxField(&header, kdbxEncryptionIV, iv)
+	writeKdbxField(&header, kdbxKDFParameters, kdbxKDFDictionary(kdf, salt))
+	writeKdbxField(&header, kdbxEndOfHeader, []byte("\r\n\r\n"))
+
+	transformed := argon2.IDKey(compositeKey, salt, uint32(kdf.iterations), uint32(kdf.memory/1024), uint8(kdf.parallelism), 32)
+	encKey := sha256.Sum256(append(bytes.Clone(masterSeed), transformed...))
+	hmacKey := sha512.Sum512(append(append(bytes.Clone(masterSeed), transformed...), 0x01))
+
+	doc, err := kdbxXML(name, entries, pws, streamKey, now, random)
+	if err != nil {
+		return nil, err
+	}
+
+	var plain bytes.Buffer
+	writeKdbxInnerField(&plain, kdbxInnerRandomStreamID, binary.LittleEndian.AppendUint32(nil, kdbxChaCha20Stream))
+	writeKdbxInnerField(&plain, kdbxInnerRandomStreamKey, streamKey)
+	writeKdbxInnerField(&plain, kdbxInnerEndOfHeader, nil)
+	plain.Write(doc)
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(plain.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to compress database (%w)", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress database (%w)", err)
+	}
+
+	block, err := aes.NewCipher(encKey[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher (%w)", err)
+	}
+
+	padded := pkcs7Pad(compressed.Bytes(), aes.BlockSize)
+	encrypted := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, padded)
+
+	out := bytes.NewBuffer(bytes.Clone(header.Bytes()))
+	headerHash := sha256.Sum256(header.Bytes())
+	out.Write(headerHash[:])
+	out.Write(kdbxHMAC(hmacKey[:], math.MaxUint64, header.Bytes()))
+
+	for i := uint64(0); ; i++ {
+		n := min(len(encrypted), kdbxBlockSize)
+		data := encrypted[:n]
+		encrypted = encrypted[n:]
+
+		size := binary.LittleEndian.AppendUint32(nil, uint32(n))
+		msg := append(binary.LittleEndian.AppendUint64(nil, i), size...)
+		out.Write(kdbxHMAC(hmacKey[:], i, append(msg, data...)))
+		out.Write(size)
+		out.Write(data)
+
+		// an empty block ends the payload
+		if n == 0 {
+			break
+		}
+	}
+
+	return out.Bytes(), nil
+}
+
+// Writes an outer header field, its ID, size and data
+func writeKdbxField(buf *bytes.Buffer, id byte, data []byte) {
+	buf.WriteByte(id)
+	buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(data))))
+	buf.Write(data)
+}
+
+// Writes an inner header field, its ID, size and data
+func writeKdbxInnerField(buf *bytes.Buffer, id byte, data []byte) {
+	buf.WriteByte(id)
+	buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(data))))
+	buf.Write(data)
+}
+
+// Returns the Argon2id parameters as a KDBX variant dictionary
+func kdbxKDFDictionary(kdf kdbxKDF, salt []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(binary.LittleEndian.AppendUint16(nil, kdbxVariantDictionaryVersion))
+
+	item := func(typ byte, key string, value []byte) {
+		buf.WriteByte(typ)
+		buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(key))))
+		buf.WriteString(key)
+		buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(value))))
+		buf.Write(value)
+	}
+
+	item(kdbxVariantByteArray, "$UUID", kdbxArgon2idUUID)
+	item(kdbxVariantByteArray, "S", salt)
+	item(kdbxVariantUInt32, "P", binary.LittleEndian.AppendUint32(nil, kdf.parallelism))
+	item(kdbxVariantUInt64, "M", binary.LittleEndian.AppendUint64(nil, kdf.memory))
+	item(kdbxVariantUInt64, "I", binary.LittleEndian.AppendUint64(nil, kdf.iterations))
+	item(kdbxVariantUInt32, "V", binary.LittleEndian.AppendUint32(nil, kdbxArgon2Version))
+	buf.WriteByte(0)
+
+	return buf.Bytes()
+}
+
+// Returns the HMAC-SHA-256 of data with the key for a block index, the header
+// using the maximum index
+func kdbxHMAC(hmacKey []byte, index uint64, data []byte) []byte {
+	key := sha512.Sum512(append(binary.LittleEndian.AppendUint64(nil, index), hmacKey...))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write(data)
+
+	return mac.Sum(nil)
+}
+
+// Returns the ChaCha20 inner random stream protected values are encrypted
+// with, in the order they appear in the XML
+func kdbxInnerStream(streamKey []byte) (*chacha20.Cipher, error) {
+	h := sha512.Sum512(streamKey)
+	return chacha20.NewUnauthenticatedCipher(h[:32], h[32:44])
+}
+
+// Returns the database XML, with the passwords protected by the inner random
+// stream and UUIDs read from random
+func kdbxXML(name string, entries []passwordEntry, pws []string, streamKey []byte, now time.Time, random io.Reader) ([]byte, error) {
+	stream, err := kdbxInnerStream(streamKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create inner random stream (%w)", err)
+	}
+
+	uuids := make([]byte, 16*(len(entries)+1))
+	if _, err := io.ReadFull(random, uuids); err != nil {
+		return nil, fmt.Errorf("failed to read random bytes (%w)", err)
+	}
+	uuid := func(i int) string {
+		return base64.StdEncoding.EncodeToString(uuids[16*i : 16*(i+1)])
+	}
+
+	t := base64.StdEncoding.EncodeToString(binary.LittleEndian.AppendUint64(nil, uint64(now.Unix()+kdbxEpochOffset)))
+	times := &keePassTimes{Creation: t, Modification: t, Access: t, Expiry: t, Expires: "False", Moved: t}
+
+	f := keePassFile{Generator: "mempass", DatabaseName: name, Group: keePassGroup{UUID: uuid(0), Name: name}}
+	for i, e := range entries {
+		pw := []byte(pws[i])
+		stream.XORKeyStream(pw, pw)
+
+		f.Group.Entries = append(f.Group.Entries, keePassEntry{
+			UUID:  uuid(i + 1),
+			Times: times,
+			Strings: []keePassString{
+				{"Title", keePassValue{Text: e.Title}},
+				{"UserName", keePassValue{Text: e.Username}},
+				{"Password", keePassValue{Protected: "True", Text: base64.StdEncoding.EncodeToString(pw)}},
+				{"URL", keePassValue{Text: e.URL}},
+			},
+		})
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="utf-8" standalone="yes"?>` + "\n")
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "\t")
+	if err := enc.Encode(f); err != nil {
+		return nil, fmt.Errorf("failed to encode database XML (%w)", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Returns data padded to a multiple of the block size with PKCS #7
+func pkcs7Pad(data []byte, blockSize int) []byte {
+	n := blockSize - len(data)%blockSize
+	return append(bytes.Clone(data), bytes.Repeat([]byte{byte(n)}, n)...)
+}
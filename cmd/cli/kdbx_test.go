@@ -0,0 +1,430 @@
+package cli
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/argon2"
+)
+
+// Cheap enough for tests
+var testKdbxKDF = kdbxKDF{iterations: 1, memory: 1 << 20, parallelism: 1}
+
+// kdbxReader reads a KDBX 4 database, independently of the writer, checking
+// its hashes and HMACs along the way
+type kdbxReader struct {
+	t    *testing.T
+	data []byte
+	pos  int
+}
+
+func (r *kdbxReader) next(n int) []byte {
+	r.t.Helper()
+
+	if r.pos+n > len(r.data) {
+		r.t.Fatalf("database is truncated at %d, wanted %d more bytes", r.pos, n)
+	}
+
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+
+	return b
+}
+
+func (r *kdbxReader) uint32() uint32 {
+	return binary.LittleEndian.Uint32(r.next(4))
+}
+
+// Returns the fields of a variant dictionary
+func readKdbxVariantDictionary(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+
+	r := &kdbxReader{t: t, data: data}
+	if v := binary.LittleEndian.Uint16(r.next(2)); v != kdbxVariantDictionaryVersion {
+		t.Fatalf("variant dictionary version = %#x, want %#x", v, kdbxVariantDictionaryVersion)
+	}
+
+	fields := map[string][]byte{}
+	for r.next(1)[0] != 0 {
+		key := string(r.next(int(r.uint32())))
+		fields[key] = r.next(int(r.uint32()))
+	}
+
+	return fields
+}
+
+// Returns the XML of a KDBX 4 database, with its protected values decrypted
+func readKdbx(t *testing.T, data, compositeKey []byte) (keePassFile, error) {
+	t.Helper()
+
+	r := &kdbxReader{t: t, data: data}
+	if s1, s2, v := r.uint32(), r.uint32(), r.uint32(); s1 != kdbxSignature1 || s2 != kdbxSignature2 || v != kdbxVersion4 {
+		t.Fatalf("signatures and version = %#x %#x %#x, want KDBX 4", s1, s2, v)
+	}
+
+	fields := map[byte][]byte{}
+	for {
+		id := r.next(1)[0]
+		fields[id] = r.next(int(r.uint32()))
+		if id == kdbxEndOfHeader {
+			break
+		}
+	}
+	header := data[:r.pos]
+
+	if sum := sha256.Sum256(header); !bytes.Equal(r.next(32), sum[:]) {
+		t.Fatalf("header SHA-256 doesn't match")
+	}
+
+	if !bytes.Equal(fields[kdbxCipherID], kdbxAESCipherUUID) {
+		t.Fatalf("cipher = %x, want AES-256", fields[kdbxCipherID])
+	}
+
+	kdf := readKdbxVariantDictionary(t, fields[kdbxKDFParameters])
+	if !bytes.Equal(kdf["$UUID"], kdbxArgon2idUUID) {
+		t.Fatalf("KDF = %x, want Argon2id", kdf["$UUID"])
+	}
+
+	transformed := argon2.IDKey(
+		compositeKey,
+		kdf["S"],
+		uint32(binary.LittleEndian.Uint64(kdf["I"])),
+		uint32(binary.LittleEndian.Uint64(kdf["M"])/1024),
+		uint8(binary.LittleEndian.Uint32(kdf["P"])),
+		32,
+	)
+
+	seed := fields[kdbxMasterSeed]
+	encKey := sha256.Sum256(append(bytes.Clone(seed), transformed...))
+	hmacKey := sha512.Sum512(append(append(bytes.Clone(seed), transformed...), 1))
+
+	mac := func(index uint64, data []byte) []byte {
+		k := sha512.Sum512(append(binary.LittleEndian.AppendUint64(nil, index), hmacKey[:]...))
+		h := hmac.New(sha256.New, k[:])
+		h.Write(data)
+		return h.Sum(nil)
+	}
+
+	if !hmac.Equal(r.next(32), mac(math.MaxUint64, header)) {
+		return keePassFile{}, fmt.Errorf("header HMAC doesn't match, wrong key")
+	}
+
+	var encrypted []byte
+	for i := uint64(0); ; i++ {
+		sum := r.next(32)
+		size := r.next(4)
+		block := r.next(int(binary.LittleEndian.Uint32(size)))
+		msg := append(append(binary.LittleEndian.AppendUint64(nil, i), size...), block...)
+		if !hmac.Equal(sum, mac(i, msg)) {
+			t.Fatalf("block %d HMAC doesn't match", i)
+		}
+
+		if len(block) == 0 {
+			break
+		}
+		encrypted = append(encrypted, block...)
+	}
+
+	if r.pos != len(data) {
+		t.Fatalf("database has %d bytes after its last block", len(data)-r.pos)
+	}
+
+	c, err := aes.NewCipher(encKey[:])
+	if err != nil {
+		t.Fatalf("NewCipher() error = %v", err)
+	}
+	plain := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(c, fields[kdbxEncryptionIV]).CryptBlocks(plain, encrypted)
+	plain = plain[:len(plain)-int(plain[len(plain)-1])]
+
+	zr, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		t.Fatalf("gzip.NewReader() error = %v", err)
+	}
+	inner, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("gunzip error = %v", err)
+	}
+
+	ir := &kdbxReader{t: t, data: inner}
+	innerFields := map[byte][]byte{}
+	for {
+		id := ir.next(1)[0]
+		innerFields[id] = ir.next(int(ir.uint32()))
+		if id == kdbxInnerEndOfHeader {
+			break
+		}
+	}
+
+	if id := binary.LittleEndian.Uint32(innerFields[kdbxInnerRandomStreamID]); id != kdbxChaCha20Stream {
+		t.Fatalf("inner random stream = %d, want ChaCha20", id)
+	}
+
+	var f keePassFile
+	if err := xml.Unmarshal(inner[ir.pos:], &f); err != nil {
+		t.Fatalf("xml.Unmarshal() error = %v", err)
+	}
+
+	stream, err := kdbxInnerStream(innerFields[kdbxInnerRandomStreamKey])
+	if err != nil {
+		t.Fatalf("kdbxInnerStream() error = %v", err)
+	}
+
+	for _, e := range f.Group.Entries {
+		for i, s := range e.Strings {
+			if s.Value.Protected != "True" {
+				continue
+			}
+
+			v, err := base64.StdEncoding.DecodeString(s.Value.Text)
+			if err != nil {
+				t.Fatalf("protected value %q isn't base64", s.Value.Text)
+			}
+			stream.XORKeyStream(v, v)
+			e.Strings[i].Value.Text = string(v)
+		}
+	}
+
+	return f, nil
+}
+
+func TestWriteKdbx(t *testing.T) {
+	t.Parallel()
+
+	entries := []passwordEntry{
+		{"GitHub", "alice", "https://github.com"},
+		{`Acme "VPN" <&>`, "alice@acme.example", ""},
+	}
+	pws := []string{"one-TWO-3!", "pässwörd <&>"}
+	key := kdbxCompositeKey([]byte("master"), true, nil)
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	data, err := writeKdbx("team", entries, pws, key, testKdbxKDF, now, rand.Reader)
+	if err != nil {
+		t.Fatalf("writeKdbx() error = %v", err)
+	}
+
+	if bytes.Contains(data, []byte("GitHub")) {
+		t.Errorf("writeKdbx() has plaintext in it")
+	}
+
+	f, err := readKdbx(t, data, key)
+	if err != nil {
+		t.Fatalf("readKdbx() error = %v", err)
+	}
+
+	if f.DatabaseName != "team" || f.Group.Name != "team" || len(f.Group.Entries) != len(entries) {
+		t.Fatalf("readKdbx() = %+v, want database team with %d entries", f, len(entries))
+	}
+
+	for i, e := range f.Group.Entries {
+		got := map[string]string{}
+		for _, s := range e.Strings {
+			got[s.Key] = s.Value.Text
+		}
+
+		want := map[string]string{"Title": entries[i].Title, "UserName": entries[i].Username, "Password": pws[i], "URL": entries[i].URL}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("entry %d = %q, want %q", i, got, want)
+		}
+
+		if created, err := base64.StdEncoding.DecodeString(e.Times.Creation); err != nil ||
+			int64(binary.LittleEndian.Uint64(created))-kdbxEpochOffset != now.Unix() {
+			t.Errorf("entry %d creation time = %q, want %v", i, e.Times.Creation, now)
+		}
+	}
+
+	if _, err := readKdbx(t, data, kdbxCompositeKey([]byte("wrong"), true, nil)); err == nil {
+		t.Errorf("readKdbx() with the wrong key error = nil, want one")
+	}
+}
+
+// Database written by writeKdbx from the inputs of TestWriteKdbxPinned, with
+// the master password "mempass". Whenever writeKdbx changes its output, the
+// new fixture must be opened in KeePassXC before replacing this one, as
+// readKdbx could share a mistake with the writer.
+var pinnedKdbxPath = filepath.Join("testdata", "kdbx", "pinned.kdbx")
+
+// Returns the database pinned by pinnedKdbxPath, written with fixed seeds,
+// keys and UUIDs
+func writePinnedKdbx(t *testing.T) []byte {
+	t.Helper()
+
+	random := make([]byte, 256)
+	for i := range random {
+		random[i] = byte(i)
+	}
+
+	entries := []passwordEntry{{"GitHub", "alice", "https://github.com"}, {"Acme VPN", "bob", ""}}
+	pws := []string{"one-TWO-3!", "pässwörd"}
+	key := kdbxCompositeKey([]byte("mempass"), true, nil)
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	data, err := writeKdbx("pinned", entries, pws, key, testKdbxKDF, now, bytes.NewReader(random))
+	if err != nil {
+		t.Fatalf("writeKdbx() error = %v", err)
+	}
+
+	return data
+}
+
+func TestWriteKdbxPinned(t *testing.T) {
+	t.Parallel()
+
+	want, err := os.ReadFile(pinnedKdbxPath)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	if got := writePinnedKdbx(t); !bytes.Equal(got, want) {
+		t.Errorf("writeKdbx() = %x, want the %d bytes of %s", got, len(want), pinnedKdbxPath)
+	}
+
+	f, err := readKdbx(t, want, kdbxCompositeKey([]byte("mempass"), true, nil))
+	if err != nil {
+		t.Fatalf("readKdbx() error = %v", err)
+	}
+
+	if len(f.Group.Entries) != 2 {
+		t.Errorf("readKdbx() = %d entries, want 2", len(f.Group.Entries))
+	}
+}
+
+func TestKdbxCompositeKey(t *testing.T) {
+	t.Parallel()
+
+	fileKey := bytes.Repeat([]byte{7}, 32)
+	pw := sha256.Sum256([]byte("master"))
+
+	tests := []struct {
+		name        string
+		password    []byte
+		hasPassword bool
+		fileKey     []byte
+		want        [32]byte
+	}{
+		{"password", []byte("master"), true, nil, sha256.Sum256(pw[:])},
+		{"key file", nil, false, fileKey, sha256.Sum256(fileKey)},
+		{"both", []byte("master"), true, fileKey, sha256.Sum256(append(pw[:], fileKey...))},
+		{"empty password", nil, true, nil, func() [32]byte { e := sha256.Sum256(nil); return sha256.Sum256(e[:]) }()},
+	}
+
+	for _, tt := range tests {
+		if got := kdbxCompositeKey(tt.password, tt.hasPassword, tt.fileKey); !bytes.Equal(got, tt.want[:]) {
+			t.Errorf("%s: kdbxCompositeKey() = %x, want %x", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestKdbxKeyFileKey(t *testing.T) {
+	t.Parallel()
+
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	hexKey := strings.ToUpper(hex.EncodeToString(key))
+	sum := sha256.Sum256(key)
+	other := sha256.Sum256([]byte("any file at all"))
+
+	v2 := func(hash string) string {
+		return `<?xml version="1.0" encoding="utf-8"?>
+<KeyFile>
+	<Meta>
+		<Version>2.0</Version>
+	</Meta>
+	<Key>
+		<Data Hash="` + hash + `">
+			` + hexKey[:32] + `
+			` + hexKey[32:] + `
+		</Data>
+	</Key>
+</KeyFile>`
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		want    []byte
+		wantErr bool
+	}{
+		{"xml 2.0", v2(strings.ToUpper(hex.EncodeToString(sum[:4]))), key, false},
+		{"xml 2.0 bad hash", v2("00000000"), nil, true},
+		{"xml 1.0", "<KeyFile><Meta><Version>1.00</Version></Meta><Key><Data>" +
+			base64.StdEncoding.EncodeToString(key) + "</Data></Key></KeyFile>", key, false},
+		{"xml 3.0", "<KeyFile><Meta><Version>3.0</Version></Meta></KeyFile>", nil, true},
+		{"raw", string(key), key, false},
+		{"hex", hexKey, key, false},
+		{"other", "any file at all", other[:], false},
+	}
+
+	for _, tt := range tests {
+		got, err := kdbxKeyFileKey([]byte(tt.data))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: kdbxKeyFileKey() error = %v, wantErr %t", tt.name, err, tt.wantErr)
+		}
+
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: kdbxKeyFileKey() = %x, want %x", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWriteKdbxManyEntries(t *testing.T) {
+	t.Parallel()
+
+	cmd := &cobra.Command{}
+	addConfigFlags(cmd.Flags())
+	cmd.SetErr(&bytes.Buffer{})
+
+	// more than libpass generates at once
+	entries := make([]passwordEntry, maxPasswordsPerBatch+2)
+	for i := range entries {
+		entries[i] = passwordEntry{Title: fmt.Sprintf("Site %d", i)}
+	}
+
+	pws, err := generatePasswords(cmd, len(entries))
+	if err != nil {
+		t.Fatalf("generatePasswords() error = %v", err)
+	}
+
+	key := kdbxCompositeKey([]byte("master"), true, nil)
+	data, err := writeKdbx("team", entries, pws, key, testKdbxKDF, time.Now(), rand.Reader)
+	if err != nil {
+		t.Fatalf("writeKdbx() error = %v", err)
+	}
+
+	f, err := readKdbx(t, data, key)
+	if err != nil {
+		t.Fatalf("readKdbx() error = %v", err)
+	}
+
+	if len(f.Group.Entries) != len(entries) {
+		t.Fatalf("readKdbx() has %d entries, want %d", len(f.Group.Entries), len(entries))
+	}
+
+	for i, e := range f.Group.Entries {
+		if e.Strings[2].Key != "Password" || e.Strings[2].Value.Text != pws[i] || pws[i] == "" {
+			t.Errorf("entry %d password = %+v, want %q", i, e.Strings[2], pws[i])
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// Environment variables set by systemd for socket activated services, see
+// sd_listen_fds(3)
+const (
+	listenPIDEnv     string = "LISTEN_PID"
+	listenFDsEnv     string = "LISTEN_FDS"
+	listenFDNamesEnv string = "LISTEN_FDNAMES"
+)
+
+// The first file descriptor passed by systemd, after stdin, stdout and stderr
+const listenFDsStart int = 3
+
+// Umask applied while creating a Unix socket, so it's only accessible by its
+// owner until its mode is set
+const unixSocketUmask int = 0o177
+
+// Returns the listeners to serve on: the sockets passed by systemd if the
+// process was socket activated, otherwise the Unix socket if one is set,
+// otherwise the TCP address
+func (s *serverSettings) listeners() ([]net.Listener, error) {
+	lns, err := systemdListeners(os.Getenv, os.Getpid())
+	if err != nil {
+		return nil, err
+	}
+
+	if lns != nil {
+		return lns, nil
+	}
+
+	if s.UnixSocket != "" {
+		ln, err := s.listenUnix()
+		if err != nil {
+			return nil, err
+		}
+
+		return []net.Listener{ln}, nil
+	}
+
+	ln, err := net.Listen("tcp", s.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s (%w)", s.Address, err)
+	}
+
+	return []net.Listener{ln}, nil
+}
+
+// Returns the number of sockets systemd passed to the process, 0 if it wasn't
+// socket activated or they were meant for another process
+func listenFDCount(getenv func(string) string, pid int) (int, error) {
+	listenPID := getenv(listenPIDEnv)
+	if listenPID == "" {
+		return 0, nil
+	}
+
+	if n, err := strconv.Atoi(listenPID); err != nil || n != pid {
+		return 0, nil
+	}
+
+	count, err := strconv.Atoi(getenv(listenFDsEnv))
+	if err != nil || count < 0 {
+		return 0, fmt.Errorf("invalid %s %q", listenFDsEnv, getenv(listenFDsEnv))
+	}
+
+	return count, nil
+}
+
+// Returns listeners for the sockets systemd passed to the process, or nil if
+// it wasn't socket activated. The environment variables are cleared so child
+// processes don't inherit them.
+func systemdListeners(getenv func(string) string, pid int) ([]net.Listener, error) {
+	count, err := listenFDCount(getenv, pid)
+	if err != nil || count == 0 {
+		return nil, err
+	}
+
+	names := strings.Split(getenv(listenFDNamesEnv), ":")
+	for _, env := range []string{listenPIDEnv, listenFDsEnv, listenFDNamesEnv} {
+		_ = os.Unsetenv(env) // only fails for an invalid name
+	}
+
+	lns := make([]net.Listener, 0, count)
+	for i := range count {
+		fd := listenFDsStart + i
+		closeOnExec(fd)
+
+		name := fmt.Sprintf("LISTEN_FD_%d", fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(fd), name)
+		ln, err := net.FileListener(f)
+		f.Close() // the listener holds its own copy of the fd
+		if err != nil {
+			for _, l := range lns {
+				l.Close()
+			}
+
+			return nil, fmt.Errorf("systemd socket %s is not a listening socket (%w)", name, err)
+		}
+
+		lns = append(lns, ln)
+	}
+
+	return lns, nil
+}
+
+// Creates the Unix socket with its mode, owner and group set. A stale socket
+// left at the path is replaced, anything else there is an error.
+func (s *serverSettings) listenUnix() (net.Listener, error) {
+	mode, err := parseUnixSocketMode(s.UnixSocketMode)
+	if err != nil {
+		return nil, err
+	}
+
+	uid, err := lookupUID(s.UnixSocketOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	gid, err := lookupGID(s.UnixSocketGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := removeStaleSocket(s.UnixSocket); err != nil {
+		return nil, err
+	}
+
+	var ln net.Listener
+	err = withUmask(unixSocketUmask, func() error {
+		var err error
+		ln, err = net.Listen("unix", s.UnixSocket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s (%w)", s.UnixSocket, err)
+	}
+
+	if uid != -1 || gid != -1 {
+		if err := os.Chown(s.UnixSocket, uid, gid); err != nil {
+			ln.Close()
+			return nil, fmt.Errorf("failed to set the owner of %s (%w)", s.UnixSocket, err)
+		}
+	}
+
+	if err := os.Chmod(s.UnixSocket, mode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to set the mode of %s (%w)", s.UnixSocket, err)
+	}
+
+	return ln, nil
+}
+
+// Parses an octal file mode, such as 0660
+func parseUnixSocketMode(mode string) (fs.FileMode, error) {
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || m > uint64(fs.ModePerm) {
+		return 0, fmt.Errorf("invalid %s %q, want an octal mode such as 0660", unixSocketModeKey, mode)
+	}
+
+	return fs.FileMode(m), nil
+}
+
+// Returns the uid of a user name or numeric id, or -1 to leave it unchanged
+func lookupUID(owner string) (int, error) {
+	if owner == "" {
+		return -1, nil
+	}
+
+	if uid, err := strconv.Atoi(owner); err == nil {
+		return uid, nil
+	}
+
+	u, err := user.Lookup(owner)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s (%w)", unixSocketOwnerKey, err)
+	}
+
+	return strconv.Atoi(u.Uid)
+}
+
+// Returns the gid of a group name or numeric id, or -1 to leave it unchanged
+func lookupGID(group string) (int, error) {
+	if group == "" {
+		return -1, nil
+	}
+
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s (%w)", unixSocketGroupKey, err)
+	}
+
+	return strconv.Atoi(g.Gid)
+}
+
+// Removes a socket left behind at path by a previous run, one which still
+// accepts connections belongs to a running server and is left alone
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to check %s (%w)", path, err)
+	}
+
+	if fi.Mode().Type() != fs.ModeSocket {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another server", path)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove stale socket %s (%w)", path, err)
+	}
+
+	return nil
+}
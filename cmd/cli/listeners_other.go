@@ -0,0 +1,11 @@
+//go:build !unix
+
+package cli
+
+// Runs f, there's no umask to set on this platform
+func withUmask(_ int, f func() error) error {
+	return f()
+}
+
+// Does nothing, file descriptors aren't inherited on this platform
+func closeOnExec(_ int) {}
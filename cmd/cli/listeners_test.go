@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eljamo/libpass/v8/config/option"
+)
+
+func TestListenFDCount(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    int
+		wantErr bool
+	}{
+		{"not activated", map[string]string{}, 0, false},
+		{"another process", map[string]string{listenPIDEnv: "1", listenFDsEnv: "2"}, 0, false},
+		{"activated", map[string]string{listenPIDEnv: "42", listenFDsEnv: "2"}, 2, false},
+		{"invalid count", map[string]string{listenPIDEnv: "42", listenFDsEnv: "two"}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := listenFDCount(func(key string) string { return tt.env[key] }, 42)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("listenFDCount() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("listenFDCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUnixSocketMode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		mode    string
+		want    os.FileMode
+		wantErr bool
+	}{
+		{"0660", 0o660, false},
+		{"600", 0o600, false},
+		{"0999", 0, true},
+		{"01777", 0, true},
+		{"rw-rw----", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseUnixSocketMode(tt.mode)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseUnixSocketMode(%q) error = %v, wantErr %t", tt.mode, err, tt.wantErr)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("parseUnixSocketMode(%q) = %o, want %o", tt.mode, got, tt.want)
+		}
+	}
+}
+
+// Returns a short socket path, the limit on Unix socket paths is easily hit
+// inside t.TempDir()
+func testSocketPath(t *testing.T) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "mempass")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return filepath.Join(dir, "s.sock")
+}
+
+func TestServeUnixSocket(t *testing.T) {
+	t.Parallel()
+
+	settings := defaultServerSettings()
+	settings.AccessLog = false
+	settings.UnixSocket = testSocketPath(t)
+	settings.UnixSocketMode = "0600"
+
+	// a socket left behind by a previous run is replaced
+	stale, err := net.Listen("unix", settings.UnixSocket)
+	if err != nil {
+		t.Fatalf("failed to create stale socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	lns, err := settings.listeners()
+	if err != nil {
+		t.Fatalf("listeners() error = %v", err)
+	}
+
+	fi, err := os.Stat(settings.UnixSocket)
+	if err != nil {
+		t.Fatalf("failed to stat socket: %v", err)
+	}
+
+	if fi.Mode().Perm() != 0o600 {
+		t.Errorf("socket mode = %o, want 600", fi.Mode().Perm())
+	}
+
+	ps, err := newPasswordServer(&configLayers{preset: option.PresetDefault}, settings, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("newPasswordServer() error = %v", err)
+	}
+
+	var gotClient string
+	srv := &http.Server{Handler: ps.authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotClient = clientID(r)
+	}))}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- serve(ctx, srv, lns, log.New(io.Discard, "", 0)) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", settings.UnixSocket)
+		},
+	}}
+
+	resp, err := client.Get("http://mempass/v1/options")
+	if err != nil {
+		t.Fatalf("GET over Unix socket error = %v", err)
+	}
+	resp.Body.Close()
+
+	if gotClient != unixSocketClientID {
+		t.Errorf("clientID() = %q, want %q", gotClient, unixSocketClientID)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("serve() error = %v", err)
+	}
+
+	if _, err := os.Stat(settings.UnixSocket); !os.IsNotExist(err) {
+		t.Errorf("socket still exists after shutdown, stat error = %v", err)
+	}
+}
+
+func TestListenUnixRefusesExistingFile(t *testing.T) {
+	t.Parallel()
+
+	settings := defaultServerSettings()
+	settings.UnixSocket = testSocketPath(t)
+	if err := os.WriteFile(settings.UnixSocket, []byte("keep me"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	if _, err := settings.listenUnix(); err == nil {
+		t.Error("listenUnix() error = nil, want an error for a regular file")
+	}
+
+	if b, err := os.ReadFile(settings.UnixSocket); err != nil || string(b) != "keep me" {
+		t.Errorf("file was changed, content = %q, error = %v", b, err)
+	}
+}
+
+func TestListenUnixRefusesSocketInUse(t *testing.T) {
+	t.Parallel()
+
+	settings := defaultServerSettings()
+	settings.UnixSocket = testSocketPath(t)
+
+	ln, err := net.Listen("unix", settings.UnixSocket)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	if _, err := settings.listenUnix(); err == nil {
+		t.Error("listenUnix() error = nil, want an error for a socket in use")
+	}
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package cli
+
+import "syscall"
+
+// Runs f with the process umask set to mask, restoring it afterwards
+func withUmask(mask int, f func() error) error {
+	old := syscall.Umask(mask)
+	defer syscall.Umask(old)
+
+	return f()
+}
+
+// Marks fd to be closed when the process execs
+func closeOnExec(fd int) {
+	syscall.CloseOnExec(fd)
+}
@@ -0,0 +1,183 @@
+package cli
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/eljamo/libpass/v8/config"
+	"github.com/eljamo/libpass/v8/config/option"
+	"github.com/eljamo/libpass/v8/service"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace of every metric the server exposes
+const metricsNamespace string = "mempass"
+
+// Label value used for a preset or word list which isn't built-in, so labels
+// only ever hold values from a fixed set
+const otherLabelValue string = "other"
+
+// Labels of the metrics broken down by config
+var configLabels = []string{option.ConfigKeyPreset, option.ConfigKeyWordList}
+
+// serverMetrics holds the metrics exposed at /metrics. Labels only ever hold
+// built-in preset and word list names, never anything from a password.
+type serverMetrics struct {
+	registry *prometheus.Registry
+
+	generateRequests         *prometheus.CounterVec
+	generateDuration         *prometheus.HistogramVec
+	weakPasswordWarnings     *prometheus.CounterVec
+	scoreRequests            prometheus.Counter
+	scoredPasswords          prometheus.Counter
+	scoreDuration            prometheus.Histogram
+	configValidationFailures prometheus.Counter
+	rngErrors                prometheus.Counter
+}
+
+// Creates the server's metrics in their own registry, along with the Go
+// runtime and process metrics
+func newServerMetrics() *serverMetrics {
+	m := &serverMetrics{
+		registry: prometheus.NewRegistry(),
+		generateRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "generate_requests_total",
+			Help:      "Generate requests with a valid config, by preset and word list.",
+		}, configLabels),
+		generateDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "generate_duration_seconds",
+			Help:      "Time taken by Generate() for each generate request, by preset and word list.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, configLabels),
+		weakPasswordWarnings: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "weak_password_warnings_total",
+			Help:      "Generate responses which carried a weak password warning, by preset and word list.",
+		}, configLabels),
+		scoreRequests: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "score_requests_total",
+			Help:      "Score requests.",
+		}),
+		scoredPasswords: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "scored_passwords_total",
+			Help:      "Passwords scored with zxcvbn.",
+		}),
+		scoreDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "score_duration_seconds",
+			Help:      "Time taken by zxcvbn to score a single password.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}),
+		configValidationFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "config_validation_failures_total",
+			Help:      "Generate requests rejected because their config was invalid.",
+		}),
+		rngErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "rng_errors_total",
+			Help:      "Errors returned by the random number generator.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.generateRequests,
+		m.generateDuration,
+		m.weakPasswordWarnings,
+		m.scoreRequests,
+		m.scoredPasswords,
+		m.scoreDuration,
+		m.configValidationFailures,
+		m.rngErrors,
+	)
+
+	return m
+}
+
+// Returns the handler serving the metrics in the Prometheus exposition format
+func (m *serverMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Returns the config label values for cfg
+func configLabelValues(cfg *config.Settings) prometheus.Labels {
+	return prometheus.Labels{
+		option.ConfigKeyPreset:   builtInLabelValue(cfg.Preset, option.Presets),
+		option.ConfigKeyWordList: builtInLabelValue(cfg.WordList, option.WordLists),
+	}
+}
+
+// Returns value if it's one of the built-in values, otherwise otherLabelValue
+func builtInLabelValue(value string, builtIn []string) string {
+	if !slices.Contains(builtIn, value) {
+		return otherLabelValue
+	}
+
+	return value
+}
+
+// Records the time since start in a histogram, in seconds
+func observeSince(o prometheus.Observer, start time.Time) {
+	o.Observe(time.Since(start).Seconds())
+}
+
+// countingRNGService counts the errors returned by the RNG service it wraps
+type countingRNGService struct {
+	service.RNGService
+	errors prometheus.Counter
+}
+
+// Wraps rngSvc so every error it returns is counted in errors
+func newCountingRNGService(rngSvc service.RNGService, errors prometheus.Counter) *countingRNGService {
+	return &countingRNGService{rngSvc, errors}
+}
+
+func (s *countingRNGService) count(err error) {
+	if err != nil {
+		s.errors.Inc()
+	}
+}
+
+func (s *countingRNGService) GenerateWithMax(max int) (int, error) {
+	n, err := s.RNGService.GenerateWithMax(max)
+	s.count(err)
+
+	return n, err
+}
+
+func (s *countingRNGService) Generate() (int, error) {
+	n, err := s.RNGService.Generate()
+	s.count(err)
+
+	return n, err
+}
+
+func (s *countingRNGService) GenerateDigit() (int, error) {
+	n, err := s.RNGService.GenerateDigit()
+	s.count(err)
+
+	return n, err
+}
+
+func (s *countingRNGService) GenerateSliceWithMax(length int, max int) ([]int, error) {
+	ns, err := s.RNGService.GenerateSliceWithMax(length, max)
+	s.count(err)
+
+	return ns, err
+}
+
+func (s *countingRNGService) GenerateSlice(length int) ([]int, error) {
+	ns, err := s.RNGService.GenerateSlice(length)
+	s.count(err)
+
+	return ns, err
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/eljamo/libpass/v8/config"
+	"github.com/eljamo/libpass/v8/config/option"
+	"github.com/eljamo/libpass/v8/service"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBuiltInLabelValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{option.PresetXKCD, option.PresetXKCD},
+		{"hunter2", otherLabelValue},
+		{"", otherLabelValue},
+	}
+
+	for _, tt := range tests {
+		if got := builtInLabelValue(tt.value, option.Presets); got != tt.want {
+			t.Errorf("builtInLabelValue(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestConfigLabelValues(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Settings{Preset: option.PresetWiFi, WordList: "my-secret-list"}
+	got := configLabelValues(cfg)
+
+	if got[option.ConfigKeyPreset] != option.PresetWiFi || got[option.ConfigKeyWordList] != otherLabelValue {
+		t.Errorf("configLabelValues() = %v, want preset %s and word list %s", got, option.PresetWiFi, otherLabelValue)
+	}
+}
+
+func TestCountingRNGService(t *testing.T) {
+	t.Parallel()
+
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_rng_errors_total"})
+	rng := newCountingRNGService(newSeededRNGService(1), counter)
+
+	if _, err := rng.GenerateWithMax(10); err != nil {
+		t.Fatalf("GenerateWithMax(10) error = %v", err)
+	}
+
+	if _, err := rng.GenerateWithMax(0); !errors.Is(err, service.ErrRNGMaxLessThanOne) {
+		t.Errorf("GenerateWithMax(0) error = %v, want %v", err, service.ErrRNGMaxLessThanOne)
+	}
+
+	if _, err := rng.GenerateSlice(-1); !errors.Is(err, service.ErrRNGSliceLengthLessThanZero) {
+		t.Errorf("GenerateSlice(-1) error = %v, want %v", err, service.ErrRNGSliceLengthLessThanZero)
+	}
+
+	if got := testutil.ToFloat64(counter); got != 2 {
+		t.Errorf("errors counted = %v, want 2", got)
+	}
+}
+
+func TestServerMetrics(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestPasswordServer(t)
+
+	resp := postJSON(t, srv.URL+"/v1/generate", `{"preset": "XKCD", "num_passwords": 1}`)
+	var gen generateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&gen); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	postJSON(t, srv.URL+"/v1/generate", `{"preset": "NTLM", "num_passwords": 1}`)
+	postJSON(t, srv.URL+"/v1/generate", `{"num_words": 1}`)
+	postJSON(t, srv.URL+"/v1/score", `{"passwords": ["correcthorsebatterystaple", "hunter2"]}`)
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read metrics: %v", err)
+	}
+	body := string(b)
+
+	for _, want := range []string{
+		`mempass_generate_requests_total{preset="XKCD",word_list="EN"} 1`,
+		`mempass_generate_duration_seconds_count{preset="XKCD",word_list="EN"} 1`,
+		`mempass_weak_password_warnings_total{preset="NTLM",word_list="EN"} 1`,
+		`mempass_config_validation_failures_total 1`,
+		`mempass_score_requests_total 1`,
+		`mempass_scored_passwords_total 2`,
+		`mempass_score_duration_seconds_count 2`,
+		`mempass_rng_errors_total 0`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+
+	for _, secret := range append(gen.Passwords, "correcthorsebatterystaple", "hunter2") {
+		if strings.Contains(body, secret) {
+			t.Errorf("metrics contain password %q", secret)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package cli
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/eljamo/libpass/v8/service"
+	"github.com/eljamo/zxcvbn"
+	"github.com/spf13/cobra"
+)
+
+// Largest message the host accepts from the browser, and the largest the
+// browser accepts from the host, see
+// https://developer.chrome.com/docs/extensions/develop/concepts/native-messaging
+const (
+	maxNativeRequestBytes  uint32 = uint32(maxRequestBytes)
+	maxNativeResponseBytes int    = 1024 * 1024
+)
+
+// Native messaging request types
+const (
+	nativeRequestGenerate string = "generate"
+	nativeRequestScore    string = "score"
+)
+
+// ErrNativeMessageTooLarge is returned for a message over the size limit, the
+// rest of the stream can't be trusted after it
+var ErrNativeMessageTooLarge = errors.New("native message too large")
+
+var nativeHostCmd = &cobra.Command{
+	Use:   "native-host",
+	Short: "Run as a browser native messaging host",
+	Long: `Run as a browser native messaging host, for a browser extension to generate
+and score passwords. The browser starts this command itself, run
+"mempass native-host install" to register it.
+
+Messages are JSON, each prefixed with its length as a 32-bit unsigned integer
+in native byte order, on stdin and stdout.
+
+  {"id": 1, "type": "generate", "config": {"preset": "XKCD"}}
+  {"id": 2, "type": "score", "passwords": ["..."]}
+
+The config of a generate request takes the same keys as a custom config and is
+merged on top of the host's config. Each response carries the id of its
+request, and either the same fields as the HTTP server's responses or an
+error`,
+	// Browsers pass the calling extension's origin, and sometimes more, as
+	// arguments
+	Args: cobra.ArbitraryArgs,
+	RunE: runNativeHostCmd,
+}
+
+// A message from the browser
+type nativeRequest struct {
+	ID        json.RawMessage `json:"id,omitempty"`
+	Type      string          `json:"type"`
+	Config    map[string]any  `json:"config,omitempty"`
+	Passwords []string        `json:"passwords,omitempty"`
+}
+
+// A message to the browser, the fields set depend on the request type
+type nativeResponse struct {
+	ID        json.RawMessage  `json:"id,omitempty"`
+	Passwords []string         `json:"passwords,omitempty"`
+	Entropy   *entropyResponse `json:"entropy,omitempty"`
+	Warning   string           `json:"warning,omitempty"`
+	Results   []scoreResult    `json:"results,omitempty"`
+	Error     string           `json:"error,omitempty"`
+}
+
+func runNativeHostCmd(cmd *cobra.Command, args []string) error {
+	layers, err := getConfigLayers(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if _, err := layers.merge(nil); err != nil {
+		return fmt.Errorf("failed to generate config: %w", err)
+	}
+
+	return runNativeHost(cmd.InOrStdin(), cmd.OutOrStdout(), layers)
+}
+
+// Answers requests from r on w until r is closed
+func runNativeHost(r io.Reader, w io.Writer, layers *configLayers) error {
+	for {
+		var req nativeRequest
+		err := readNativeMessage(r, &req)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		var resp nativeResponse
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+			resp = nativeResponse{Error: err.Error()}
+		case err != nil:
+			return err
+		default:
+			resp = handleNativeRequest(layers, req)
+		}
+
+		if err := writeNativeMessage(w, resp); err != nil {
+			return err
+		}
+	}
+}
+
+// Answers a single request, any error is reported in the response
+func handleNativeRequest(layers *configLayers, req nativeRequest) nativeResponse {
+	var resp nativeResponse
+	var err error
+	switch req.Type {
+	case nativeRequestGenerate:
+		resp, err = nativeGenerate(layers, req.Config)
+	case nativeRequestScore:
+		resp, err = nativeScore(req.Passwords)
+	default:
+		err = fmt.Errorf("unknown request type %q, want %q or %q", req.Type, nativeRequestGenerate, nativeRequestScore)
+	}
+
+	if err != nil {
+		resp = nativeResponse{Error: err.Error()}
+	}
+	resp.ID = req.ID
+
+	return resp
+}
+
+// Generates passwords from the host's config layers with reqCfg on top
+func nativeGenerate(layers *configLayers, reqCfg map[string]any) (nativeResponse, error) {
+	cfg, err := layers.merge(reqCfg)
+	if err != nil {
+		return nativeResponse{}, err
+	}
+
+	if err := validateConfigLimits(cfg); err != nil {
+		return nativeResponse{}, err
+	}
+
+	pgs, err := service.NewPasswordGeneratorService(cfg)
+	if err != nil {
+		return nativeResponse{}, fmt.Errorf("invalid config (%w)", err)
+	}
+
+	est, err := estimateEntropy(cfg)
+	if err != nil {
+		return nativeResponse{}, err
+	}
+
+	pws, err := pgs.Generate()
+	if err != nil {
+		return nativeResponse{}, fmt.Errorf("failed to generate passwords (%w)", err)
+	}
+
+	_, warning := evaluatePasswords(pws, false)
+
+	return nativeResponse{
+		Passwords: pws,
+		Entropy:   &entropyResponse{est.Bits, est.TypicalLength},
+		Warning:   warning,
+	}, nil
+}
+
+// Scores each password with zxcvbn
+func nativeScore(pws []string) (nativeResponse, error) {
+	if err := validateScorePasswords(pws); err != nil {
+		return nativeResponse{}, err
+	}
+
+	results := make([]scoreResult, 0, len(pws))
+	for _, p := range pws {
+		results = append(results, newScoreResult(zxcvbn.PasswordStrength(p, nil)))
+	}
+
+	return nativeResponse{Results: results}, nil
+}
+
+// Reads a single length-prefixed JSON message into v. io.EOF is returned
+// only when the stream ends cleanly between messages.
+func readNativeMessage(r io.Reader, v any) error {
+	var size uint32
+	if err := binary.Read(r, binary.NativeEndian, &size); err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.EOF
+		}
+
+		return fmt.Errorf("failed to read native message length (%w)", err)
+	}
+
+	if size > maxNativeRequestBytes {
+		return fmt.Errorf("%w, %d bytes is over the limit of %d", ErrNativeMessageTooLarge, size, maxNativeRequestBytes)
+	}
+
+	b := make([]byte, size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return fmt.Errorf("failed to read native message (%w)", err)
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("invalid JSON native message (%w)", err)
+	}
+
+	return nil
+}
+
+// Writes v as a single length-prefixed JSON message
+func writeNativeMessage(w io.Writer, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal native message (%w)", err)
+	}
+
+	if len(b) > maxNativeResponseBytes {
+		return fmt.Errorf("%w, %d bytes is over the limit of %d", ErrNativeMessageTooLarge, len(b), maxNativeResponseBytes)
+	}
+
+	// a single write, so a message is never split between two writes
+	msg := binary.NativeEndian.AppendUint32(make([]byte, 0, 4+len(b)), uint32(len(b)))
+	if _, err := w.Write(append(msg, b...)); err != nil {
+		return fmt.Errorf("failed to write native message (%w)", err)
+	}
+
+	return nil
+}
+
+func init() {
+	addConfigFlags(nativeHostCmd.Flags())
+
+	rootCmd.AddCommand(nativeHostCmd)
+}
@@ -0,0 +1,362 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Name the browser extension connects to the native messaging host with
+const nativeHostName string = "io.github.eljamo.mempass"
+
+// Constants for the native host install flag keys
+const (
+	browserKey            string = "browser"
+	chromeExtensionIDKey  string = "chrome_extension_id"
+	firefoxExtensionIDKey string = "firefox_extension_id"
+)
+
+// Browsers the native messaging host can be installed for
+const (
+	browserChrome   string = "chrome"
+	browserChromium string = "chromium"
+	browserBrave    string = "brave"
+	browserFirefox  string = "firefox"
+)
+
+var nativeHostBrowsers = []string{browserChrome, browserChromium, browserBrave, browserFirefox}
+
+// Chrome extension IDs are 32 characters from a to p
+var chromeExtensionIDPattern = regexp.MustCompile(`^[a-p]{32}$`)
+
+var nativeHostInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Register the native messaging host with the browsers for the current user",
+	Long: `Register the native messaging host with the browsers for the current user.
+
+Writes a launcher script to $XDG_DATA_HOME/mempass, which starts
+"mempass native-host" with any config flags given here, and a host manifest
+allowing the given extension to start it for each browser:
+
+  chrome    $XDG_CONFIG_HOME/google-chrome/NativeMessagingHosts
+  chromium  $XDG_CONFIG_HOME/chromium/NativeMessagingHosts
+  brave     $XDG_CONFIG_HOME/BraveSoftware/Brave-Browser/NativeMessagingHosts
+  firefox   ~/.mozilla/native-messaging-hosts
+
+By default it installs for chrome and chromium when a Chrome extension ID is
+given, and for firefox when a Firefox extension ID is given`,
+	Args: cobra.NoArgs,
+	RunE: runNativeHostInstallCmd,
+}
+
+// Directories the native messaging host files are installed under
+type nativeHostDirs struct {
+	home   string
+	config string
+	data   string
+}
+
+// Returns the current user's directories, following the XDG base directory
+// spec
+func userNativeHostDirs() (nativeHostDirs, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nativeHostDirs{}, fmt.Errorf("failed to find home directory (%w)", err)
+	}
+
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return nativeHostDirs{}, fmt.Errorf("failed to find config directory (%w)", err)
+	}
+
+	return nativeHostDirs{home, config, userDataDirIn(home)}, nil
+}
+
+// Returns the current user's data directory, following the XDG base
+// directory spec
+func userDataDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory (%w)", err)
+	}
+
+	return userDataDirIn(home), nil
+}
+
+// Returns the data directory of the user with the home directory
+func userDataDirIn(home string) string {
+	if data := os.Getenv("XDG_DATA_HOME"); data != "" {
+		return data
+	}
+
+	return filepath.Join(home, ".local", "share")
+}
+
+// Returns the directory a browser reads native messaging host manifests from
+func (d nativeHostDirs) manifestDir(browser string) string {
+	switch browser {
+	case browserChrome:
+		return filepath.Join(d.config, "google-chrome", "NativeMessagingHosts")
+	case browserChromium:
+		return filepath.Join(d.config, "chromium", "NativeMessagingHosts")
+	case browserBrave:
+		return filepath.Join(d.config, "BraveSoftware", "Brave-Browser", "NativeMessagingHosts")
+	default:
+		return filepath.Join(d.home, ".mozilla", "native-messaging-hosts")
+	}
+}
+
+// A native messaging host manifest. Chromium based browsers allow extensions
+// by origin, Firefox by extension ID.
+type nativeHostManifest struct {
+	Name              string   `json:"name"`
+	Description       string   `json:"description"`
+	Path              string   `json:"path"`
+	Type              string   `json:"type"`
+	AllowedOrigins    []string `json:"allowed_origins,omitempty"`
+	AllowedExtensions []string `json:"allowed_extensions,omitempty"`
+}
+
+func runNativeHostInstallCmd(cmd *cobra.Command, args []string) error {
+	browsers, err := cmd.Flags().GetStringSlice(browserKey)
+	if err != nil {
+		return fmt.Errorf("failed to get %s flag: %w", browserKey, err)
+	}
+
+	chromeID, err := cmd.Flags().GetString(chromeExtensionIDKey)
+	if err != nil {
+		return fmt.Errorf("failed to get %s flag: %w", chromeExtensionIDKey, err)
+	}
+
+	firefoxID, err := cmd.Flags().GetString(firefoxExtensionIDKey)
+	if err != nil {
+		return fmt.Errorf("failed to get %s flag: %w", firefoxExtensionIDKey, err)
+	}
+
+	// Check the config flags work before baking them into the launcher
+	if _, err := generateConfig(cmd); err != nil {
+		return fmt.Errorf("failed to generate config: %w", err)
+	}
+
+	hostArgs, err := nativeHostArgs(cmd)
+	if err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the mempass executable: %w", err)
+	}
+
+	dirs, err := userNativeHostDirs()
+	if err != nil {
+		return err
+	}
+
+	paths, err := installNativeHost(dirs, exe, hostArgs, browsers, chromeID, firefoxID)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range paths {
+		cmd.Println(p)
+	}
+
+	return nil
+}
+
+// Returns the config flags set on cmd as arguments for the native host, with
+// the custom config path made absolute as the browser starts the host from
+// its own working directory
+func nativeHostArgs(cmd *cobra.Command) ([]string, error) {
+	var args []string
+	var err error
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if err != nil {
+			return
+		}
+
+		switch flag.Name {
+		case browserKey, chromeExtensionIDKey, firefoxExtensionIDKey:
+			return
+		}
+
+		value := flag.Value.String()
+		if sv, ok := flag.Value.(pflag.SliceValue); ok {
+			value = strings.Join(sv.GetSlice(), ",")
+		}
+
+		if flag.Name == customConfigPathKey {
+			value, err = filepath.Abs(value)
+		}
+
+		args = append(args, fmt.Sprintf("--%s=%s", flag.Name, value))
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", customConfigPathKey, err)
+	}
+
+	return args, nil
+}
+
+// Writes the launcher script and a manifest for each browser, returning the
+// paths written. With no browsers given it installs for every browser an
+// extension ID is given for.
+func installNativeHost(
+	dirs nativeHostDirs,
+	exe string,
+	hostArgs []string,
+	browsers []string,
+	chromeID string,
+	firefoxID string,
+) ([]string, error) {
+	if chromeID == "" && firefoxID == "" {
+		return nil, fmt.Errorf("%s or %s is required", chromeExtensionIDKey, firefoxExtensionIDKey)
+	}
+
+	if chromeID != "" && !chromeExtensionIDPattern.MatchString(chromeID) {
+		return nil, fmt.Errorf("invalid %s %q, want 32 characters from a to p", chromeExtensionIDKey, chromeID)
+	}
+
+	if len(browsers) == 0 {
+		if chromeID != "" {
+			browsers = append(browsers, browserChrome, browserChromium)
+		}
+
+		if firefoxID != "" {
+			browsers = append(browsers, browserFirefox)
+		}
+	}
+
+	for _, b := range browsers {
+		if !slices.Contains(nativeHostBrowsers, b) {
+			return nil, fmt.Errorf("invalid %s %q, valid values: %s", browserKey, b, strings.Join(nativeHostBrowsers, ", "))
+		}
+
+		if b == browserFirefox && firefoxID == "" {
+			return nil, fmt.Errorf("%s is required for %s", firefoxExtensionIDKey, b)
+		}
+
+		if b != browserFirefox && chromeID == "" {
+			return nil, fmt.Errorf("%s is required for %s", chromeExtensionIDKey, b)
+		}
+	}
+
+	launcher, err := writeNativeHostLauncher(dirs.data, exe, hostArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{launcher}
+	for _, b := range browsers {
+		manifest := nativeHostManifest{
+			Name:        nativeHostName,
+			Description: "mempass memorable password generator",
+			Path:        launcher,
+			Type:        "stdio",
+		}
+
+		if b == browserFirefox {
+			manifest.AllowedExtensions = []string{firefoxID}
+		} else {
+			manifest.AllowedOrigins = []string{"chrome-extension://" + chromeID + "/"}
+		}
+
+		path, err := writeNativeHostManifest(dirs.manifestDir(b), manifest)
+		if err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// Writes the script browsers run to start the native host, as manifests can't
+// pass arguments
+func writeNativeHostLauncher(dataDir string, exe string, hostArgs []string) (string, error) {
+	dir := filepath.Join(dataDir, "mempass")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create %s (%w)", dir, err)
+	}
+
+	quoted := []string{shellQuote(exe), "native-host"}
+	for _, a := range hostArgs {
+		quoted = append(quoted, shellQuote(a))
+	}
+
+	script := "#!/bin/sh\n" +
+		"# Written by mempass native-host install, browsers run this to start the\n" +
+		"# native messaging host\n" +
+		"exec " + strings.Join(quoted, " ") + " \"$@\"\n"
+
+	path := filepath.Join(dir, "native-host")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		return "", fmt.Errorf("failed to write %s (%w)", path, err)
+	}
+
+	// WriteFile leaves the mode of an existing file alone
+	if err := os.Chmod(path, 0o755); err != nil {
+		return "", fmt.Errorf("failed to make %s executable (%w)", path, err)
+	}
+
+	return path, nil
+}
+
+// Writes a manifest to dir, named after the host as browsers require
+func writeNativeHostManifest(dir string, manifest nativeHostManifest) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create %s (%w)", dir, err)
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal manifest (%w)", err)
+	}
+
+	path := filepath.Join(dir, nativeHostName+".json")
+	if err := os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write %s (%w)", path, err)
+	}
+
+	return path, nil
+}
+
+// Quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func init() {
+	nativeHostInstallCmd.Flags().StringSlice(
+		browserKey,
+		[]string{},
+		fmt.Sprintf(
+			"comma-separated list of browsers to install for, valid values: %s",
+			strings.Join(nativeHostBrowsers, ", "),
+		),
+	)
+	nativeHostInstallCmd.Flags().String(
+		chromeExtensionIDKey,
+		"",
+		"ID of the extension allowed to use the host in Chromium based browsers",
+	)
+	nativeHostInstallCmd.Flags().String(
+		firefoxExtensionIDKey,
+		"",
+		"ID of the extension allowed to use the host in Firefox, e.g. mempass@example.com",
+	)
+
+	addConfigFlags(nativeHostInstallCmd.Flags())
+
+	nativeHostCmd.AddCommand(nativeHostInstallCmd)
+}
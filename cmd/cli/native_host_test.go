@@ -0,0 +1,219 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eljamo/libpass/v8/config/option"
+)
+
+// Encodes requests as a stream of native messages
+func nativeMessages(t *testing.T, msgs ...string) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	for _, m := range msgs {
+		if err := binary.Write(&buf, binary.NativeEndian, uint32(len(m))); err != nil {
+			t.Fatalf("failed to write length: %v", err)
+		}
+		buf.WriteString(m)
+	}
+
+	return &buf
+}
+
+// Decodes every native message response written to r
+func readNativeResponses(t *testing.T, r io.Reader) []nativeResponse {
+	t.Helper()
+
+	var resps []nativeResponse
+	for {
+		var resp nativeResponse
+		err := readNativeMessage(r, &resp)
+		if errors.Is(err, io.EOF) {
+			return resps
+		}
+
+		if err != nil {
+			t.Fatalf("readNativeMessage() error = %v", err)
+		}
+
+		resps = append(resps, resp)
+	}
+}
+
+func TestRunNativeHost(t *testing.T) {
+	t.Parallel()
+
+	in := nativeMessages(t,
+		`{"id": 1, "type": "generate", "config": {"preset": "XKCD", "num_passwords": 2}}`,
+		`{"id": "two", "type": "score", "passwords": ["password"]}`,
+		`{"id": 3, "type": "generate", "config": {"num_words": 1}}`,
+		`{"id": 4, "type": "delete"}`,
+		`not json`,
+		`{"id": 6, "type": "generate", "config": {"padding_type": "ADAPTIVE", "pad_to_length": 2000000000}}`,
+		`{"id": 7, "type": "generate", "config": {"num_words": 1000000000}}`,
+	)
+
+	var out bytes.Buffer
+	if err := runNativeHost(in, &out, &configLayers{preset: option.PresetDefault}); err != nil {
+		t.Fatalf("runNativeHost() error = %v", err)
+	}
+
+	resps := readNativeResponses(t, &out)
+	if len(resps) != 7 {
+		t.Fatalf("got %d responses, want 7", len(resps))
+	}
+
+	if string(resps[0].ID) != "1" || len(resps[0].Passwords) != 2 || resps[0].Entropy == nil {
+		t.Errorf("generate response = %+v, want id 1, 2 passwords and an entropy estimate", resps[0])
+	}
+
+	if !strings.Contains(resps[0].Passwords[0], "-") {
+		t.Errorf("password = %q, want the XKCD preset's dash separator", resps[0].Passwords[0])
+	}
+
+	if string(resps[1].ID) != `"two"` || len(resps[1].Results) != 1 || resps[1].Results[0].Score != 0 {
+		t.Errorf("score response = %+v, want id \"two\" and a single score of 0", resps[1])
+	}
+
+	for i, resp := range resps[2:] {
+		if resp.Error == "" || resp.Passwords != nil {
+			t.Errorf("response %d = %+v, want only an error", i+2, resp)
+		}
+	}
+}
+
+func TestReadNativeMessageTooLarge(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.NativeEndian, maxNativeRequestBytes+1); err != nil {
+		t.Fatalf("failed to write length: %v", err)
+	}
+
+	var req nativeRequest
+	if err := readNativeMessage(&buf, &req); !errors.Is(err, ErrNativeMessageTooLarge) {
+		t.Errorf("readNativeMessage() error = %v, want %v", err, ErrNativeMessageTooLarge)
+	}
+}
+
+func TestReadNativeMessageTruncated(t *testing.T) {
+	t.Parallel()
+
+	in := nativeMessages(t, `{"type": "score"}`)
+	in.Truncate(in.Len() - 1)
+
+	var req nativeRequest
+	if err := readNativeMessage(in, &req); err == nil || errors.Is(err, io.EOF) {
+		t.Errorf("readNativeMessage() error = %v, want an error other than io.EOF", err)
+	}
+}
+
+func TestInstallNativeHost(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	dirs := nativeHostDirs{
+		home:   filepath.Join(root, "home"),
+		config: filepath.Join(root, "config"),
+		data:   filepath.Join(root, "data"),
+	}
+	chromeID := strings.Repeat("a", 32)
+
+	paths, err := installNativeHost(
+		dirs, "/opt/it's/mempass", []string{"--preset=XKCD"}, nil, chromeID, "mempass@example.com",
+	)
+	if err != nil {
+		t.Fatalf("installNativeHost() error = %v", err)
+	}
+
+	want := []string{
+		filepath.Join(dirs.data, "mempass", "native-host"),
+		filepath.Join(dirs.config, "google-chrome", "NativeMessagingHosts", nativeHostName+".json"),
+		filepath.Join(dirs.config, "chromium", "NativeMessagingHosts", nativeHostName+".json"),
+		filepath.Join(dirs.home, ".mozilla", "native-messaging-hosts", nativeHostName+".json"),
+	}
+	if strings.Join(paths, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("installNativeHost() = %v, want %v", paths, want)
+	}
+
+	script, err := os.ReadFile(paths[0])
+	if err != nil {
+		t.Fatalf("failed to read launcher: %v", err)
+	}
+
+	wantExec := `exec '/opt/it'\''s/mempass' native-host '--preset=XKCD' "$@"`
+	if !strings.Contains(string(script), wantExec) {
+		t.Errorf("launcher = %q, want it to contain %q", script, wantExec)
+	}
+
+	if fi, err := os.Stat(paths[0]); err != nil || fi.Mode().Perm() != 0o755 {
+		t.Errorf("launcher mode = %v, error = %v, want 0755", fi.Mode().Perm(), err)
+	}
+
+	var chrome, firefox nativeHostManifest
+	for path, m := range map[string]*nativeHostManifest{paths[1]: &chrome, paths[3]: &firefox} {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read manifest: %v", err)
+		}
+
+		if err := json.Unmarshal(b, m); err != nil {
+			t.Fatalf("failed to decode manifest: %v", err)
+		}
+	}
+
+	if chrome.Path != paths[0] || chrome.Type != "stdio" || chrome.Name != nativeHostName {
+		t.Errorf("chrome manifest = %+v, want the launcher path, stdio type and host name", chrome)
+	}
+
+	if len(chrome.AllowedOrigins) != 1 || chrome.AllowedOrigins[0] != "chrome-extension://"+chromeID+"/" {
+		t.Errorf("chrome allowed_origins = %v, want the extension's origin", chrome.AllowedOrigins)
+	}
+
+	if len(firefox.AllowedExtensions) != 1 || firefox.AllowedExtensions[0] != "mempass@example.com" {
+		t.Errorf("firefox allowed_extensions = %v, want the extension ID", firefox.AllowedExtensions)
+	}
+}
+
+func TestInstallNativeHostErrors(t *testing.T) {
+	t.Parallel()
+
+	chromeID := strings.Repeat("p", 32)
+	tests := []struct {
+		name      string
+		browsers  []string
+		chromeID  string
+		firefoxID string
+	}{
+		{"no extension ID", nil, "", ""},
+		{"invalid chrome ID", nil, "not-an-id", ""},
+		{"unknown browser", []string{"netscape"}, chromeID, ""},
+		{"firefox without its ID", []string{browserFirefox}, chromeID, ""},
+		{"brave without a chrome ID", []string{browserBrave}, "", "mempass@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			root := t.TempDir()
+			dirs := nativeHostDirs{root, root, root}
+			if _, err := installNativeHost(dirs, "/bin/mempass", nil, tt.browsers, tt.chromeID, tt.firefoxID); err == nil {
+				t.Error("installNativeHost() error = nil, want an error")
+			}
+
+			if entries, _ := os.ReadDir(root); len(entries) != 0 {
+				t.Errorf("installNativeHost() wrote %d entries, want nothing written on error", len(entries))
+			}
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/eljamo/zxcvbn"
+	"github.com/spf13/cobra"
+)
+
+// Constant for the output format flag key
+const formatKey string = "format"
+
+// Constants for the output formats
+const (
+	formatPlain string = "plain"
+	formatJSON  string = "json"
+	formatCSV   string = "csv"
+)
+
+var outputFormats = append([]string{formatPlain, formatJSON, formatCSV}, passwordManagerFormats...)
+
+// passwordRecord is a generated password and what's output along with it in
+// the JSON and CSV formats
+type passwordRecord struct {
+	passwordEntry
+	Password         string `json:"password,omitempty"`
+	Hash             string `json:"hash,omitempty"`
+	ThrottledScore   *int   `json:"throttled_score,omitempty"`
+	UnthrottledScore *int   `json:"unthrottled_score,omitempty"`
+}
+
+// passwordOutput is how to output generated passwords, from the output flags
+// shared by the commands which generate them
+type passwordOutput struct {
+	score     bool
+	clip      bool
+	ephemeral bool
+	qr        qrOptions
+	hash      hashOptions
+	format    string
+	// File to write the output to instead of stdout, none when empty
+	file  string
+	force bool
+	// Recipients to encrypt the output to, none when it isn't encrypted
+	recipients []age.Recipient
+	// What to generate passwords for, from the input file, none when there
+	// isn't one
+	entries []passwordEntry
+}
+
+// Returns the output flags, checking they can be used together, so a command
+// can fail before doing anything else
+func getPasswordOutput(cmd *cobra.Command) (passwordOutput, error) {
+	var o passwordOutput
+	var err error
+	flags := cmd.Flags()
+
+	// only the root command scores passwords
+	if flags.Lookup(scoreKey) != nil {
+		if o.score, err = flags.GetBool(scoreKey); err != nil {
+			return o, fmt.Errorf("failed to get %s flag: %w", scoreKey, err)
+		}
+	}
+
+	if o.clip, err = getClipFlag(cmd); err != nil {
+		return o, err
+	}
+
+	if o.ephemeral, err = getEphemeralFlag(cmd); err != nil {
+		return o, err
+	}
+
+	if o.qr, err = getQRFlags(cmd); err != nil {
+		return o, err
+	}
+
+	if o.hash, err = getHashFlags(cmd); err != nil {
+		return o, err
+	}
+
+	if o.format, err = flags.GetString(formatKey); err != nil {
+		return o, fmt.Errorf("failed to get %s flag: %w", formatKey, err)
+	}
+
+	o.format = strings.ToLower(o.format)
+	if !slices.Contains(outputFormats, o.format) {
+		return o, fmt.Errorf("%s (%s) must be one of %s", formatKey, o.format, strings.Join(outputFormats, ", "))
+	}
+
+	if o.format != formatPlain && o.qr.terminal {
+		return o, fmt.Errorf("--%s can only be drawn with the %s format", qrKey, formatPlain)
+	}
+
+	if o.file, err = flags.GetString(outputFileKey); err != nil {
+		return o, fmt.Errorf("failed to get %s flag: %w", outputFileKey, err)
+	}
+
+	if o.force, err = flags.GetBool(forceKey); err != nil {
+		return o, fmt.Errorf("failed to get %s flag: %w", forceKey, err)
+	}
+
+	if o.recipients, err = getEncryptToFlag(cmd); err != nil {
+		return o, err
+	}
+
+	if o.entries, err = getInputFileFlag(cmd); err != nil {
+		return o, err
+	}
+
+	switch {
+	case o.clip && (o.entries != nil || isPasswordManagerFormat(o.format)):
+		// the rest would be lost
+		return o, fmt.Errorf(
+			"--%s copies a single password, it can't be used with an --%s or --%s %s",
+			clipKey,
+			inputFileKey,
+			formatKey,
+			o.format,
+		)
+	case isPasswordManagerFormat(o.format) && flags.Lookup(inputFileKey) == nil:
+		return o, fmt.Errorf("--%s %s is only for generating passwords for an --%s", formatKey, o.format, inputFileKey)
+	case isPasswordManagerFormat(o.format) && o.entries == nil:
+		return o, fmt.Errorf("--%s %s needs an --%s of titles, usernames and URLs", formatKey, o.format, inputFileKey)
+	case isPasswordManagerFormat(o.format) && o.hash.algorithm != "":
+		return o, fmt.Errorf("--%s can't be used with --%s %s", hashKey, formatKey, o.format)
+	case o.format == formatPlain && o.entries != nil:
+		return o, fmt.Errorf("--%s needs a --%s other than %s", inputFileKey, formatKey, formatPlain)
+	}
+
+	return o, nil
+}
+
+// Outputs the passwords as the flags ask: copied to the clipboard, or
+// formatted and then written to a file, encrypted, shown on the alternate
+// screen or printed
+func (o passwordOutput) write(cmd *cobra.Command, pws []string) error {
+	lines, warning := evaluatePasswords(pws, o.score)
+	if warning != "" {
+		cmd.PrintErrln(warning)
+		cmd.PrintErrln()
+	}
+
+	if o.clip {
+		// a hash isn't secret, so it's printed while the password is copied
+		hashes, err := o.hash.hashAll(pws[:1])
+		if err != nil {
+			return err
+		}
+
+		for _, h := range hashes {
+			fmt.Fprintln(cmd.OutOrStdout(), h)
+		}
+
+		return clipPassword(cmd, pws[0])
+	}
+
+	hashes, err := o.hash.hashAll(pws)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case o.format == formatPlain:
+		lines = o.hash.annotate(lines, hashes)
+	case isPasswordManagerFormat(o.format):
+		if lines, err = formatPasswordManager(o.format, o.entries, pws); err != nil {
+			return fmt.Errorf("failed to format passwords: %w", err)
+		}
+	default:
+		records := newPasswordRecords(o.entries, pws, hashes, o.hash.only, o.score)
+		if lines, err = formatRecords(o.format, records); err != nil {
+			return fmt.Errorf("failed to format passwords: %w", err)
+		}
+	}
+
+	lines, err = o.qr.apply(cmd, pws, lines)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case o.file != "":
+		data := []byte(strings.Join(lines, "\n") + "\n")
+		if len(o.recipients) > 0 {
+			if data, err = encryptLinesToBytes(o.recipients, lines); err != nil {
+				return fmt.Errorf("failed to encrypt passwords: %w", err)
+			}
+		}
+
+		err := writeFileAtomic(o.file, data, 0o600, o.force)
+		if errors.Is(err, ErrFileExists) {
+			return fmt.Errorf("%s already exists, use --%s to overwrite it", o.file, forceKey)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+
+		cmd.PrintErrf("Wrote %d passwords to %s\n", len(pws), o.file)
+	case len(o.recipients) > 0:
+		if err := encryptLines(cmd.OutOrStdout(), o.recipients, lines); err != nil {
+			return fmt.Errorf("failed to encrypt passwords: %w", err)
+		}
+	case o.ephemeral:
+		return showEphemeral(cmd, lines)
+	default:
+		for _, l := range lines {
+			fmt.Fprintln(cmd.OutOrStdout(), l)
+		}
+	}
+
+	return nil
+}
+
+// Returns a record for each password, with its entry, hash and scores when
+// wanted
+func newPasswordRecords(entries []passwordEntry, pws, hashes []string, hashOnly, score bool) []passwordRecord {
+	records := make([]passwordRecord, len(pws))
+	for i, pw := range pws {
+		if entries != nil {
+			records[i].passwordEntry = entries[i]
+		}
+
+		if !hashOnly {
+			records[i].Password = pw
+		}
+
+		if hashes != nil {
+			records[i].Hash = hashes[i]
+		}
+
+		if score {
+			r := zxcvbn.PasswordStrength(pw, nil)
+			records[i].ThrottledScore = &r.ThrottledPasswordEntryScore
+			records[i].UnthrottledScore = &r.UnthrottledPasswordEntryScore
+		}
+	}
+
+	return records
+}
+
+// Returns the records formatted as JSON or CSV, split into lines
+func formatRecords(format string, records []passwordRecord) ([]string, error) {
+	var buf bytes.Buffer
+
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(map[string][]passwordRecord{"passwords": records}); err != nil {
+			return nil, fmt.Errorf("failed to encode JSON (%w)", err)
+		}
+	case formatCSV:
+		if err := writeRecordsCSV(&buf, records); err != nil {
+			return nil, fmt.Errorf("failed to encode CSV (%w)", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown format (%s)", format)
+	}
+
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"), nil
+}
+
+// Writes the records as CSV with a header, leaving out the columns which no
+// record has
+func writeRecordsCSV(buf *bytes.Buffer, records []passwordRecord) error {
+	first := records[0]
+	type column struct {
+		name  string
+		value func(r passwordRecord) string
+	}
+
+	var columns []column
+	if first.Title != "" {
+		columns = append(columns,
+			column{"title", func(r passwordRecord) string { return r.Title }},
+			column{"username", func(r passwordRecord) string { return r.Username }},
+			column{"url", func(r passwordRecord) string { return r.URL }},
+		)
+	}
+	if first.Password != "" {
+		columns = append(columns, column{"password", func(r passwordRecord) string { return r.Password }})
+	}
+	if first.Hash != "" {
+		columns = append(columns, column{"hash", func(r passwordRecord) string { return r.Hash }})
+	}
+	if first.ThrottledScore != nil {
+		columns = append(columns,
+			column{"throttled_score", func(r passwordRecord) string { return strconv.Itoa(*r.ThrottledScore) }},
+			column{"unthrottled_score", func(r passwordRecord) string { return strconv.Itoa(*r.UnthrottledScore) }},
+		)
+	}
+
+	w := csv.NewWriter(buf)
+	row := make([]string, len(columns))
+	for i, c := range columns {
+		row[i] = c.name
+	}
+	if err := w.Write(row); err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		for i, c := range columns {
+			row[i] = c.value(r)
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+
+	return w.Error()
+}
+
+// Adds the flags for outputting generated passwords to a command
+func addPasswordOutputFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.Bool(forceKey, false, "overwrite --output_file or the --qr_png files if they already exist")
+	addClipFlags(flags)
+	addEphemeralFlags(cmd)
+	addQRFlags(cmd)
+	addQRSSIDFlag(flags)
+	addHashFlags(flags)
+	addEncryptToFlag(flags)
+
+	flags.String(
+		formatKey,
+		formatPlain,
+		fmt.Sprintf("output format, valid values: %s", strings.Join(outputFormats, ", ")),
+	)
+	flags.String(
+		outputFileKey,
+		"",
+		"file to write the output to instead of stdout, created readable only by you, it must not exist unless --force is given",
+	)
+
+	cmd.MarkFlagsMutuallyExclusive(outputFileKey, clipKey)
+	cmd.MarkFlagsMutuallyExclusive(outputFileKey, ephemeralKey)
+	cmd.MarkFlagsMutuallyExclusive(outputFileKey, qrKey)
+	// only the bare password is copied
+	cmd.MarkFlagsMutuallyExclusive(clipKey, formatKey)
+	// these would show or save the passwords unencrypted
+	cmd.MarkFlagsMutuallyExclusive(encryptToKey, clipKey)
+	cmd.MarkFlagsMutuallyExclusive(encryptToKey, ephemeralKey)
+	cmd.MarkFlagsMutuallyExclusive(encryptToKey, qrKey)
+	cmd.MarkFlagsMutuallyExclusive(encryptToKey, qrPNGKey)
+}
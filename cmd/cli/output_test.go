@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func newTestOutputCmd(t *testing.T, args ...string) (*cobra.Command, *bytes.Buffer) {
+	t.Helper()
+
+	cmd := &cobra.Command{}
+	addPasswordOutputFlags(cmd)
+	if err := cmd.ParseFlags(args); err != nil {
+		t.Fatalf("ParseFlags(%q) error = %v", args, err)
+	}
+
+	if err := cmd.ValidateFlagGroups(); err != nil {
+		t.Fatalf("ValidateFlagGroups(%q) error = %v", args, err)
+	}
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+
+	return cmd, &out
+}
+
+func TestGetPasswordOutput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		args    []string
+		wantErr bool
+	}{
+		{nil, false},
+		{[]string{"--format", "JSON"}, false},
+		{[]string{"--format", "csv", "--qr_png", "dir"}, false},
+		{[]string{"--format", "xml"}, true},
+		{[]string{"--format", "json", "--qr"}, true},
+	}
+
+	for _, tt := range tests {
+		cmd, _ := newTestOutputCmd(t, tt.args...)
+		if _, err := getPasswordOutput(cmd); (err != nil) != tt.wantErr {
+			t.Errorf("getPasswordOutput(%q) error = %v, wantErr %t", tt.args, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPasswordOutputFlagGroups(t *testing.T) {
+	t.Parallel()
+
+	tests := [][]string{
+		{"--clip", "--qr_png", "dir"},
+		{"--clip", "--format", "json"},
+		{"--clip", "--output_file", "passwords.txt"},
+	}
+
+	for _, args := range tests {
+		cmd := &cobra.Command{}
+		addPasswordOutputFlags(cmd)
+		if err := cmd.ParseFlags(args); err != nil {
+			t.Fatalf("ParseFlags(%q) error = %v", args, err)
+		}
+
+		if err := cmd.ValidateFlagGroups(); err == nil {
+			t.Errorf("ValidateFlagGroups(%q) error = nil, want one", args)
+		}
+	}
+}
+
+func TestFormatRecords(t *testing.T) {
+	t.Parallel()
+
+	score := 4
+	tests := []struct {
+		format  string
+		records []passwordRecord
+		want    string
+	}{
+		{
+			formatJSON,
+			[]passwordRecord{{Password: "a<b>&c"}},
+			"{\n  \"passwords\": [\n    {\n      \"password\": \"a<b>&c\"\n    }\n  ]\n}",
+		},
+		{
+			formatJSON,
+			[]passwordRecord{{Hash: "h", ThrottledScore: &score, UnthrottledScore: &score}},
+			"{\n  \"passwords\": [\n    {\n      \"hash\": \"h\",\n      \"throttled_score\": 4,\n      \"unthrottled_score\": 4\n    }\n  ]\n}",
+		},
+		{
+			formatCSV,
+			[]passwordRecord{{Password: "a,b", Hash: "h1"}, {Password: `c"d`, Hash: "h2"}},
+			"password,hash\n\"a,b\",h1\n\"c\"\"d\",h2",
+		},
+		{
+			formatCSV,
+			[]passwordRecord{{Password: "p", ThrottledScore: &score, UnthrottledScore: &score}},
+			"password,throttled_score,unthrottled_score\np,4,4",
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := formatRecords(tt.format, tt.records)
+		if err != nil {
+			t.Fatalf("formatRecords(%s) error = %v", tt.format, err)
+		}
+
+		if strings.Join(got, "\n") != tt.want {
+			t.Errorf("formatRecords(%s) = %q, want %q", tt.format, strings.Join(got, "\n"), tt.want)
+		}
+	}
+}
+
+func TestPasswordOutputWriteFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "passwords.json")
+	pws := []string{"one-two", "three-four"}
+
+	cmd, out := newTestOutputCmd(t, "--format", "json", "--output_file", path)
+	o, err := getPasswordOutput(cmd)
+	if err != nil {
+		t.Fatalf("getPasswordOutput() error = %v", err)
+	}
+
+	if err := o.write(cmd, pws); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+
+	if out.Len() != 0 {
+		t.Errorf("write() printed %q, want nothing on stdout", out.String())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	var got struct{ Passwords []passwordRecord }
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal(%q) error = %v", data, err)
+	}
+
+	if len(got.Passwords) != 2 || got.Passwords[0].Password != pws[0] || got.Passwords[1].Password != pws[1] {
+		t.Errorf("output file = %q, want the passwords %q", data, pws)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("output file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if err := o.write(cmd, pws); err == nil {
+		t.Errorf("write() over an existing file error = nil, want one without --force")
+	}
+
+	// --force over a world readable file mustn't leave the passwords in it
+	if err := os.Chmod(path, 0o644); err != nil {
+		t.Fatalf("Chmod() error = %v", err)
+	}
+
+	o.force = true
+	if err := o.write(cmd, pws[:1]); err != nil {
+		t.Errorf("write() with --force error = %v", err)
+	}
+
+	if info, err = os.Stat(path); err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("output file mode after --force = %v, want 0600", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,355 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Constant for the input file flag key
+const inputFileKey string = "input_file"
+
+// Constants for the password manager import formats
+const (
+	formatKeePassXML    string = "keepass-xml"
+	formatBitwardenJSON string = "bitwarden-json"
+	format1PasswordCSV  string = "1password-csv"
+	formatLastPassCSV   string = "lastpass-csv"
+)
+
+var passwordManagerFormats = []string{formatKeePassXML, formatBitwardenJSON, format1PasswordCSV, formatLastPassCSV}
+
+// passwordEntry is what a password is for, read from the input file
+type passwordEntry struct {
+	Title    string `json:"title,omitempty"`
+	Username string `json:"username,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+// Returns the entries in the input file, none when it isn't given
+func getInputFileFlag(cmd *cobra.Command) ([]passwordEntry, error) {
+	// only the root command generates passwords for an input file
+	if cmd.Flags().Lookup(inputFileKey) == nil {
+		return nil, nil
+	}
+
+	path, err := cmd.Flags().GetString(inputFileKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s flag: %w", inputFileKey, err)
+	}
+
+	if path == "" {
+		return nil, nil
+	}
+
+	entries, err := readPasswordEntriesFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", inputFileKey, err)
+	}
+
+	return entries, nil
+}
+
+// Returns the entries in the CSV file at path
+func readPasswordEntriesFile(path string) ([]passwordEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open entries (%w)", err)
+	}
+	defer f.Close()
+
+	entries, err := readPasswordEntries(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s (%w)", path, err)
+	}
+
+	return entries, nil
+}
+
+// Returns the entries in a CSV file with a header naming its title, username
+// and url columns, in any order. Only title is required.
+func readPasswordEntries(r io.Reader) ([]passwordEntry, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("it's empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV (%w)", err)
+	}
+
+	columns := map[string]int{"title": -1, "username": -1, "url": -1}
+	for i, h := range header {
+		name := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("column (%s) must be one of title, username, url", h)
+		}
+		if columns[name] >= 0 {
+			return nil, fmt.Errorf("column (%s) is given more than once", h)
+		}
+		columns[name] = i
+	}
+
+	if columns["title"] < 0 {
+		return nil, errors.New("it needs a title column")
+	}
+
+	field := func(row []string, name string) string {
+		if i := columns[name]; i >= 0 {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var entries []passwordEntry
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV (%w)", err)
+		}
+
+		e := passwordEntry{field(row, "title"), field(row, "username"), field(row, "url")}
+		if e.Title == "" {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("line %d has no title", line)
+		}
+
+		entries = append(entries, e)
+	}
+
+	if len(entries) == 0 {
+		return nil, errors.New("it has no entries")
+	}
+
+	return entries, nil
+}
+
+// Returns the entries with their passwords in a password manager's import
+// format, split into lines
+func formatPasswordManager(format string, entries []passwordEntry, pws []string) ([]string, error) {
+	var data []byte
+	var err error
+
+	switch format {
+	case formatKeePassXML:
+		data, err = keePassXML(entries, pws, randomKeePassUUID)
+	case formatBitwardenJSON:
+		data, err = bitwardenJSON(entries, pws)
+	case format1PasswordCSV:
+		data, err = passwordManagerCSV(
+			[]string{"Title", "Url", "Username", "Password", "OTPAuth", "Favorite", "Archived", "Tags", "Notes"},
+			entries,
+			pws,
+			func(e passwordEntry, pw string) []string {
+				return []string{e.Title, e.URL, e.Username, pw, "", "false", "false", "", ""}
+			},
+		)
+	case formatLastPassCSV:
+		data, err = passwordManagerCSV(
+			[]string{"url", "username", "password", "totp", "extra", "name", "grouping", "fav"},
+			entries,
+			pws,
+			func(e passwordEntry, pw string) []string {
+				return []string{e.URL, e.Username, pw, "", "", e.Title, "", "0"}
+			},
+		)
+	default:
+		return nil, fmt.Errorf("unknown password manager format (%s)", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+}
+
+// Returns a random KeePass UUID, base64 encoded
+func randomKeePassUUID() string {
+	return base64.StdEncoding.EncodeToString(randomBytes(16))
+}
+
+// KeePass 2 XML, only the elements needed for an import or a new database
+type keePassFile struct {
+	XMLName      xml.Name     `xml:"KeePassFile"`
+	Generator    string       `xml:"Meta>Generator"`
+	DatabaseName string       `xml:"Meta>DatabaseName,omitempty"`
+	Group        keePassGroup `xml:"Root>Group"`
+}
+
+type keePassGroup struct {
+	UUID    string         `xml:"UUID"`
+	Name    string         `xml:"Name"`
+	Entries []keePassEntry `xml:"Entry"`
+}
+
+type keePassEntry struct {
+	UUID    string          `xml:"UUID"`
+	Times   *keePassTimes   `xml:"Times,omitempty"`
+	Strings []keePassString `xml:"String"`
+}
+
+type keePassTimes struct {
+	Creation     string `xml:"CreationTime"`
+	Modification string `xml:"LastModificationTime"`
+	Access       string `xml:"LastAccessTime"`
+	Expiry       string `xml:"ExpiryTime"`
+	Expires      string `xml:"Expires"`
+	UsageCount   int    `xml:"UsageCount"`
+	Moved        string `xml:"LocationChanged"`
+}
+
+type keePassString struct {
+	Key   string       `xml:"Key"`
+	Value keePassValue `xml:"Value"`
+}
+
+type keePassValue struct {
+	Protect string `xml:"ProtectInMemory,attr,omitempty"`
+	// Set in a KDBX database, where the value is encrypted with its inner
+	// random stream
+	Protected string `xml:"Protected,attr,omitempty"`
+	Text      string `xml:",chardata"`
+}
+
+// Returns the entries as a KeePass 2 XML file, in a mempass group
+func keePassXML(entries []passwordEntry, pws []string, newUUID func() string) ([]byte, error) {
+	f := keePassFile{Generator: "mempass", Group: keePassGroup{UUID: newUUID(), Name: "mempass"}}
+	for i, e := range entries {
+		f.Group.Entries = append(f.Group.Entries, keePassEntry{
+			UUID: newUUID(),
+			Strings: []keePassString{
+				{"Title", keePassValue{Text: e.Title}},
+				{"UserName", keePassValue{Text: e.Username}},
+				{"Password", keePassValue{Protect: "True", Text: pws[i]}},
+				{"URL", keePassValue{Text: e.URL}},
+			},
+		})
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="utf-8" standalone="yes"?>` + "\n")
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "\t")
+	if err := enc.Encode(f); err != nil {
+		return nil, fmt.Errorf("failed to encode KeePass XML (%w)", err)
+	}
+	buf.WriteString("\n")
+
+	return buf.Bytes(), nil
+}
+
+// Bitwarden's unencrypted JSON export, only the fields needed for an import
+type bitwardenExport struct {
+	Encrypted bool            `json:"encrypted"`
+	Folders   []struct{}      `json:"folders"`
+	Items     []bitwardenItem `json:"items"`
+}
+
+type bitwardenItem struct {
+	Type     int            `json:"type"`
+	Name     string         `json:"name"`
+	Notes    *string        `json:"notes"`
+	Favorite bool           `json:"favorite"`
+	Login    bitwardenLogin `json:"login"`
+}
+
+type bitwardenLogin struct {
+	URIs     []bitwardenURI `json:"uris"`
+	Username string         `json:"username"`
+	Password string         `json:"password"`
+	TOTP     *string        `json:"totp"`
+}
+
+type bitwardenURI struct {
+	Match *int   `json:"match"`
+	URI   string `json:"uri"`
+}
+
+// Bitwarden item type of a login
+const bitwardenLoginType int = 1
+
+// Returns the entries as a Bitwarden JSON export of logins
+func bitwardenJSON(entries []passwordEntry, pws []string) ([]byte, error) {
+	export := bitwardenExport{Folders: []struct{}{}, Items: []bitwardenItem{}}
+	for i, e := range entries {
+		uris := []bitwardenURI{}
+		if e.URL != "" {
+			uris = append(uris, bitwardenURI{URI: e.URL})
+		}
+
+		export.Items = append(export.Items, bitwardenItem{
+			Type:  bitwardenLoginType,
+			Name:  e.Title,
+			Login: bitwardenLogin{URIs: uris, Username: e.Username, Password: pws[i]},
+		})
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(export); err != nil {
+		return nil, fmt.Errorf("failed to encode Bitwarden JSON (%w)", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Returns the entries as a CSV file with the header, one row for each from
+// row
+func passwordManagerCSV(
+	header []string,
+	entries []passwordEntry,
+	pws []string,
+	row func(e passwordEntry, pw string) []string,
+) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to encode CSV (%w)", err)
+	}
+
+	for i, e := range entries {
+		if err := w.Write(row(e, pws[i])); err != nil {
+			return nil, fmt.Errorf("failed to encode CSV (%w)", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to encode CSV (%w)", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Returns whether the format is a password manager's import format
+func isPasswordManagerFormat(format string) bool {
+	return slices.Contains(passwordManagerFormats, format)
+}
+
+// Adds the input file flag to a flag set
+func addInputFileFlag(flags *pflag.FlagSet) {
+	flags.String(
+		inputFileKey,
+		"",
+		"CSV file with a title column, and optionally username and url columns, to generate a password for each row of, "+
+			"for the password manager formats or added to the json and csv ones",
+	)
+}
@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+var testPasswordManagerPasswords = []string{"one-TWO-3!", `"quoted" <&> pw`, "a,b;c"}
+
+func readTestPasswordEntries(t *testing.T) []passwordEntry {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", "password_managers", "entries.csv"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+
+	entries, err := readPasswordEntries(f)
+	if err != nil {
+		t.Fatalf("readPasswordEntries() error = %v", err)
+	}
+
+	return entries
+}
+
+// Returns KeePass UUIDs counting up from 1, so the output is reproducible
+func countingKeePassUUIDs() func() string {
+	n := 0
+	return func() string {
+		n++
+		return base64.StdEncoding.EncodeToString(fmt.Appendf(nil, "%016d", n))
+	}
+}
+
+func TestReadPasswordEntries(t *testing.T) {
+	t.Parallel()
+
+	got := readTestPasswordEntries(t)
+	want := []passwordEntry{
+		{"GitHub", "alice", "https://github.com"},
+		{`Acme, Inc. "VPN"`, "alice@acme.example", ""},
+		{"Router <home>", "", "http://192.168.1.1/?a=1&b=<2>"},
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("readPasswordEntries() = %q, want %q", got, want)
+	}
+}
+
+func TestReadPasswordEntriesInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"no title column", "username,url\nalice,https://example.com\n"},
+		{"unknown column", "title,password\nGitHub,hunter2\n"},
+		{"repeated column", "title,Title\nGitHub,GitHub\n"},
+		{"no entries", "title,username\n"},
+		{"no title", "title,username\nGitHub,alice\n,bob\n"},
+		{"short row", "title,username\nGitHub\n"},
+	}
+
+	for _, tt := range tests {
+		if got, err := readPasswordEntries(strings.NewReader(tt.input)); err == nil {
+			t.Errorf("%s: readPasswordEntries(%q) = %q, want an error", tt.name, tt.input, got)
+		}
+	}
+}
+
+func TestPasswordManagerFormats(t *testing.T) {
+	t.Parallel()
+
+	entries := readTestPasswordEntries(t)
+
+	tests := []struct {
+		format  string
+		fixture string
+	}{
+		{formatKeePassXML, "keepass.xml"},
+		{formatBitwardenJSON, "bitwarden.json"},
+		{format1PasswordCSV, "1password.csv"},
+		{formatLastPassCSV, "lastpass.csv"},
+	}
+
+	for _, tt := range tests {
+		want, err := os.ReadFile(filepath.Join("testdata", "password_managers", tt.fixture))
+		if err != nil {
+			t.Fatalf("ReadFile(%s) error = %v", tt.fixture, err)
+		}
+
+		var got string
+		if tt.format == formatKeePassXML {
+			// KeePass UUIDs are random, so they're made reproducible here
+			data, err := keePassXML(entries, testPasswordManagerPasswords, countingKeePassUUIDs())
+			if err != nil {
+				t.Fatalf("keePassXML() error = %v", err)
+			}
+			got = string(data)
+		} else {
+			lines, err := formatPasswordManager(tt.format, entries, testPasswordManagerPasswords)
+			if err != nil {
+				t.Fatalf("formatPasswordManager(%s) error = %v", tt.format, err)
+			}
+			got = strings.Join(lines, "\n") + "\n"
+		}
+
+		if got != string(want) {
+			t.Errorf("formatPasswordManager(%s) = %q, want %s %q", tt.format, got, tt.fixture, want)
+		}
+	}
+}
+
+func TestGetPasswordOutputInputFile(t *testing.T) {
+	t.Parallel()
+
+	entries := filepath.Join("testdata", "password_managers", "entries.csv")
+
+	tests := []struct {
+		args    []string
+		wantErr bool
+	}{
+		{[]string{"--input_file", entries, "--format", formatLastPassCSV}, false},
+		{[]string{"--input_file", entries, "--format", formatJSON, "--hash", hashNT}, false},
+		{[]string{"--input_file", entries}, true},
+		{[]string{"--format", formatKeePassXML}, true},
+		{[]string{"--input_file", entries, "--format", formatBitwardenJSON, "--hash", hashNT}, true},
+		{[]string{"--input_file", "missing.csv", "--format", formatCSV}, true},
+		{[]string{"--input_file", entries, "--format", formatBitwardenJSON, "--clip"}, true},
+		{[]string{"--input_file", entries, "--format", formatCSV, "--clip"}, true},
+	}
+
+	for _, tt := range tests {
+		cmd, _ := newTestOutputCmd(t)
+		addInputFileFlag(cmd.Flags())
+		if err := cmd.ParseFlags(tt.args); err != nil {
+			t.Fatalf("ParseFlags(%q) error = %v", tt.args, err)
+		}
+
+		if _, err := getPasswordOutput(cmd); (err != nil) != tt.wantErr {
+			t.Errorf("getPasswordOutput(%q) error = %v, wantErr %t", tt.args, err, tt.wantErr)
+		}
+	}
+
+	// commands without an input file can't use the password manager formats
+	cmd, _ := newTestOutputCmd(t, "--format", formatKeePassXML)
+	if _, err := getPasswordOutput(cmd); err == nil {
+		t.Errorf("getPasswordOutput() without an input file flag error = nil, want one")
+	}
+}
+
+func TestRunRootCmdManyEntries(t *testing.T) {
+	t.Parallel()
+
+	// more than libpass generates at once
+	entries := "title,username\n"
+	for i := range maxPasswordsPerBatch + 2 {
+		entries += fmt.Sprintf("Site %d,user%d\n", i, i)
+	}
+
+	path := filepath.Join(t.TempDir(), "entries.csv")
+	if err := os.WriteFile(path, []byte(entries), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	cmd := &cobra.Command{}
+	addPasswordOutputFlags(cmd)
+	addInputFileFlag(cmd.Flags())
+	addConfigFlags(cmd.Flags())
+	if err := cmd.ParseFlags([]string{"--input_file", path, "--format", formatBitwardenJSON}); err != nil {
+		t.Fatalf("ParseFlags() error = %v", err)
+	}
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+
+	if err := runRootCmd(cmd, nil); err != nil {
+		t.Fatalf("runRootCmd() error = %v", err)
+	}
+
+	var got bitwardenExport
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal(%q) error = %v", out.String(), err)
+	}
+
+	if len(got.Items) != maxPasswordsPerBatch+2 {
+		t.Fatalf("got %d items, want %d", len(got.Items), maxPasswordsPerBatch+2)
+	}
+
+	for i, item := range got.Items {
+		if item.Name != fmt.Sprintf("Site %d", i) || item.Login.Password == "" {
+			t.Errorf("item %d = %+v, want Site %d with a password", i, item, i)
+		}
+	}
+}
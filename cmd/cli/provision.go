@@ -0,0 +1,500 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/eljamo/libpass/v8/config"
+	"github.com/eljamo/libpass/v8/config/option"
+	"github.com/eljamo/libpass/v8/service"
+	"github.com/eljamo/zxcvbn"
+	"github.com/spf13/cobra"
+)
+
+// Constants for the provision output formats
+const (
+	formatChpasswd  string = "chpasswd"
+	formatCloudInit string = "cloud-init"
+)
+
+var provisionFormats = []string{formatCSV, formatJSON, formatChpasswd, formatCloudInit}
+
+// Entropy under which a provisioned password is reported as weak
+const provisionWeakEntropyBits float64 = 64
+
+// Most times the passwords which repeat others in the batch are generated
+// again, before the config is deemed too small to give every user their own
+const provisionMaxAttempts int = 10
+
+var provisionCmd = &cobra.Command{
+	Use:   "provision USERS_CSV",
+	Short: "Generate a unique password for each user in a CSV file",
+	Long: `Generate a password for each user in a CSV file, with no two users getting the
+same one, for creating accounts in bulk. The file needs a header naming its
+username column, and optionally preset and policy columns, which pick a
+built-in preset or a custom config JSON file for that user. They're layered
+on top of the given preset, custom config and flags, in that order. Relative
+policy paths are relative to the directory of the users file.
+
+The passwords are written as csv, json, chpasswd input or a cloud-init
+chpasswd config, to stdout or --output_file, which is created readable only
+by you. A summary of the entropy of each config and any weak passwords is
+printed to stderr`,
+	Args: cobra.ExactArgs(1),
+	RunE: runProvisionCmd,
+}
+
+// provisionUser is a row of the users file
+type provisionUser struct {
+	Line     int
+	Username string
+	Preset   string
+	Policy   string
+}
+
+// provisionGroup is the users whose passwords are generated with the same
+// config, by index
+type provisionGroup struct {
+	name    string
+	cfg     *config.Settings
+	entropy entropyEstimate
+	users   []int
+}
+
+func runProvisionCmd(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString(formatKey)
+	if err != nil {
+		return fmt.Errorf("failed to get %s flag: %w", formatKey, err)
+	}
+
+	format = strings.ToLower(format)
+	if !slices.Contains(provisionFormats, format) {
+		return fmt.Errorf("%s (%s) must be one of %s", formatKey, format, strings.Join(provisionFormats, ", "))
+	}
+
+	outputFile, err := cmd.Flags().GetString(outputFileKey)
+	if err != nil {
+		return fmt.Errorf("failed to get %s flag: %w", outputFileKey, err)
+	}
+
+	force, err := cmd.Flags().GetBool(forceKey)
+	if err != nil {
+		return fmt.Errorf("failed to get %s flag: %w", forceKey, err)
+	}
+
+	recipients, err := getEncryptToFlag(cmd)
+	if err != nil {
+		return err
+	}
+
+	users, err := readProvisionUsersFile(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid users file: %w", err)
+	}
+
+	groups, err := getProvisionGroups(cmd, users)
+	if err != nil {
+		return err
+	}
+
+	pws, err := generateUniquePasswords(len(users), groups, func(cfg *config.Settings) (service.PasswordGeneratorService, error) {
+		return newCmdPasswordGeneratorService(cmd, cfg)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate passwords: %w", err)
+	}
+
+	data, err := formatProvisioned(format, users, pws)
+	if err != nil {
+		return err
+	}
+
+	if len(recipients) > 0 {
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		if data, err = encryptLinesToBytes(recipients, lines); err != nil {
+			return fmt.Errorf("failed to encrypt passwords: %w", err)
+		}
+	}
+
+	if outputFile != "" {
+		err := writeFileAtomic(outputFile, data, 0o600, force)
+		if errors.Is(err, ErrFileExists) {
+			return fmt.Errorf("%s already exists, use --%s to overwrite it", outputFile, forceKey)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write passwords: %w", err)
+		}
+	} else if _, err := cmd.OutOrStdout().Write(data); err != nil {
+		return fmt.Errorf("failed to print passwords: %w", err)
+	}
+
+	if err := writeProvisionSummary(cmd.ErrOrStderr(), users, groups, pws); err != nil {
+		return err
+	}
+
+	if outputFile != "" {
+		cmd.PrintErrf("Wrote %d passwords to %s\n", len(users), outputFile)
+	}
+
+	return nil
+}
+
+// Returns the users in the CSV file at path, with relative policy paths
+// resolved against the file's directory
+func readProvisionUsersFile(path string) ([]provisionUser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open users (%w)", err)
+	}
+	defer f.Close()
+
+	users, err := readProvisionUsers(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s (%w)", path, err)
+	}
+
+	for i, u := range users {
+		if u.Policy != "" && !filepath.IsAbs(u.Policy) {
+			users[i].Policy = filepath.Join(filepath.Dir(path), u.Policy)
+		}
+	}
+
+	return users, nil
+}
+
+// Returns the users in a CSV file with a header naming its username, preset
+// and policy columns, in any order. Only username is required.
+func readProvisionUsers(r io.Reader) ([]provisionUser, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("it's empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV (%w)", err)
+	}
+
+	columns := map[string]int{"username": -1, "preset": -1, "policy": -1}
+	for i, h := range header {
+		name := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("column (%s) must be one of username, preset, policy", h)
+		}
+		if columns[name] >= 0 {
+			return nil, fmt.Errorf("column (%s) is given more than once", h)
+		}
+		columns[name] = i
+	}
+
+	if columns["username"] < 0 {
+		return nil, errors.New("it needs a username column")
+	}
+
+	field := func(row []string, name string) string {
+		if i := columns[name]; i >= 0 {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var users []provisionUser
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV (%w)", err)
+		}
+
+		line, _ := cr.FieldPos(0)
+		u := provisionUser{line, field(row, "username"), field(row, "preset"), field(row, "policy")}
+		if u.Preset != "" {
+			preset, ok := lookupPreset(u.Preset)
+			if !ok {
+				return nil, fmt.Errorf("line %d has an unknown preset (%s)", line, u.Preset)
+			}
+			u.Preset = preset
+		}
+
+		users = append(users, u)
+	}
+
+	if len(users) == 0 {
+		return nil, errors.New("it has no users")
+	}
+
+	usernames := make([]string, len(users))
+	for i, u := range users {
+		usernames[i] = u.Username
+	}
+
+	if err := validateHtpasswdUsers(usernames); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// Returns the users grouped by the config their passwords are generated
+// with, in the order each config is first used
+func getProvisionGroups(cmd *cobra.Command, users []provisionUser) ([]provisionGroup, error) {
+	layers, err := getConfigLayers(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate config: %w", err)
+	}
+
+	policies := map[string]map[string]any{}
+	index := map[[2]string]int{}
+	var groups []provisionGroup
+
+	for i, u := range users {
+		key := [2]string{u.Preset, u.Policy}
+		if g, ok := index[key]; ok {
+			groups[g].users = append(groups[g].users, i)
+			continue
+		}
+
+		if _, ok := policies[u.Policy]; !ok && u.Policy != "" {
+			policy, err := loadCustomConfigJSON(u.Policy)
+			if err != nil {
+				return nil, fmt.Errorf("invalid policy on line %d: %w", u.Line, err)
+			}
+
+			if _, err := splitServerConfig(policy); err != nil {
+				return nil, fmt.Errorf("invalid policy on line %d: %w", u.Line, err)
+			}
+
+			policies[u.Policy] = policy
+		}
+
+		extra := maps.Clone(policies[u.Policy])
+		if u.Preset != "" {
+			if extra == nil {
+				extra = map[string]any{}
+			}
+			extra[option.ConfigKeyPreset] = u.Preset
+		}
+
+		cfg, err := layers.merge(extra)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config on line %d: %w", u.Line, err)
+		}
+
+		est, err := estimateEntropy(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config on line %d: %w", u.Line, err)
+		}
+
+		index[key] = len(groups)
+		groups = append(groups, provisionGroup{provisionGroupName(layers.preset, u), cfg, est, []int{i}})
+	}
+
+	return groups, nil
+}
+
+// Returns the name of the config a user's password is generated with
+func provisionGroupName(basePreset string, u provisionUser) string {
+	name := basePreset
+	if u.Preset != "" {
+		name = u.Preset
+	}
+
+	if u.Policy != "" {
+		name += " + " + u.Policy
+	}
+
+	return name
+}
+
+// Returns n passwords, one for each user in the groups, generated with
+// a generator created for each group's config. Passwords which repeat earlier
+// ones are generated again, so each user gets their own.
+func generateUniquePasswords(
+	n int,
+	groups []provisionGroup,
+	newGenerator func(cfg *config.Settings) (service.PasswordGeneratorService, error),
+) ([]string, error) {
+	pws := make([]string, n)
+	seen := make(map[string]bool, n)
+
+	for _, g := range groups {
+		g.cfg.NumPasswords = min(len(g.users), maxPasswordsPerBatch)
+		pgs, err := newGenerator(g.cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create password generator service (%w)", err)
+		}
+
+		need := g.users
+
+		for attempt := 0; len(need) > 0; attempt++ {
+			if attempt == provisionMaxAttempts {
+				return nil, fmt.Errorf("%s keeps generating the same passwords, it needs more entropy", g.name)
+			}
+
+			generated, err := samplePasswords(pgs, len(need))
+			if err != nil {
+				return nil, err
+			}
+
+			var repeated []int
+			for i, u := range need {
+				if seen[generated[i]] {
+					repeated = append(repeated, u)
+					continue
+				}
+
+				seen[generated[i]] = true
+				pws[u] = generated[i]
+			}
+			need = repeated
+		}
+	}
+
+	return pws, nil
+}
+
+// Returns the users with their passwords in the format
+func formatProvisioned(format string, users []provisionUser, pws []string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	switch format {
+	case formatCSV:
+		w := csv.NewWriter(&buf)
+		rows := [][]string{{"username", "password"}}
+		for i, u := range users {
+			rows = append(rows, []string{u.Username, pws[i]})
+		}
+
+		if err := w.WriteAll(rows); err != nil {
+			return nil, fmt.Errorf("failed to encode CSV: %w", err)
+		}
+	case formatJSON:
+		type user struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+
+		out := make([]user, len(users))
+		for i, u := range users {
+			out[i] = user{u.Username, pws[i]}
+		}
+
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(map[string][]user{"users": out}); err != nil {
+			return nil, fmt.Errorf("failed to encode JSON: %w", err)
+		}
+	case formatChpasswd:
+		for i, u := range users {
+			buf.WriteString(u.Username + ":" + pws[i] + "\n")
+		}
+	case formatCloudInit:
+		// JSON strings are YAML double quoted scalars, so no password can
+		// break out of its value
+		buf.WriteString("#cloud-config\nchpasswd:\n  users:\n")
+		for i, u := range users {
+			name, err := marshalSetting(u.Username)
+			if err != nil {
+				return nil, err
+			}
+
+			pw, err := marshalSetting(pws[i])
+			if err != nil {
+				return nil, err
+			}
+
+			fmt.Fprintf(&buf, "    - name: %s\n      password: %s\n      type: text\n", name, pw)
+		}
+	default:
+		return nil, fmt.Errorf("unknown format (%s)", format)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Writes the entropy of each config and the users with weak passwords, those
+// from a config under provisionWeakEntropyBits or scored as crackable by an
+// attacker with no rate limit
+func writeProvisionSummary(w io.Writer, users []provisionUser, groups []provisionGroup, pws []string) error {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Generated %d unique passwords\n", len(users))
+	fmt.Fprintln(tw, "Config\tUsers\tEntropy (bits)\tTypical length")
+	for _, g := range groups {
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%d\n", g.name, len(g.users), g.entropy.Bits, g.entropy.TypicalLength)
+	}
+
+	group := make([]provisionGroup, len(users))
+	for _, g := range groups {
+		for _, i := range g.users {
+			group[i] = g
+		}
+	}
+
+	var weak []string
+	for i, u := range users {
+		var reasons []string
+		if group[i].entropy.Bits < provisionWeakEntropyBits {
+			reasons = append(reasons, fmt.Sprintf("under %.0f bits of entropy", provisionWeakEntropyBits))
+		}
+
+		if zxcvbn.PasswordStrength(pws[i], nil).UnthrottledPasswordEntryScore <= weakUnthrottledScoreThreshold {
+			reasons = append(reasons, "crackable with no rate limit")
+		}
+
+		if len(reasons) > 0 {
+			weak = append(weak, fmt.Sprintf("  line %d\t%s\t%s", u.Line, u.Username, strings.Join(reasons, ", ")))
+		}
+	}
+
+	if len(weak) > 0 {
+		fmt.Fprintf(tw, "WARNING: Weak passwords (%d):\n", len(weak))
+		for _, line := range weak {
+			fmt.Fprintln(tw, line)
+		}
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+
+	return nil
+}
+
+func init() {
+	provisionCmd.Flags().String(
+		formatKey,
+		formatCSV,
+		"output format, one of "+strings.Join(provisionFormats, ", "),
+	)
+	provisionCmd.Flags().String(
+		outputFileKey,
+		"",
+		"file to write the passwords to instead of stdout, created readable only by you, it must not exist unless --force is given",
+	)
+	provisionCmd.Flags().Bool(forceKey, false, "overwrite --output_file if it already exists")
+	addEncryptToFlag(provisionCmd.Flags())
+
+	addConfigFlags(provisionCmd.Flags())
+
+	rootCmd.AddCommand(provisionCmd)
+}
@@ -0,0 +1,245 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eljamo/libpass/v8/config"
+	"github.com/eljamo/libpass/v8/service"
+)
+
+// Generates from a fixed list of passwords, repeating it when it runs out
+type listPasswordGenerator struct {
+	cfg  *config.Settings
+	pws  []string
+	next int
+}
+
+func (g *listPasswordGenerator) Generate() ([]string, error) {
+	pws := make([]string, g.cfg.NumPasswords)
+	for i := range pws {
+		pws[i] = g.pws[g.next%len(g.pws)]
+		g.next++
+	}
+
+	return pws, nil
+}
+
+func TestReadProvisionUsers(t *testing.T) {
+	t.Parallel()
+
+	input := "\ufeffPolicy, Username ,preset\n,alice,\npolicy.json,bob,xkcd\n,\"carol smith\",\n"
+	got, err := readProvisionUsers(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("readProvisionUsers() error = %v", err)
+	}
+
+	want := []provisionUser{
+		{2, "alice", "", ""},
+		{3, "bob", "XKCD", "policy.json"},
+		{4, "carol smith", "", ""},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("readProvisionUsers() = %v, want %v", got, want)
+	}
+}
+
+func TestReadProvisionUsersFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	abs := filepath.Join(dir, "abs.json")
+	path := filepath.Join(dir, "users.csv")
+	input := "username,policy\nalice,\nbob,policies/strict.json\ncarol," + abs + "\n"
+	if err := os.WriteFile(path, []byte(input), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readProvisionUsersFile(path)
+	if err != nil {
+		t.Fatalf("readProvisionUsersFile() error = %v", err)
+	}
+
+	want := []string{"", filepath.Join(dir, "policies", "strict.json"), abs}
+	for i, u := range got {
+		if u.Policy != want[i] {
+			t.Errorf("readProvisionUsersFile()[%d].Policy = %q, want %q", i, u.Policy, want[i])
+		}
+	}
+}
+
+func TestReadProvisionUsersInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"no users", "username\n"},
+		{"no username column", "preset\nxkcd\n"},
+		{"unknown column", "username,password\nalice,secret\n"},
+		{"repeated column", "username,Username\nalice,bob\n"},
+		{"empty username", "username\nalice\n\"\"\n"},
+		{"colon", "username\nali:ce\n"},
+		{"repeated user", "username\nalice\nbob\nalice\n"},
+		{"unknown preset", "username,preset\nalice,nope\n"},
+	}
+
+	for _, tt := range tests {
+		if _, err := readProvisionUsers(strings.NewReader(tt.input)); err == nil {
+			t.Errorf("%s: readProvisionUsers() error = nil, want one", tt.name)
+		}
+	}
+}
+
+func TestGenerateUniquePasswords(t *testing.T) {
+	t.Parallel()
+
+	newGroups := func() []provisionGroup {
+		return []provisionGroup{
+			{name: "a", cfg: &config.Settings{}, users: []int{0, 2, 3}},
+			{name: "b", cfg: &config.Settings{}, users: []int{1}},
+		}
+	}
+
+	lists := map[string][]string{
+		"a": {"one", "one", "two", "one", "three"},
+		"b": {"two", "three", "four"},
+	}
+	newGenerator := func(lists map[string][]string) func(cfg *config.Settings) (service.PasswordGeneratorService, error) {
+		n := 0
+		names := []string{"a", "b"}
+		return func(cfg *config.Settings) (service.PasswordGeneratorService, error) {
+			g := &listPasswordGenerator{cfg: cfg, pws: lists[names[n]]}
+			n++
+			return g, nil
+		}
+	}
+
+	got, err := generateUniquePasswords(4, newGroups(), newGenerator(lists))
+	if err != nil {
+		t.Fatalf("generateUniquePasswords() error = %v", err)
+	}
+
+	want := []string{"one", "four", "three", "two"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("generateUniquePasswords() = %q, want %q", got, want)
+	}
+
+	// more users than libpass generates at once
+	many := provisionGroup{name: "many", cfg: &config.Settings{}}
+	var manyPasswords []string
+	for i := range maxPasswordsPerBatch + 2 {
+		many.users = append(many.users, i)
+	}
+	for i := range 4 * maxPasswordsPerBatch {
+		manyPasswords = append(manyPasswords, fmt.Sprintf("pw%d", i))
+	}
+	// a repeat within the first batch
+	manyPasswords[3] = manyPasswords[1]
+
+	got, err = generateUniquePasswords(len(many.users), []provisionGroup{many}, func(cfg *config.Settings) (service.PasswordGeneratorService, error) {
+		return &listPasswordGenerator{cfg: cfg, pws: manyPasswords}, nil
+	})
+	if err != nil {
+		t.Fatalf("generateUniquePasswords() for %d users error = %v", len(many.users), err)
+	}
+
+	seen := map[string]bool{}
+	for _, pw := range got {
+		seen[pw] = true
+	}
+	if len(got) != len(many.users) || len(seen) != len(many.users) {
+		t.Errorf("generateUniquePasswords() = %q, want %d unique passwords", got, len(many.users))
+	}
+
+	stuck := map[string][]string{"a": {"same"}, "b": {"other"}}
+	if _, err := generateUniquePasswords(4, newGroups(), newGenerator(stuck)); err == nil {
+		t.Errorf("generateUniquePasswords() with repeating passwords error = nil, want one")
+	}
+}
+
+func TestFormatProvisioned(t *testing.T) {
+	t.Parallel()
+
+	users := []provisionUser{{Username: "alice"}, {Username: "bob smith"}}
+	pws := []string{`a"b:c`, "x,y\\z"}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{formatCSV, "username,password\nalice,\"a\"\"b:c\"\nbob smith,\"x,y\\z\"\n"},
+		{formatJSON, `{
+  "users": [
+    {
+      "username": "alice",
+      "password": "a\"b:c"
+    },
+    {
+      "username": "bob smith",
+      "password": "x,y\\z"
+    }
+  ]
+}
+`},
+		{formatChpasswd, "alice:a\"b:c\nbob smith:x,y\\z\n"},
+		{formatCloudInit, `#cloud-config
+chpasswd:
+  users:
+    - name: "alice"
+      password: "a\"b:c"
+      type: text
+    - name: "bob smith"
+      password: "x,y\\z"
+      type: text
+`},
+	}
+
+	for _, tt := range tests {
+		got, err := formatProvisioned(tt.format, users, pws)
+		if err != nil {
+			t.Errorf("formatProvisioned(%s) error = %v", tt.format, err)
+			continue
+		}
+
+		if string(got) != tt.want {
+			t.Errorf("formatProvisioned(%s) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestWriteProvisionSummary(t *testing.T) {
+	t.Parallel()
+
+	users := []provisionUser{{2, "alice", "", ""}, {3, "bob", "", "weak.json"}, {4, "carol", "", ""}}
+	groups := []provisionGroup{
+		{name: "DEFAULT", entropy: entropyEstimate{Bits: 72.5, TypicalLength: 30}, users: []int{0, 2}},
+		{name: "DEFAULT + weak.json", entropy: entropyEstimate{Bits: 20, TypicalLength: 9}, users: []int{1}},
+	}
+	pws := []string{"::87/BYTES/chasing/mexico/58::", "pellet?BRASS", "password"}
+
+	var buf bytes.Buffer
+	if err := writeProvisionSummary(&buf, users, groups, pws); err != nil {
+		t.Fatalf("writeProvisionSummary() error = %v", err)
+	}
+
+	want := strings.Join([]string{
+		"Generated 3 unique passwords",
+		"Config               Users  Entropy (bits)  Typical length",
+		"DEFAULT              2      72.5            30",
+		"DEFAULT + weak.json  1      20.0            9",
+		"WARNING: Weak passwords (2):",
+		"  line 3  bob    under 64 bits of entropy, crackable with no rate limit",
+		"  line 4  carol  crackable with no rate limit",
+		"",
+	}, "\n")
+	if buf.String() != want {
+		t.Errorf("writeProvisionSummary() = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,238 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Constants for the QR code flag keys
+const (
+	qrKey     string = "qr"
+	qrPNGKey  string = "qr_png"
+	qrSSIDKey string = "qr_ssid"
+)
+
+// Size of each module (QR code pixel) in written PNG files, in pixels
+const qrPNGModuleSize int = 8
+
+// Colours for drawing QR codes as black on white whatever the terminal's
+// theme, as some scanners can't read inverted codes
+const (
+	qrColours      string = "\x1b[30;107m"
+	qrResetColours string = "\x1b[0m"
+)
+
+// qrOptions are how to show passwords as QR codes
+type qrOptions struct {
+	// Draw them in the terminal
+	terminal bool
+	// Directory to write PNG files to, none when empty
+	pngDir string
+	// Overwrite PNG files which already exist
+	force bool
+	// Network name for a Wi-Fi payload, otherwise the QR code holds just the
+	// password
+	ssid string
+}
+
+// Returns the QR code flags
+func getQRFlags(cmd *cobra.Command) (qrOptions, error) {
+	var o qrOptions
+	var err error
+
+	if o.terminal, err = cmd.Flags().GetBool(qrKey); err != nil {
+		return o, fmt.Errorf("failed to get %s flag: %w", qrKey, err)
+	}
+
+	if o.pngDir, err = cmd.Flags().GetString(qrPNGKey); err != nil {
+		return o, fmt.Errorf("failed to get %s flag: %w", qrPNGKey, err)
+	}
+
+	if o.force, err = cmd.Flags().GetBool(forceKey); err != nil {
+		return o, fmt.Errorf("failed to get %s flag: %w", forceKey, err)
+	}
+
+	// commands which know the network take its name from their own flag
+	if cmd.Flags().Lookup(qrSSIDKey) == nil {
+		return o, nil
+	}
+
+	if o.ssid, err = cmd.Flags().GetString(qrSSIDKey); err != nil {
+		return o, fmt.Errorf("failed to get %s flag: %w", qrSSIDKey, err)
+	}
+
+	if o.ssid != "" && !o.terminal && o.pngDir == "" {
+		return o, fmt.Errorf("--%s needs --%s or --%s", qrSSIDKey, qrKey, qrPNGKey)
+	}
+
+	return o, nil
+}
+
+// Returns what to encode in the QR code for pw
+func (o qrOptions) payload(pw string) string {
+	if o.ssid == "" {
+		return pw
+	}
+
+	return wifiQRPayload(o.ssid, pw)
+}
+
+// Returns lines with each password's QR code drawn after its line when
+// drawing them in the terminal, and writes the PNG files
+func (o qrOptions) apply(cmd *cobra.Command, pws []string, lines []string) ([]string, error) {
+	if o.pngDir != "" {
+		paths, err := writeQRPNGs(o.pngDir, pws, o.payload, o.force)
+		if errors.Is(err, ErrFileExists) {
+			return nil, fmt.Errorf("failed to write QR codes: %w, use --%s to overwrite it", err, forceKey)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to write QR codes: %w", err)
+		}
+
+		for _, p := range paths {
+			cmd.PrintErrf("Wrote a QR code to %s\n", p)
+		}
+	}
+
+	if !o.terminal {
+		return lines, nil
+	}
+
+	withQR := make([]string, 0, len(lines))
+	for i, pw := range pws {
+		code, err := renderQR(o.payload(pw))
+		if err != nil {
+			return nil, fmt.Errorf("failed to draw QR code: %w", err)
+		}
+
+		withQR = append(withQR, lines[i])
+		withQR = append(withQR, code...)
+	}
+
+	return withQR, nil
+}
+
+// Returns a QR code of payload drawn with Unicode half blocks, two rows of
+// modules to a line, including the quiet zone around it
+func renderQR(payload string) ([]string, error) {
+	q, err := qrcode.New(payload, qrcode.Medium)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code (%w)", err)
+	}
+
+	bits := q.Bitmap()
+	rows := make([]string, 0, (len(bits)+1)/2)
+	for y := 0; y < len(bits); y += 2 {
+		var b strings.Builder
+		b.WriteString(qrColours)
+		for x := range bits[y] {
+			top := bits[y][x]
+			bottom := y+1 < len(bits) && bits[y+1][x]
+
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(qrResetColours)
+		rows = append(rows, b.String())
+	}
+
+	return rows, nil
+}
+
+// Writes a PNG QR code for each password to dir, readable only by the user,
+// returning the paths written. Existing files are only replaced when force is
+// set, and a directory others can write to is refused, as they could swap the
+// files for their own.
+func writeQRPNGs(dir string, pws []string, payload func(string) string, force bool) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create directory (%w)", err)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check directory (%w)", err)
+	}
+
+	if perm := info.Mode().Perm(); perm&0o022 != 0 {
+		return nil, fmt.Errorf("%s is writable by other users (mode %o)", dir, perm)
+	}
+
+	paths := make([]string, 0, len(pws))
+	for i, pw := range pws {
+		q, err := qrcode.New(payload(pw), qrcode.Medium)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode QR code (%w)", err)
+		}
+
+		img, err := q.PNG(-qrPNGModuleSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode PNG (%w)", err)
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("mempass-%d.png", i+1))
+		if err := writeFileAtomic(path, img, 0o600, force); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// Returns the payload phones read to join a WPA network, escaping the
+// characters with special meanings in it
+func wifiQRPayload(ssid, pw string) string {
+	return "WIFI:T:WPA;S:" + wifiQREscaper.Replace(ssid) + ";P:" + wifiQREscaper.Replace(pw) + ";;"
+}
+
+// Escapes the characters in a Wi-Fi QR code field which would otherwise end
+// it or be read as a quote
+var wifiQREscaper = strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, `"`, `\"`, `:`, `\:`)
+
+// Adds the QR code flags to a command, which can't be used along with copying
+// to the clipboard, as only the password would be copied
+func addQRFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.Bool(
+		qrKey,
+		false,
+		"draw a QR code of each password in the terminal, for scanning with a phone",
+	)
+	flags.String(
+		qrPNGKey,
+		"",
+		"directory to write a PNG QR code of each password to, readable only by you, "+
+			"the files must not exist unless --force is given",
+	)
+	// commands with an output file share its flag
+	if flags.Lookup(forceKey) == nil {
+		flags.Bool(forceKey, false, "overwrite the --qr_png files if they already exist")
+	}
+	cmd.MarkFlagsMutuallyExclusive(clipKey, qrKey)
+	cmd.MarkFlagsMutuallyExclusive(clipKey, qrPNGKey)
+}
+
+// Adds the flag for putting a Wi-Fi network name in the QR codes to a flag set
+func addQRSSIDFlag(flags *pflag.FlagSet) {
+	flags.String(
+		qrSSIDKey,
+		"",
+		"network name to put in the QR codes along with the password, "+
+			"so a phone scanning them joins the WPA Wi-Fi network, e.g. with the WIFI preset",
+	)
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"errors"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWiFiQRPayload(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		ssid string
+		pw   string
+		want string
+	}{
+		{"home", "correct-horse", "WIFI:T:WPA;S:home;P:correct-horse;;"},
+		{`"cafe"`, `a;b,c:d\e`, `WIFI:T:WPA;S:\"cafe\";P:a\;b\,c\:d\\e;;`},
+	}
+
+	for _, tt := range tests {
+		if got := wifiQRPayload(tt.ssid, tt.pw); got != tt.want {
+			t.Errorf("wifiQRPayload(%q, %q) = %q, want %q", tt.ssid, tt.pw, got, tt.want)
+		}
+	}
+}
+
+func TestRenderQR(t *testing.T) {
+	t.Parallel()
+
+	rows, err := renderQR("WIFI:T:WPA;S:home;P:correct-horse;;")
+	if err != nil {
+		t.Fatalf("renderQR() error = %v", err)
+	}
+
+	// a version 3 code is 29 modules, plus a 4 module quiet zone on each side
+	if got, want := len(rows), (29+8+1)/2; got != want {
+		t.Errorf("renderQR() = %d rows, want %d", got, want)
+	}
+
+	for i, r := range rows {
+		if !strings.HasPrefix(r, qrColours) || !strings.HasSuffix(r, qrResetColours) {
+			t.Errorf("row %d = %q, want it coloured black on white", i, r)
+		}
+	}
+
+	// the quiet zone's top and bottom edges are blank
+	blank := qrColours + strings.Repeat(" ", 29+8) + qrResetColours
+	if rows[0] != blank || rows[len(rows)-1] != blank {
+		t.Errorf("renderQR() edges = %q, %q, want blank", rows[0], rows[len(rows)-1])
+	}
+}
+
+func TestWriteQRPNGs(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "codes")
+	paths, err := writeQRPNGs(dir, []string{"one", "two"}, func(pw string) string { return pw }, false)
+	if err != nil {
+		t.Fatalf("writeQRPNGs() error = %v", err)
+	}
+
+	if len(paths) != 2 {
+		t.Fatalf("writeQRPNGs() = %q, want 2 paths", paths)
+	}
+
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatalf("Stat(%q) error = %v", p, err)
+		}
+
+		if perm := info.Mode().Perm(); perm != 0o600 {
+			t.Errorf("%s mode = %o, want 600", p, perm)
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			t.Fatalf("Open(%q) error = %v", p, err)
+		}
+
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("png.Decode(%q) error = %v", p, err)
+		}
+
+		// a version 1 code is 21 modules, plus the quiet zone
+		if got, want := img.Bounds().Dx(), (21+8)*qrPNGModuleSize; got != want {
+			t.Errorf("%s width = %d, want %d", p, got, want)
+		}
+	}
+}
+
+func TestWriteQRPNGsExisting(t *testing.T) {
+	t.Parallel()
+
+	payload := func(pw string) string { return pw }
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mempass-1.png")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if _, err := writeQRPNGs(dir, []string{"one"}, payload, false); !errors.Is(err, ErrFileExists) {
+		t.Errorf("writeQRPNGs() over an existing file error = %v, want %v", err, ErrFileExists)
+	}
+
+	if _, err := writeQRPNGs(dir, []string{"one"}, payload, true); err != nil {
+		t.Fatalf("writeQRPNGs() with force error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat(%q) error = %v", path, err)
+	}
+
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("%s mode = %o, want 600", path, perm)
+	}
+
+	shared := filepath.Join(t.TempDir(), "shared")
+	if err := os.Mkdir(shared, 0o700); err != nil {
+		t.Fatalf("Mkdir() error = %v", err)
+	}
+	// set after creating it, as the umask would clear the write bits
+	if err := os.Chmod(shared, 0o777); err != nil {
+		t.Fatalf("Chmod() error = %v", err)
+	}
+
+	if _, err := writeQRPNGs(shared, []string{"one"}, payload, true); err == nil {
+		t.Error("writeQRPNGs() to a directory others can write to error = nil, want one")
+	}
+}
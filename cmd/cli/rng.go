@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand/v2"
+
+	"github.com/eljamo/libpass/v8/config"
+	"github.com/eljamo/libpass/v8/service"
+	"github.com/spf13/cobra"
+)
+
+// Number of possible values returned by GenerateDigit
+const rngDigitMax int = 10
+
+// Bound of the deterministic Generate, the largest int on every platform, as
+// math.MaxInt would give 32 and 64 bit builds different numbers
+const rngGenerateMax int = math.MaxInt32
+
+// deterministicRNGService implements service.RNGService with a ChaCha8 stream,
+// so the same seed always produces the same sequence of numbers, on every
+// platform. It must only be used where reproducibility is the point, as anyone
+// holding the seed can reproduce every password generated with it.
+type deterministicRNGService struct {
+	rng *rand.Rand
+}
+
+// Creates a deterministicRNGService seeded with the given 32 bytes
+func newDeterministicRNGService(seed [32]byte) *deterministicRNGService {
+	return &deterministicRNGService{rand.New(rand.NewChaCha8(seed))}
+}
+
+// Creates a deterministicRNGService from a numeric seed, which is written
+// little endian into the start of an otherwise zero ChaCha8 seed
+func newSeededRNGService(seed uint64) *deterministicRNGService {
+	var s [32]byte
+	binary.LittleEndian.PutUint64(s[:], seed)
+
+	return newDeterministicRNGService(s)
+}
+
+// Generates a random integer up to, but not including, the specified maximum
+// value.
+func (s *deterministicRNGService) GenerateWithMax(max int) (int, error) {
+	if max < 1 {
+		return 0, service.ErrRNGMaxLessThanOne
+	}
+
+	return s.intN(max), nil
+}
+
+// Returns a random integer in [0, n) by rejection sampling the stream's 64
+// bit words, unlike rand.IntN which samples 32 bit words on 32 bit platforms
+// and so draws different numbers there
+func (s *deterministicRNGService) intN(n int) int {
+	bound := uint64(n)
+	// 2^64 mod bound, the words below it would make the low values likelier
+	threshold := -bound % bound
+	for {
+		if v := s.rng.Uint64(); v >= threshold {
+			return int(v % bound)
+		}
+	}
+}
+
+// Generates a random integer up to rngGenerateMax.
+func (s *deterministicRNGService) Generate() (int, error) {
+	return s.GenerateWithMax(rngGenerateMax)
+}
+
+// Generates a single digit (0-9).
+func (s *deterministicRNGService) GenerateDigit() (int, error) {
+	return s.GenerateWithMax(rngDigitMax)
+}
+
+// Generates a slice of random integers, each up to the specified maximum value.
+func (s *deterministicRNGService) GenerateSliceWithMax(length int, max int) ([]int, error) {
+	if length < 0 {
+		return nil, service.ErrRNGSliceLengthLessThanZero
+	}
+
+	if max < 1 {
+		return nil, service.ErrRNGMaxLessThanOne
+	}
+
+	slice := make([]int, length)
+	for i := range slice {
+		slice[i] = s.intN(max)
+	}
+
+	return slice, nil
+}
+
+// Generates a slice of random integers up to rngGenerateMax.
+func (s *deterministicRNGService) GenerateSlice(length int) ([]int, error) {
+	return s.GenerateSliceWithMax(length, rngGenerateMax)
+}
+
+// Creates a password generator service which uses rngSvc for every random
+// choice, wiring up the same services as service.NewPasswordGeneratorService
+func newPasswordGeneratorService(
+	cfg *config.Settings,
+	rngSvc service.RNGService,
+) (*service.DefaultPasswordGeneratorService, error) {
+	wls, err := service.NewWordListService(cfg, rngSvc)
+	if err != nil {
+		return nil, err
+	}
+
+	ts, err := service.NewTransformerService(cfg, rngSvc)
+	if err != nil {
+		return nil, err
+	}
+
+	ss, err := service.NewSeparatorService(cfg, rngSvc)
+	if err != nil {
+		return nil, err
+	}
+
+	ps, err := service.NewPaddingService(cfg, rngSvc)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.NewCustomPasswordGeneratorService(cfg, ts, ss, ps, wls)
+}
+
+// Banner printed to stderr whenever passwords come from an insecure seed
+const insecureSeedBanner string = `********************************************************************************
+* WARNING: --insecure_seed is set, every password below is reproducible by     *
+* anyone who knows the seed. These are for testing only, NEVER use them as     *
+* real passwords.                                                              *
+********************************************************************************`
+
+// Creates the password generator service for a cmd, swapping in a seeded
+// deterministic RNG when the hidden insecure seed flag is set
+func newCmdPasswordGeneratorService(
+	cmd *cobra.Command,
+	cfg *config.Settings,
+) (service.PasswordGeneratorService, error) {
+	if !isFlagSet(cmd, insecureSeedKey) {
+		return service.NewPasswordGeneratorService(cfg)
+	}
+
+	seed, err := cmd.Flags().GetUint64(insecureSeedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s flag (%w)", insecureSeedKey, err)
+	}
+
+	cmd.PrintErrln(insecureSeedBanner)
+	cmd.PrintErrln()
+
+	return newPasswordGeneratorService(cfg, newSeededRNGService(seed))
+}
@@ -7,8 +7,8 @@ import (
 
 	"github.com/eljamo/libpass/v8/config"
 	"github.com/eljamo/libpass/v8/config/option"
-	"github.com/eljamo/libpass/v8/service"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 const version string = "1.16.1"
@@ -16,6 +16,12 @@ const version string = "1.16.1"
 // Constant for the custom config path key
 const customConfigPathKey string = "custom_config_path"
 
+// Constant for the settings code flag key
+const settingsCodeKey string = "settings_code"
+
+// Constant for the hidden insecure seed flag key
+const insecureSeedKey string = "insecure_seed"
+
 // Constant for the score flag key
 const scoreKey string = "score"
 
@@ -29,38 +35,39 @@ var rootCmd = &cobra.Command{
 }
 
 func runRootCmd(cmd *cobra.Command, args []string) error {
-	cfg, err := generateConfig(cmd)
-	if err != nil {
-		return fmt.Errorf("failed to generate config: %w", err)
-	}
-
-	pgs, err := service.NewPasswordGeneratorService(cfg)
-	if err != nil {
-		return fmt.Errorf("failed to create password generator service: %w", err)
-	}
-
-	pws, err := pgs.Generate()
+	out, err := getPasswordOutput(cmd)
 	if err != nil {
-		return fmt.Errorf("failed to generate passwords: %w", err)
+		return err
 	}
 
-	showScore, err := cmd.Flags().GetBool(scoreKey)
+	cfg, err := generateConfig(cmd)
 	if err != nil {
-		return fmt.Errorf("failed to get score flag: %w", err)
-	}
-
-	lines, warning := evaluatePasswords(pws, showScore)
-
-	if warning != "" {
-		cmd.PrintErrln(warning)
-		cmd.PrintErrln()
+		return fmt.Errorf("failed to generate config: %w", err)
 	}
 
-	for _, p := range lines {
-		cmd.Println(p)
+	var pws []string
+	if out.entries != nil {
+		// one for each entry, however many there are
+		if pws, err = generateInBatches(cmd, cfg, len(out.entries)); err != nil {
+			return err
+		}
+	} else {
+		if out.clip {
+			// only one is copied, whatever the config asks for
+			cfg.NumPasswords = 1
+		}
+
+		pgs, err := newCmdPasswordGeneratorService(cmd, cfg)
+		if err != nil {
+			return fmt.Errorf("failed to create password generator service: %w", err)
+		}
+
+		if pws, err = pgs.Generate(); err != nil {
+			return fmt.Errorf("failed to generate passwords: %w", err)
+		}
 	}
 
-	return nil
+	return out.write(cmd, pws)
 }
 
 func Execute() {
@@ -70,6 +77,34 @@ func Execute() {
 }
 
 func init() {
+	// Output Flags
+	rootCmd.Flags().Bool(
+		scoreKey,
+		false,
+		"show throttled/unthrottled zxcvbn strength scores next to each password, e.g. "+
+			"(Throttled [4/4, Very Strong], Unthrottled [2/4, Fair])",
+	)
+
+	addPasswordOutputFlags(rootCmd)
+	addInputFileFlag(rootCmd.Flags())
+
+	// Testing Flags
+	rootCmd.Flags().Uint64(
+		insecureSeedKey,
+		0,
+		"seed a deterministic RNG so output is reproducible, for testing only, NEVER use the passwords",
+	)
+	_ = rootCmd.Flags().MarkHidden(insecureSeedKey) // only fails for an unknown flag
+
+	addConfigFlags(rootCmd.Flags())
+
+	// the input file decides how many passwords are generated
+	rootCmd.MarkFlagsMutuallyExclusive(inputFileKey, option.ConfigKeyNumPasswords)
+}
+
+// Adds the flags which make up the config layers to a flag set, for the root
+// command and any subcommand which generates passwords from a config
+func addConfigFlags(flags *pflag.FlagSet) {
 	defaultSettings := config.DefaultSettings()
 	ccss := strings.Join(option.Presets, ", ")
 	pco := strings.Join(option.PaddingCharacterOptions, ", ")
@@ -79,21 +114,18 @@ func init() {
 	ttcss := strings.Join(option.TransformTypes, ", ")
 	wlcss := strings.Join(option.WordLists, ", ")
 
-	// Output Flags
-	rootCmd.Flags().Bool(
-		scoreKey,
-		false,
-		"show throttled/unthrottled zxcvbn strength scores next to each password, e.g. "+
-			"(Throttled [4/4, Very Strong], Unthrottled [2/4, Fair])",
-	)
-
 	// Preset and Custom Config Flags
-	rootCmd.Flags().String(
+	flags.String(
 		customConfigPathKey,
 		"",
 		"custom config file path, you can use this to load a custom config. Such as ones generated by xkpasswd.net",
 	)
-	rootCmd.Flags().String(
+	flags.String(
+		settingsCodeKey,
+		"",
+		"settings code to load as a config layer, as printed by the config share command",
+	)
+	flags.String(
 		option.ConfigKeyPreset,
 		defaultSettings.Preset,
 		fmt.Sprintf(
@@ -106,90 +138,90 @@ func init() {
 	)
 
 	// Word List Flags
-	rootCmd.Flags().String(
+	flags.String(
 		option.ConfigKeyWordList,
 		defaultSettings.WordList,
 		fmt.Sprintf("use a built-in list of words. Valid values: %s", wlcss),
 	)
 
 	// Passwords Flags
-	rootCmd.Flags().Int(
+	flags.Int(
 		option.ConfigKeyNumPasswords,
 		defaultSettings.NumPasswords,
 		"number of passwords to generate, valid values: 1+",
 	)
 
 	// Word Flags
-	rootCmd.Flags().Int(
+	flags.Int(
 		option.ConfigKeyNumWords,
 		defaultSettings.NumWords,
 		"number of words, valid values: 2+",
 	)
-	rootCmd.Flags().String(
+	flags.String(
 		option.ConfigKeyCaseTransform,
 		defaultSettings.CaseTransform,
 		fmt.Sprintf("case transformation, allowed values: %s", ttcss),
 	)
-	rootCmd.Flags().Int(
+	flags.Int(
 		option.ConfigKeyWordLengthMax,
 		defaultSettings.WordLengthMax,
 		"maximum word length, valid values: 1+",
 	)
-	rootCmd.Flags().Int(
+	flags.Int(
 		option.ConfigKeyWordLengthMin,
 		defaultSettings.WordLengthMin,
 		"minimum word length, valid values: 1+",
 	)
 
 	// Separator Flags
-	rootCmd.Flags().StringSlice(
+	flags.StringSlice(
 		option.ConfigKeySeparatorAlphabet,
 		[]string{},
 		fmt.Sprintf("comma-separated list of characters to separate password parts, example values: %s", sccss),
 	)
-	rootCmd.Flags().String(
+	flags.String(
 		option.ConfigKeySeparatorCharacter,
 		defaultSettings.SeparatorCharacter,
 		fmt.Sprintf("character to separate password parts, example values: %s", sco),
 	)
 
 	// Padding Flags
-	rootCmd.Flags().Int(
+	flags.Int(
 		option.ConfigKeyPadToLength,
 		defaultSettings.PadToLength,
 		"length to pad the password to, will be ignored if less than the generated password length, valid values: 0+",
 	)
-	rootCmd.Flags().String(
+	flags.String(
 		option.ConfigKeyPaddingCharacter,
 		defaultSettings.PaddingCharacter,
 		fmt.Sprintf("character to pad the password with, example values: %s", pco),
 	)
-	rootCmd.Flags().Int(
+	flags.Int(
 		option.ConfigKeyPaddingCharactersAfter,
 		defaultSettings.PaddingCharactersAfter,
 		"number of characters to pad after the password, valid values: 0+",
 	)
-	rootCmd.Flags().Int(
+	flags.Int(
 		option.ConfigKeyPaddingCharactersBefore,
 		defaultSettings.PaddingCharactersBefore,
 		"number of characters to pad before the password, valid values: 0+",
 	)
-	rootCmd.Flags().Int(
+	flags.Int(
 		option.ConfigKeyPaddingDigitsAfter,
 		defaultSettings.PaddingDigitsAfter,
 		"number of digits to pad after the password, valid values: 0+",
 	)
-	rootCmd.Flags().Int(
+	flags.Int(
 		option.ConfigKeyPaddingDigitsBefore,
 		defaultSettings.PaddingDigitsBefore,
 		"number of digits to pad before the password, valid values: 0+",
 	)
-	rootCmd.Flags().String(
+	flags.String(
 		option.ConfigKeyPaddingType,
 		defaultSettings.PaddingType,
 		fmt.Sprintf("padding type, allowed values: %s", ptcss),
 	)
-	rootCmd.Flags().StringSlice(
+	flags.StringSlice(
 		option.ConfigKeySymbolAlphabet,
 		[]string{},
 		fmt.Sprintf("comma-separated list of characters to pad the password with, example values: %s", sccss),
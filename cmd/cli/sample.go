@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/eljamo/libpass/v8/config"
+	"github.com/eljamo/libpass/v8/service"
+	"github.com/spf13/cobra"
+)
+
+// Most passwords libpass generates per Generate call
+const maxPasswordsPerBatch int = 10
+
+// samplePasswords generates n passwords with pgs. libpass caps the number of
+// passwords per Generate call, so it's called as many times as needed and the
+// last batch is trimmed to fit.
+func samplePasswords(pgs service.PasswordGeneratorService, n int) ([]string, error) {
+	pws := make([]string, 0, n)
+	for len(pws) < n {
+		batch, err := pgs.Generate()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate passwords: %w", err)
+		}
+
+		if len(batch) == 0 {
+			return nil, errors.New("failed to generate passwords: empty batch")
+		}
+
+		pws = append(pws, batch[:min(len(batch), n-len(pws))]...)
+	}
+
+	return pws, nil
+}
+
+// Generates n passwords with the cmd's generator for cfg, in batches as big
+// as libpass allows
+func generateInBatches(cmd *cobra.Command, cfg *config.Settings, n int) ([]string, error) {
+	cfg.NumPasswords = min(n, maxPasswordsPerBatch)
+
+	pgs, err := newCmdPasswordGeneratorService(cmd, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create password generator service (%w)", err)
+	}
+
+	return samplePasswords(pgs, n)
+}
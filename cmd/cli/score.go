@@ -41,6 +41,22 @@ func scoreLabel(score int) string {
 // bucket, so rather than one line per weak password, only the single
 // worst (fastest-to-crack) case across the whole batch is reported.
 func evaluatePasswords(pws []string, showScore bool) (lines []string, warning string) {
+	return evaluateScores(pws, scorePasswords(pws), showScore)
+}
+
+// Returns the zxcvbn result for each password
+func scorePasswords(pws []string) []zxcvbn.Result {
+	results := make([]zxcvbn.Result, len(pws))
+	for i, p := range pws {
+		results[i] = zxcvbn.PasswordStrength(p, nil)
+	}
+
+	return results
+}
+
+// evaluateScores is evaluatePasswords with the passwords already scored, for
+// callers which need the results too
+func evaluateScores(pws []string, results []zxcvbn.Result, showScore bool) (lines []string, warning string) {
 	maxLen := 0
 	for _, p := range pws {
 		if len(p) > maxLen {
@@ -52,8 +68,8 @@ func evaluatePasswords(pws []string, showScore bool) (lines []string, warning st
 	worstSeconds := math.Inf(1)
 	var worstDisplay string
 
-	for _, p := range pws {
-		r := zxcvbn.PasswordStrength(p, nil)
+	for i, p := range pws {
+		r := results[i]
 
 		line := p
 		if showScore {
@@ -85,3 +101,17 @@ func evaluatePasswords(pws []string, showScore bool) (lines []string, warning st
 
 	return lines, warning
 }
+
+// scoreDistribution counts how many passwords land on each
+// UnthrottledPasswordEntryScore, indexed by score (0-4)
+func scoreDistribution(pws []string) []int {
+	counts := make([]int, len(scoreLabels))
+	for _, p := range pws {
+		s := zxcvbn.PasswordStrength(p, nil).UnthrottledPasswordEntryScore
+		if s >= 0 && s < len(counts) {
+			counts[s]++
+		}
+	}
+
+	return counts
+}
@@ -0,0 +1,502 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+
+	"github.com/eljamo/libpass/v8/config"
+	"github.com/eljamo/libpass/v8/config/option"
+	"github.com/eljamo/libpass/v8/service"
+	"github.com/spf13/cobra"
+)
+
+// Default number of passwords generated by the self-test
+const defaultNumSelftestSamples int = 20000
+
+// p-value below which a uniformity test fails
+const selftestSignificance float64 = 0.001
+
+// Maximum number of bins the word indices are grouped into
+const selftestMaxWordBins int = 100
+
+// Maximum number of words the RANDOM case transform test supports, each
+// word doubles the number of case patterns
+const selftestMaxCaseWords int = 10
+
+var ErrSelftestFailed = errors.New("self-test failed, a choice was not uniformly distributed")
+
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Check that password generation choices are uniformly distributed",
+	Long: `Generate a large sample of passwords through the password generator services
+and run chi-square uniformity tests on each random choice: the word indices, the
+separator chosen from the separator alphabet, the padding character chosen from
+the symbol alphabet, the padding digits, and the case patterns produced by the
+RANDOM case transform. Choices the config doesn't make at random are skipped.
+Exits with an error if any test's p-value is below ` + fmt.Sprint(selftestSignificance),
+	Args: cobra.NoArgs,
+	RunE: runSelftestCmd,
+}
+
+// recordingRNGService wraps an RNGService and records the numbers it hands out
+// for the choices the self-test checks
+type recordingRNGService struct {
+	rng service.RNGService
+	// Results of GenerateWithMax
+	withMax []int
+	// Results of GenerateDigit
+	digits []int
+	// Results of GenerateSliceWithMax, flattened
+	sliceValues []int
+	// Maximum passed to GenerateSliceWithMax
+	sliceMax int
+}
+
+func (s *recordingRNGService) GenerateWithMax(max int) (int, error) {
+	n, err := s.rng.GenerateWithMax(max)
+	if err == nil {
+		s.withMax = append(s.withMax, n)
+	}
+
+	return n, err
+}
+
+func (s *recordingRNGService) Generate() (int, error) {
+	return s.rng.Generate()
+}
+
+func (s *recordingRNGService) GenerateDigit() (int, error) {
+	n, err := s.rng.GenerateDigit()
+	if err == nil {
+		s.digits = append(s.digits, n)
+	}
+
+	return n, err
+}
+
+func (s *recordingRNGService) GenerateSlice(length int) ([]int, error) {
+	return s.rng.GenerateSlice(length)
+}
+
+func (s *recordingRNGService) GenerateSliceWithMax(length int, max int) ([]int, error) {
+	slice, err := s.rng.GenerateSliceWithMax(length, max)
+	if err == nil {
+		s.sliceValues = append(s.sliceValues, slice...)
+		s.sliceMax = max
+	}
+
+	return slice, err
+}
+
+// recordingTransformerService wraps a TransformerService and records the case
+// pattern of every transformed slice, as a bitmask of its uppercase words
+type recordingTransformerService struct {
+	svc      service.TransformerService
+	patterns []int
+}
+
+func (s *recordingTransformerService) Transform(slice []string) ([]string, error) {
+	out, err := s.svc.Transform(slice)
+	if err != nil {
+		return nil, err
+	}
+
+	var pattern int
+	for i, w := range out {
+		if isUpperWord(w) {
+			pattern |= 1 << i
+		}
+	}
+	s.patterns = append(s.patterns, pattern)
+
+	return out, nil
+}
+
+// Checks if every letter in a word is uppercase
+func isUpperWord(w string) bool {
+	return !strings.ContainsFunc(w, func(r rune) bool {
+		return unicode.IsLetter(r) && !unicode.IsUpper(r)
+	})
+}
+
+// The recorders wired into each service of the self-test pipeline
+type selftestRecorders struct {
+	words       *recordingRNGService
+	separator   *recordingRNGService
+	padding     *recordingRNGService
+	transformer *recordingTransformerService
+}
+
+// The outcome of a single uniformity test
+type selftestResult struct {
+	Name string
+	// Why the test was skipped, empty if it ran
+	Skipped      string
+	Observations int
+	Bins         int
+	ChiSquare    float64
+	PValue       float64
+}
+
+// Checks if a test ran and its p-value is below the significance level
+func (r selftestResult) failed() bool {
+	return r.Skipped == "" && r.PValue < selftestSignificance
+}
+
+func runSelftestCmd(cmd *cobra.Command, args []string) error {
+	numSamples, err := cmd.Flags().GetInt(samplesKey)
+	if err != nil {
+		return fmt.Errorf("failed to get samples flag: %w", err)
+	}
+
+	if numSamples < 1 {
+		return fmt.Errorf("%s (%d) must be greater than 0", samplesKey, numSamples)
+	}
+
+	cfg, err := generateConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to generate config: %w", err)
+	}
+
+	results, err := runSelftest(cfg, service.NewRNGService(), numSamples)
+	if err != nil {
+		return err
+	}
+
+	if err := writeSelftestResults(cmd.OutOrStdout(), results); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if r.failed() {
+			return ErrSelftestFailed
+		}
+	}
+
+	return nil
+}
+
+// runSelftest generates numSamples passwords from cfg with every service
+// drawing from rngSvc through its own recorder, then tests the recorded
+// choices for uniformity
+func runSelftest(cfg *config.Settings, rngSvc service.RNGService, numSamples int) ([]selftestResult, error) {
+	rec, pgs, err := newSelftestPipeline(cfg, rngSvc)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := samplePasswords(pgs, numSamples); err != nil {
+		return nil, err
+	}
+
+	return []selftestResult{
+		testWordIndices(rec.words.sliceValues, rec.words.sliceMax),
+		testSeparator(cfg, rec.separator.withMax),
+		testPaddingCharacter(cfg, rec.padding.withMax),
+		testDigits(rec.padding.digits),
+		testRandomCase(cfg, rec.transformer.patterns),
+	}, nil
+}
+
+// Creates the services libpass would, each with its own recorder, and the
+// password generator service combining them
+func newSelftestPipeline(
+	cfg *config.Settings,
+	rngSvc service.RNGService,
+) (*selftestRecorders, service.PasswordGeneratorService, error) {
+	rec := &selftestRecorders{
+		words:     &recordingRNGService{rng: rngSvc},
+		separator: &recordingRNGService{rng: rngSvc},
+		padding:   &recordingRNGService{rng: rngSvc},
+	}
+
+	wls, err := service.NewWordListService(cfg, rec.words)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ts, err := service.NewTransformerService(cfg, rngSvc)
+	if err != nil {
+		return nil, nil, err
+	}
+	rec.transformer = &recordingTransformerService{svc: ts}
+
+	ss, err := service.NewSeparatorService(cfg, rec.separator)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ps, err := service.NewPaddingService(cfg, rec.padding)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pgs, err := service.NewCustomPasswordGeneratorService(cfg, rec.transformer, ss, ps, wls)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rec, pgs, nil
+}
+
+// Tests the word indices, grouped into at most selftestMaxWordBins bins of
+// nearly equal size so each bin expects enough observations
+func testWordIndices(indices []int, wordListLen int) selftestResult {
+	r := selftestResult{Name: "Word indices", Observations: len(indices)}
+	if wordListLen < 2 {
+		r.Skipped = "word list has fewer than 2 words"
+		return r
+	}
+
+	numBins := min(wordListLen, selftestMaxWordBins)
+	observed := make([]int, numBins)
+	for _, idx := range indices {
+		observed[idx*numBins/wordListLen]++
+	}
+
+	binSizes := make([]int, numBins)
+	for idx := range wordListLen {
+		binSizes[idx*numBins/wordListLen]++
+	}
+
+	expected := make([]float64, numBins)
+	for i, size := range binSizes {
+		expected[i] = float64(len(indices)) * float64(size) / float64(wordListLen)
+	}
+
+	return r.withChiSquare(observed, expected)
+}
+
+// Tests the separator character chosen from the separator alphabet
+func testSeparator(cfg *config.Settings, choices []int) selftestResult {
+	r := selftestResult{Name: "Separator character"}
+	if cfg.SeparatorCharacter != option.SeparatorCharacterRandom {
+		r.Skipped = option.ConfigKeySeparatorCharacter + " is not " + option.SeparatorCharacterRandom
+		return r
+	}
+
+	return r.withUniformChoices(choices, len(cfg.SeparatorAlphabet))
+}
+
+// Tests the padding character chosen from the symbol alphabet
+func testPaddingCharacter(cfg *config.Settings, choices []int) selftestResult {
+	r := selftestResult{Name: "Padding character"}
+	if cfg.PaddingCharacter != option.PaddingCharacterRandom {
+		r.Skipped = option.ConfigKeyPaddingCharacter + " is not " + option.PaddingCharacterRandom
+		return r
+	}
+
+	return r.withUniformChoices(choices, len(cfg.SymbolAlphabet))
+}
+
+// Tests the padding digits
+func testDigits(digits []int) selftestResult {
+	r := selftestResult{Name: "Padding digits"}
+	if len(digits) == 0 {
+		r.Skipped = "no padding digits"
+		return r
+	}
+
+	return r.withUniformChoices(digits, digitChoices)
+}
+
+// Tests the case patterns of the RANDOM case transform. Every pattern is
+// equally likely apart from the two where all words share a case, which are
+// rejected by flipping the case of one word, so the patterns with a single
+// odd word out are proportionally more likely.
+func testRandomCase(cfg *config.Settings, patterns []int) selftestResult {
+	r := selftestResult{Name: "Random case", Observations: len(patterns)}
+	if cfg.CaseTransform != option.CaseTransformRandom {
+		r.Skipped = option.ConfigKeyCaseTransform + " is not " + option.CaseTransformRandom
+		return r
+	}
+
+	n := cfg.NumWords
+	if n > selftestMaxCaseWords {
+		r.Skipped = fmt.Sprintf("more than %d words", selftestMaxCaseWords)
+		return r
+	}
+
+	numPatterns := 1 << n
+	observed := make([]int, numPatterns)
+	for _, p := range patterns {
+		observed[p]++
+	}
+
+	expected := make([]float64, numPatterns)
+	for p := range numPatterns {
+		prob := randomCasePatternOdds(n, bits.OnesCount(uint(p)))
+		expected[p] = float64(len(patterns)) * prob
+	}
+
+	return r.withChiSquare(observed, expected)
+}
+
+// Runs a chi-square test of choices against a uniform distribution over
+// numChoices values
+func (r selftestResult) withUniformChoices(choices []int, numChoices int) selftestResult {
+	r.Observations = len(choices)
+	if numChoices < 2 {
+		r.Skipped = "fewer than 2 possible values"
+		return r
+	}
+
+	observed := make([]int, numChoices)
+	for _, c := range choices {
+		observed[c]++
+	}
+
+	expected := make([]float64, numChoices)
+	for i := range expected {
+		expected[i] = float64(len(choices)) / float64(numChoices)
+	}
+
+	return r.withChiSquare(observed, expected)
+}
+
+// Fills in the chi-square statistic and p-value of the observed counts against
+// the expected counts. Bins which can't occur are left out of the degrees of
+// freedom, and any observation in one fails the test outright.
+func (r selftestResult) withChiSquare(observed []int, expected []float64) selftestResult {
+	var bins int
+	var chi2 float64
+	impossible := false
+	for i, e := range expected {
+		if e == 0 {
+			impossible = impossible || observed[i] > 0
+			continue
+		}
+
+		bins++
+		d := float64(observed[i]) - e
+		chi2 += d * d / e
+	}
+
+	r.Bins = bins
+	r.ChiSquare = chi2
+	r.PValue = chiSquarePValue(chi2, bins-1)
+	if impossible {
+		r.PValue = 0
+	}
+
+	return r
+}
+
+func writeSelftestResults(w io.Writer, results []selftestResult) error {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "Test\tObservations\tBins\tChi-square\tp-value\tResult")
+	for _, r := range results {
+		if r.Skipped != "" {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\t-\tSKIP (%s)\n", r.Name, r.Skipped)
+			continue
+		}
+
+		result := "PASS"
+		if r.failed() {
+			result = "FAIL"
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f\t%.4f\t%s\n", r.Name, r.Observations, r.Bins, r.ChiSquare, r.PValue, result)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write self-test results: %w", err)
+	}
+
+	if _, err := buf.WriteTo(w); err != nil {
+		return fmt.Errorf("failed to write self-test results: %w", err)
+	}
+
+	return nil
+}
+
+// chiSquarePValue returns the probability of a chi-square statistic at least
+// as large as chi2 with df degrees of freedom, Q(df/2, chi2/2)
+func chiSquarePValue(chi2 float64, df int) float64 {
+	if df < 1 {
+		return 1
+	}
+
+	return regularizedGammaQ(float64(df)/2, chi2/2)
+}
+
+// Iteration limit and convergence threshold for the incomplete gamma function
+const (
+	gammaMaxIterations int     = 1000
+	gammaEpsilon       float64 = 1e-15
+	gammaTiny          float64 = 1e-300
+)
+
+// regularizedGammaQ computes the regularized upper incomplete gamma function
+// Q(a, x), using its series below x = a+1 and its continued fraction above,
+// as each converges quickly on its side
+func regularizedGammaQ(a, x float64) float64 {
+	if x <= 0 {
+		return 1
+	}
+
+	lgamma, _ := math.Lgamma(a)
+	prefix := math.Exp(-x + a*math.Log(x) - lgamma)
+
+	if x < a+1 {
+		ap, sum := a, 1/a
+		del := sum
+		for range gammaMaxIterations {
+			ap++
+			del *= x / ap
+			sum += del
+			if math.Abs(del) < math.Abs(sum)*gammaEpsilon {
+				break
+			}
+		}
+
+		return math.Max(0, 1-sum*prefix)
+	}
+
+	// modified Lentz's method
+	b := x + 1 - a
+	c := 1 / gammaTiny
+	d := 1 / b
+	h := d
+	for i := 1; i <= gammaMaxIterations; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < gammaTiny {
+			d = gammaTiny
+		}
+		c = b + an/c
+		if math.Abs(c) < gammaTiny {
+			c = gammaTiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < gammaEpsilon {
+			break
+		}
+	}
+
+	return prefix * h
+}
+
+func init() {
+	selftestCmd.Flags().Int(
+		samplesKey,
+		defaultNumSelftestSamples,
+		"number of passwords to generate for the uniformity tests, valid values: 1+",
+	)
+
+	addConfigFlags(selftestCmd.Flags())
+
+	rootCmd.AddCommand(selftestCmd)
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"math"
+	"testing"
+
+	"github.com/eljamo/libpass/v8/config"
+)
+
+func TestChiSquarePValue(t *testing.T) {
+	t.Parallel()
+
+	// critical values from standard chi-square tables
+	tests := []struct {
+		chi2 float64
+		df   int
+		want float64
+	}{
+		{3.841, 1, 0.05},
+		{18.307, 10, 0.05},
+		{23.209, 10, 0.01},
+		{9.342, 10, 0.5},
+		{0, 5, 1},
+	}
+
+	for _, tt := range tests {
+		if got := chiSquarePValue(tt.chi2, tt.df); math.Abs(got-tt.want) > 1e-3 {
+			t.Errorf("chiSquarePValue(%v, %d) = %f, want %f", tt.chi2, tt.df, got, tt.want)
+		}
+	}
+}
+
+func TestRunSelftestSeeded(t *testing.T) {
+	t.Parallel()
+
+	results, err := runSelftest(config.DefaultSettings(), newSeededRNGService(1), 2000)
+	if err != nil {
+		t.Fatalf("runSelftest() error = %v", err)
+	}
+
+	for _, r := range results {
+		if r.Skipped != "" {
+			t.Errorf("%s skipped (%s), want every test to run for the default config", r.Name, r.Skipped)
+		}
+		if r.failed() {
+			t.Errorf("%s failed with p-value %f", r.Name, r.PValue)
+		}
+	}
+}
+
+func TestSelftestDetectsBias(t *testing.T) {
+	t.Parallel()
+
+	// every choice is the first value
+	r := selftestResult{}.withUniformChoices(make([]int, 1000), 10)
+	if !r.failed() {
+		t.Errorf("withUniformChoices() of constant choices p-value = %f, want a failure", r.PValue)
+	}
+}
@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Timeouts applied to every connection, and the time allowed for in-flight
+// requests to finish when shutting down
+const (
+	serverReadHeaderTimeout time.Duration = 5 * time.Second
+	serverReadTimeout       time.Duration = 10 * time.Second
+	serverWriteTimeout      time.Duration = 30 * time.Second
+	serverIdleTimeout       time.Duration = 60 * time.Second
+	serverShutdownTimeout   time.Duration = 10 * time.Second
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve password generation and scoring over HTTP",
+	Long: `Serve password generation and scoring over HTTP.
+
+  POST /v1/generate  generate passwords, the JSON body takes the same keys as a
+                     custom config and is merged on top of the server's config,
+                     with at most 64 words, digits or padding characters on
+                     either side and a pad_to_length of at most 1024
+  POST /v1/score     score {"passwords": [...]} with zxcvbn
+  GET  /v1/options   list the presets, with their settings, and option values
+  GET  /metrics      Prometheus metrics, labelled only by preset and word list
+  GET  /healthz      health check
+  GET  /             web UI, only with --ui
+
+Every response is sent with Cache-Control: no-store. The server shuts down
+gracefully on SIGINT or SIGTERM.
+
+Server settings can be given as flags or in a "server" object in the custom
+config, flags take precedence. With an auth token file every request except
+the health check must send "Authorization: Bearer <token>", which needs TLS
+unless the server only listens on a loopback address or a Unix socket. With a client CA
+bundle every connection must present a certificate signed by it. Rate limits
+are per client, which is the token name, the certificate common name or the
+remote IP, or "unix" over a Unix socket. The access log never includes request
+or response bodies.
+
+With --unix_socket the server listens on a Unix socket instead of the address,
+so access is controlled by its mode, owner and group. When started by systemd
+socket activation it serves on the sockets passed in LISTEN_FDS instead of
+either`,
+	Args: cobra.NoArgs,
+	RunE: runServeCmd,
+}
+
+func runServeCmd(cmd *cobra.Command, args []string) error {
+	layers, err := getConfigLayers(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Fail on start rather than on every request if the config is invalid
+	if _, err := layers.merge(nil); err != nil {
+		return fmt.Errorf("failed to generate config: %w", err)
+	}
+
+	settings, err := newServerSettings(cmd, layers)
+	if err != nil {
+		return fmt.Errorf("failed to load server settings: %w", err)
+	}
+
+	tlsCfg, err := settings.tlsConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load TLS config: %w", err)
+	}
+
+	logger := log.New(cmd.ErrOrStderr(), "", log.LstdFlags)
+	ps, err := newPasswordServer(layers, settings, logger)
+	if err != nil {
+		return fmt.Errorf("failed to create server: %w", err)
+	}
+
+	lns, err := settings.listeners()
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	if err := settings.checkCleartextTokens(lns); err != nil {
+		for _, ln := range lns {
+			ln.Close()
+		}
+
+		return err
+	}
+
+	if tlsCfg != nil {
+		for i, ln := range lns {
+			lns[i] = tls.NewListener(ln, tlsCfg)
+		}
+	}
+
+	srv := &http.Server{
+		Handler:           ps.handler(),
+		TLSConfig:         tlsCfg,
+		ReadHeaderTimeout: serverReadHeaderTimeout,
+		ReadTimeout:       serverReadTimeout,
+		WriteTimeout:      serverWriteTimeout,
+		IdleTimeout:       serverIdleTimeout,
+		ErrorLog:          logger,
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go ps.sweepRateLimiters(ctx)
+
+	return serve(ctx, srv, lns, logger)
+}
+
+// serve runs srv on every listener until ctx is done or one of them fails,
+// then shuts it down gracefully, giving in-flight requests
+// serverShutdownTimeout to finish
+func serve(ctx context.Context, srv *http.Server, lns []net.Listener, logger *log.Logger) error {
+	errCh := make(chan error, len(lns))
+	for _, ln := range lns {
+		go func() {
+			logger.Printf("listening on %s %s", ln.Addr().Network(), ln.Addr())
+			errCh <- srv.Serve(ln)
+		}()
+	}
+
+	select {
+	case err := <-errCh:
+		srv.Close()
+		return fmt.Errorf("server stopped: %w", err)
+	case <-ctx.Done():
+	}
+
+	logger.Print("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down server: %w", err)
+	}
+
+	for range lns {
+		if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("server stopped: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	addServerFlags(serveCmd.Flags())
+
+	addConfigFlags(serveCmd.Flags())
+
+	rootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,347 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/eljamo/libpass/v8/config"
+	"github.com/eljamo/libpass/v8/config/option"
+	"github.com/eljamo/libpass/v8/service"
+	"github.com/eljamo/zxcvbn"
+)
+
+// Limits on what a single request may ask of the server
+const (
+	maxRequestBytes     int64 = 64 * 1024
+	maxScorePasswords   int   = 100
+	maxScorePasswordLen int   = 256
+)
+
+// Limits on the config of a single request, which would otherwise let it
+// allocate gigabytes or keep the RNG busy for minutes
+const (
+	maxRequestNumWords          int = 64
+	maxRequestWordLength        int = 64
+	maxRequestPaddingDigits     int = 64
+	maxRequestPaddingCharacters int = 64
+	maxRequestPadToLength       int = 1024
+)
+
+// passwordServer serves password generation and scoring over HTTP. Every
+// generate request is merged on top of the server's own config layers.
+type passwordServer struct {
+	layers *configLayers
+	logger *log.Logger
+	// Bearer tokens clients must present, nil when not required
+	tokens          []authToken
+	generateLimiter *rateLimiter
+	scoreLimiter    *rateLimiter
+	accessLog       bool
+	ui              bool
+	metrics         *serverMetrics
+}
+
+// Body of a generate response
+type generateResponse struct {
+	Passwords []string        `json:"passwords"`
+	Entropy   entropyResponse `json:"entropy"`
+	Warning   string          `json:"warning,omitempty"`
+}
+
+// The entropy of the passwords a config generates
+type entropyResponse struct {
+	Bits          float64 `json:"bits"`
+	TypicalLength int     `json:"typical_length"`
+}
+
+// Body of a score request
+type scoreRequest struct {
+	Passwords []string `json:"passwords"`
+}
+
+// The zxcvbn result for a single password, without the match sequence
+type scoreResult struct {
+	Guesses                       float64            `json:"guesses"`
+	GuessesLog10                  float64            `json:"guesses_log10"`
+	Score                         int                `json:"score"`
+	ThrottledPasswordEntryScore   int                `json:"throttled_password_entry_score"`
+	UnthrottledPasswordEntryScore int                `json:"unthrottled_password_entry_score"`
+	CrackTimesSeconds             map[string]float64 `json:"crack_times_seconds"`
+	CrackTimesDisplay             map[string]string  `json:"crack_times_display"`
+	Warning                       string             `json:"warning,omitempty"`
+	Suggestions                   []string           `json:"suggestions,omitempty"`
+}
+
+// Body of a score response
+type scoreResponse struct {
+	Results []scoreResult `json:"results"`
+}
+
+// Body of an error response
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// An error with the HTTP status it should be reported with
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+func (e *httpError) Unwrap() error {
+	return e.err
+}
+
+// Creates a passwordServer which merges requests on top of layers, with the
+// auth, rate limits and access log given by settings
+func newPasswordServer(layers *configLayers, settings *serverSettings, logger *log.Logger) (*passwordServer, error) {
+	s := &passwordServer{
+		layers:          layers,
+		logger:          logger,
+		generateLimiter: newRateLimiter(settings.GenerateRateLimit, settings.GenerateRateBurst),
+		scoreLimiter:    newRateLimiter(settings.ScoreRateLimit, settings.ScoreRateBurst),
+		accessLog:       settings.AccessLog,
+		ui:              settings.UI,
+		metrics:         newServerMetrics(),
+	}
+
+	if settings.AuthTokenFile != "" {
+		tokens, err := loadAuthTokens(settings.AuthTokenFile)
+		if err != nil {
+			return nil, err
+		}
+
+		s.tokens = tokens
+	}
+
+	return s, nil
+}
+
+// Returns the server's routes, every response is marked as not cacheable.
+// The health check and web UI are open to everyone, every other route requires
+// auth when it's configured and generate and score are rate limited per
+// client.
+func (s *passwordServer) handler() http.Handler {
+	api := http.NewServeMux()
+	api.HandleFunc("GET /v1/options", s.handleOptions)
+	api.Handle("GET /metrics", s.metrics.handler())
+	api.HandleFunc("POST /v1/generate", s.rateLimit(s.generateLimiter, s.handleGenerate))
+	api.HandleFunc("POST /v1/score", s.rateLimit(s.scoreLimiter, s.handleScore))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealthz)
+	mux.Handle("/", s.authenticate(api))
+
+	if s.ui {
+		ui := uiHandler()
+		mux.Handle("GET /{$}", ui)
+		mux.Handle("GET /assets/", ui)
+	}
+
+	h := noStore(mux)
+	if s.accessLog {
+		h = accessLog(s.logger, h)
+	}
+
+	return h
+}
+
+// Sets Cache-Control: no-store on every response, generated passwords must
+// never be kept by a cache between the server and the client
+func noStore(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *passwordServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Generates passwords from the server's config layers with the request body,
+// which takes the same keys as a custom config, merged on top
+func (s *passwordServer) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	var reqCfg map[string]any
+	if err := decodeRequest(w, r, &reqCfg); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	cfg, err := s.layers.merge(reqCfg)
+	if err == nil {
+		err = validateConfigLimits(cfg)
+	}
+	if err != nil {
+		s.metrics.configValidationFailures.Inc()
+		s.writeError(w, &httpError{http.StatusBadRequest, err})
+		return
+	}
+
+	pgs, err := newPasswordGeneratorService(cfg, newCountingRNGService(service.NewRNGService(), s.metrics.rngErrors))
+	if err != nil {
+		s.metrics.configValidationFailures.Inc()
+		s.writeError(w, &httpError{http.StatusBadRequest, err})
+		return
+	}
+
+	est, err := estimateEntropy(cfg)
+	if err != nil {
+		s.metrics.configValidationFailures.Inc()
+		s.writeError(w, &httpError{http.StatusBadRequest, err})
+		return
+	}
+
+	labels := configLabelValues(cfg)
+	s.metrics.generateRequests.With(labels).Inc()
+
+	start := time.Now()
+	pws, err := pgs.Generate()
+	observeSince(s.metrics.generateDuration.With(labels), start)
+	if err != nil {
+		s.writeError(w, fmt.Errorf("failed to generate passwords: %w", err))
+		return
+	}
+
+	_, warning := evaluatePasswords(pws, false)
+	if warning != "" {
+		s.metrics.weakPasswordWarnings.With(labels).Inc()
+	}
+
+	s.writeJSON(w, http.StatusOK, generateResponse{pws, entropyResponse{est.Bits, est.TypicalLength}, warning})
+}
+
+// Scores each password in the request body with zxcvbn
+func (s *passwordServer) handleScore(w http.ResponseWriter, r *http.Request) {
+	s.metrics.scoreRequests.Inc()
+
+	var req scoreRequest
+	if err := decodeRequest(w, r, &req); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	if err := validateScorePasswords(req.Passwords); err != nil {
+		s.writeError(w, &httpError{http.StatusBadRequest, err})
+		return
+	}
+
+	results := make([]scoreResult, 0, len(req.Passwords))
+	for _, p := range req.Passwords {
+		start := time.Now()
+		res := zxcvbn.PasswordStrength(p, nil)
+		observeSince(s.metrics.scoreDuration, start)
+		s.metrics.scoredPasswords.Inc()
+
+		results = append(results, newScoreResult(res))
+	}
+
+	s.writeJSON(w, http.StatusOK, scoreResponse{results})
+}
+
+// Checks a score request is within the limits on how many passwords, and how
+// long, a single request may score
+func validateScorePasswords(pws []string) error {
+	if len(pws) > maxScorePasswords {
+		return fmt.Errorf("cannot score more than %d passwords per request", maxScorePasswords)
+	}
+
+	for _, p := range pws {
+		if utf8.RuneCountInString(p) > maxScorePasswordLen {
+			return fmt.Errorf("cannot score passwords longer than %d characters", maxScorePasswordLen)
+		}
+	}
+
+	return nil
+}
+
+// Checks a config is within the limits on what a single request may generate
+func validateConfigLimits(cfg *config.Settings) error {
+	limits := []struct {
+		key   string
+		value int
+		max   int
+	}{
+		{option.ConfigKeyNumWords, cfg.NumWords, maxRequestNumWords},
+		{option.ConfigKeyWordLengthMin, cfg.WordLengthMin, maxRequestWordLength},
+		{option.ConfigKeyWordLengthMax, cfg.WordLengthMax, maxRequestWordLength},
+		{option.ConfigKeyPaddingDigitsBefore, cfg.PaddingDigitsBefore, maxRequestPaddingDigits},
+		{option.ConfigKeyPaddingDigitsAfter, cfg.PaddingDigitsAfter, maxRequestPaddingDigits},
+		{option.ConfigKeyPaddingCharactersBefore, cfg.PaddingCharactersBefore, maxRequestPaddingCharacters},
+		{option.ConfigKeyPaddingCharactersAfter, cfg.PaddingCharactersAfter, maxRequestPaddingCharacters},
+		{option.ConfigKeyPadToLength, cfg.PadToLength, maxRequestPadToLength},
+	}
+
+	for _, l := range limits {
+		if l.value > l.max {
+			return fmt.Errorf("%s (%d) must not be more than %d", l.key, l.value, l.max)
+		}
+	}
+
+	return nil
+}
+
+// Copies the fields of a zxcvbn result which don't contain parts of the
+// password
+func newScoreResult(r zxcvbn.Result) scoreResult {
+	return scoreResult{
+		Guesses:                       r.Guesses,
+		GuessesLog10:                  r.GuessesLog10,
+		Score:                         r.Score,
+		ThrottledPasswordEntryScore:   r.ThrottledPasswordEntryScore,
+		UnthrottledPasswordEntryScore: r.UnthrottledPasswordEntryScore,
+		CrackTimesSeconds:             r.CrackTimesSeconds,
+		CrackTimesDisplay:             r.CrackTimesDisplay,
+		Warning:                       r.Feedback.Warning,
+		Suggestions:                   r.Feedback.Suggestions,
+	}
+}
+
+// Decodes a JSON request body into v, limited to maxRequestBytes. An empty
+// body leaves v untouched.
+func decodeRequest(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			return &httpError{http.StatusRequestEntityTooLarge, fmt.Errorf("request body larger than %d bytes", mbe.Limit)}
+		}
+
+		return &httpError{http.StatusBadRequest, fmt.Errorf("invalid JSON request body (%w)", err)}
+	}
+
+	return nil
+}
+
+// Writes err as a JSON error response, with its status if it has one.
+// Anything else is an internal error, logged but not exposed to the client.
+func (s *passwordServer) writeError(w http.ResponseWriter, err error) {
+	var he *httpError
+	if errors.As(err, &he) {
+		s.writeJSON(w, he.status, errorResponse{he.Error()})
+		return
+	}
+
+	s.logger.Printf("internal error: %v", err)
+	s.writeJSON(w, http.StatusInternalServerError, errorResponse{http.StatusText(http.StatusInternalServerError)})
+}
+
+func (s *passwordServer) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		s.logger.Printf("failed to write response: %v", err)
+	}
+}
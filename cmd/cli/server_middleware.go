@@ -0,0 +1,339 @@
+package cli
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// How long a client's rate limiter is kept after its last request, and how
+// often idle ones are looked for
+const (
+	rateLimiterIdleTimeout   time.Duration = 10 * time.Minute
+	rateLimiterSweepInterval time.Duration = time.Minute
+)
+
+// Most clients a rate limiter tracks at once, past which the least recently
+// seen is dropped, so a stream of new client IDs can't grow it without limit
+const rateLimiterMaxClients int = 10000
+
+// Length of the hex digest prefix used to name tokens without a name
+const tokenNameLen int = 8
+
+type clientIDKey struct{}
+
+// Returns the ID of the client making the request, set by withClientID
+func clientID(r *http.Request) string {
+	id, _ := r.Context().Value(clientIDKey{}).(string)
+	return id
+}
+
+// Returns r with the client ID attached to its context, and records it in the
+// access log if there is one
+func withClientID(w http.ResponseWriter, r *http.Request, id string) *http.Request {
+	if lw, ok := w.(*loggingResponseWriter); ok {
+		lw.client = id
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), clientIDKey{}, id))
+}
+
+// A bearer token, kept only as its SHA-256 digest
+type authToken struct {
+	name   string
+	digest [sha256.Size]byte
+}
+
+// Loads the bearer tokens from a file with one token per line, either as
+// "name token" or just "token". Blank lines and lines starting with # are
+// skipped.
+func loadAuthTokens(path string) ([]authToken, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s (%w)", authTokenFileKey, err)
+	}
+	defer f.Close()
+
+	var tokens []authToken
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		var name, token string
+		switch len(fields) {
+		case 1:
+			token = fields[0]
+		case 2:
+			name, token = fields[0], fields[1]
+		default:
+			return nil, fmt.Errorf("invalid line %d in %s, want \"name token\" or \"token\"", n, authTokenFileKey)
+		}
+
+		digest := sha256.Sum256([]byte(token))
+		if name == "" {
+			name = hex.EncodeToString(digest[:])[:tokenNameLen]
+		}
+
+		tokens = append(tokens, authToken{name, digest})
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s (%w)", authTokenFileKey, err)
+	}
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("no tokens found in %s", authTokenFileKey)
+	}
+
+	return tokens, nil
+}
+
+// Identifies the client of every request. With tokens, requests must present
+// one as a bearer token and the client is its name. With a verified client
+// certificate the client is its common name. Otherwise the client is the
+// remote IP.
+func (s *passwordServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.tokens != nil {
+			name, ok := s.matchToken(r)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				s.writeJSON(w, http.StatusUnauthorized, errorResponse{http.StatusText(http.StatusUnauthorized)})
+				return
+			}
+
+			next.ServeHTTP(w, withClientID(w, r, name))
+			return
+		}
+
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			next.ServeHTTP(w, withClientID(w, r, r.TLS.PeerCertificates[0].Subject.CommonName))
+			return
+		}
+
+		next.ServeHTTP(w, withClientID(w, r, remoteIP(r)))
+	})
+}
+
+// Returns the name of the token the request presents, every token is compared
+// so the time taken doesn't reveal which one matched
+func (s *passwordServer) matchToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+
+	digest := sha256.Sum256([]byte(token))
+	var name string
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare(digest[:], t.digest[:]) == 1 {
+			name = t.name
+		}
+	}
+
+	return name, name != ""
+}
+
+// Client ID of every request over a Unix socket, which has no remote address
+const unixSocketClientID string = "unix"
+
+// Returns the IP of the request's remote address
+func remoteIP(r *http.Request) string {
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok && addr.Network() == "unix" {
+		return unixSocketClientID
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// Per-client token bucket rate limiters for a single route
+type rateLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu      sync.Mutex
+	clients map[string]*list.Element
+	// The clientLimiters, most recently seen first
+	recent *list.List
+}
+
+type clientLimiter struct {
+	id       string
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Creates a rateLimiter allowing each client perSecond requests per second in
+// bursts of burst, or nil if perSecond is 0
+func newRateLimiter(perSecond float64, burst int) *rateLimiter {
+	if perSecond == 0 {
+		return nil
+	}
+
+	return &rateLimiter{
+		limit:   rate.Limit(perSecond),
+		burst:   burst,
+		clients: make(map[string]*list.Element),
+		recent:  list.New(),
+	}
+}
+
+// Reports whether the client may make a request now, and if not how long
+// until it may. A new client past rateLimiterMaxClients replaces the least
+// recently seen one.
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.clients[client]
+	if ok {
+		l.recent.MoveToFront(e)
+	} else {
+		if len(l.clients) >= rateLimiterMaxClients {
+			l.drop(l.recent.Back())
+		}
+
+		e = l.recent.PushFront(&clientLimiter{id: client, limiter: rate.NewLimiter(l.limit, l.burst)})
+		l.clients[client] = e
+	}
+
+	c := e.Value.(*clientLimiter)
+	c.lastSeen = now
+
+	res := c.limiter.ReserveN(now, 1)
+	if delay := res.DelayFrom(now); delay > 0 {
+		res.CancelAt(now)
+		return false, delay
+	}
+
+	return true, 0
+}
+
+// Drops a client's limiter, l.mu must be held
+func (l *rateLimiter) drop(e *list.Element) {
+	l.recent.Remove(e)
+	delete(l.clients, e.Value.(*clientLimiter).id)
+}
+
+// Drops the limiters idle for longer than rateLimiterIdleTimeout, which are
+// all at the back of the list
+func (l *rateLimiter) dropIdle(now time.Time) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for {
+		e := l.recent.Back()
+		if e == nil || now.Sub(e.Value.(*clientLimiter).lastSeen) <= rateLimiterIdleTimeout {
+			return
+		}
+
+		l.drop(e)
+	}
+}
+
+// Drops idle client limiters every rateLimiterSweepInterval until ctx is done
+func (s *passwordServer) sweepRateLimiters(ctx context.Context) {
+	ticker := time.NewTicker(rateLimiterSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.generateLimiter.dropIdle(now)
+			s.scoreLimiter.dropIdle(now)
+		}
+	}
+}
+
+// Limits the requests each client may make to a route, a nil limiter allows
+// every request
+func (s *passwordServer) rateLimit(l *rateLimiter, next http.HandlerFunc) http.HandlerFunc {
+	if l == nil {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, delay := l.allow(clientID(r), time.Now())
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			s.writeJSON(w, http.StatusTooManyRequests, errorResponse{http.StatusText(http.StatusTooManyRequests)})
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// Records the status and size of a response for the access log
+type loggingResponseWriter struct {
+	http.ResponseWriter
+	client string
+	status int
+	bytes  int
+}
+
+func (w *loggingResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *loggingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+
+	return n, err
+}
+
+// Logs the client, method, path, status, size and duration of each request.
+// Bodies, headers and query strings are never logged, they may hold
+// passwords or tokens.
+func accessLog(logger *log.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		lw := &loggingResponseWriter{ResponseWriter: w, client: "-"}
+		next.ServeHTTP(lw, r)
+
+		if lw.status == 0 {
+			lw.status = http.StatusOK
+		}
+
+		logger.Printf(
+			"%s %s %s %d %d %s",
+			lw.client, r.Method, r.URL.Path, lw.status, lw.bytes, time.Since(start).Round(time.Microsecond),
+		)
+	})
+}
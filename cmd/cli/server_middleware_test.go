@@ -0,0 +1,368 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func writeTokenFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+
+	return path
+}
+
+func TestLoadAuthTokens(t *testing.T) {
+	t.Parallel()
+
+	path := writeTokenFile(t, "# comment\n\nci s3cret\nanonymous-token\n")
+	tokens, err := loadAuthTokens(path)
+	if err != nil {
+		t.Fatalf("loadAuthTokens() error = %v", err)
+	}
+
+	if len(tokens) != 2 {
+		t.Fatalf("got %d tokens, want 2", len(tokens))
+	}
+
+	if tokens[0].name != "ci" {
+		t.Errorf("tokens[0].name = %q, want \"ci\"", tokens[0].name)
+	}
+
+	if len(tokens[1].name) != tokenNameLen || strings.Contains(tokens[1].name, "anonymous") {
+		t.Errorf("tokens[1].name = %q, want a %d character digest prefix", tokens[1].name, tokenNameLen)
+	}
+}
+
+func TestLoadAuthTokensErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"empty", "# only a comment\n"},
+		{"too many fields", "ci s3cret extra\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := loadAuthTokens(writeTokenFile(t, tt.content)); err == nil {
+				t.Error("loadAuthTokens() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestServerAuthToken(t *testing.T) {
+	t.Parallel()
+
+	settings := defaultServerSettings()
+	settings.AccessLog = false
+	settings.AuthTokenFile = writeTokenFile(t, "ci s3cret\n")
+	srv := newTestPasswordServerWithSettings(t, settings, log.New(io.Discard, "", 0))
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer wrong", http.StatusUnauthorized},
+		{"wrong scheme", "Basic s3cret", http.StatusUnauthorized},
+		{"valid", "Bearer s3cret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/generate", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: request error = %v", tt.name, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+
+		if tt.want == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("%s: WWW-Authenticate = %q, want \"Bearer\"", tt.name, resp.Header.Get("WWW-Authenticate"))
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "/healthz")
+	if err != nil {
+		t.Fatalf("GET /healthz error = %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /healthz status = %d, want %d without a token", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestAuthenticateClientCertificate(t *testing.T) {
+	t.Parallel()
+
+	ps := &passwordServer{}
+	var got string
+	h := ps.authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = clientID(r)
+	}))
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "build-agent"}}
+	req := httptest.NewRequest(http.MethodPost, "/v1/generate", nil)
+	req.TLS = &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert},
+		VerifiedChains:   [][]*x509.Certificate{{cert}},
+	}
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got != "build-agent" {
+		t.Errorf("clientID() = %q, want \"build-agent\"", got)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	l := newRateLimiter(1, 2)
+	now := time.Now()
+
+	for i := range 2 {
+		if ok, _ := l.allow("a", now); !ok {
+			t.Fatalf("request %d denied, want it allowed within the burst", i)
+		}
+	}
+
+	ok, delay := l.allow("a", now)
+	if ok || delay <= 0 || delay > time.Second {
+		t.Errorf("allow() = %t, %v, want false and a delay of at most 1s", ok, delay)
+	}
+
+	if ok, _ := l.allow("b", now); !ok {
+		t.Error("allow() denied another client, want each client limited separately")
+	}
+
+	if ok, _ := l.allow("a", now.Add(time.Second)); !ok {
+		t.Error("allow() denied a request after the bucket refilled")
+	}
+
+	l.allow("b", now.Add(2*rateLimiterIdleTimeout))
+	l.dropIdle(now.Add(2 * rateLimiterIdleTimeout))
+	if _, ok := l.clients["a"]; ok {
+		t.Error("idle client limiter was not dropped")
+	}
+
+	if _, ok := l.clients["b"]; !ok {
+		t.Error("recently seen client limiter was dropped")
+	}
+}
+
+func TestRateLimiterMaxClients(t *testing.T) {
+	t.Parallel()
+
+	l := newRateLimiter(1, 1)
+	now := time.Now()
+	for i := range rateLimiterMaxClients + 1 {
+		l.allow(fmt.Sprintf("client %d", i), now.Add(time.Duration(i)))
+	}
+
+	if got := len(l.clients); got != rateLimiterMaxClients {
+		t.Errorf("rate limiter tracks %d clients, want at most %d", got, rateLimiterMaxClients)
+	}
+
+	if _, ok := l.clients["client 0"]; ok {
+		t.Error("least recently seen client limiter was not dropped")
+	}
+}
+
+func TestNewRateLimiterDisabled(t *testing.T) {
+	t.Parallel()
+
+	if l := newRateLimiter(0, 10); l != nil {
+		t.Errorf("newRateLimiter(0, 10) = %v, want nil", l)
+	}
+}
+
+func TestServerRateLimit(t *testing.T) {
+	t.Parallel()
+
+	settings := defaultServerSettings()
+	settings.AccessLog = false
+	settings.ScoreRateLimit = 0.001
+	settings.ScoreRateBurst = 1
+	srv := newTestPasswordServerWithSettings(t, settings, log.New(io.Discard, "", 0))
+
+	if resp := postJSON(t, srv.URL+"/v1/score", `{"passwords": []}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("first status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	resp := postJSON(t, srv.URL+"/v1/score", `{"passwords": []}`)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("second status = %d, want %d", resp.StatusCode, http.StatusTooManyRequests)
+	}
+
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("Retry-After header missing")
+	}
+
+	// generate has no limit set
+	if resp := postJSON(t, srv.URL+"/v1/generate", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("generate status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestServerAccessLogOmitsSecrets(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	settings := defaultServerSettings()
+	settings.AuthTokenFile = writeTokenFile(t, "ci s3cret\n")
+	srv := newTestPasswordServerWithSettings(t, settings, log.New(&buf, "", 0))
+
+	req, _ := http.NewRequest(
+		http.MethodPost,
+		srv.URL+"/v1/score?q=querysecret",
+		strings.NewReader(`{"passwords": ["hunter2"]}`),
+	)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request error = %v", err)
+	}
+	resp.Body.Close()
+
+	got := buf.String()
+	if !strings.HasPrefix(got, "ci POST /v1/score 200 ") {
+		t.Errorf("access log = %q, want it to start with \"ci POST /v1/score 200 \"", got)
+	}
+
+	for _, secret := range []string{"hunter2", "s3cret", "querysecret"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("access log = %q, contains %q", got, secret)
+		}
+	}
+}
+
+func TestNewServerSettings(t *testing.T) {
+	t.Parallel()
+
+	cmd := &cobra.Command{}
+	addServerFlags(cmd.Flags())
+	if err := cmd.Flags().Parse([]string{"--score_rate_limit=5"}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	layers := &configLayers{serverCfg: map[string]any{
+		addressKey:        "0.0.0.0:9000",
+		scoreRateLimitKey: 1,
+		accessLogKey:      false,
+	}}
+
+	settings, err := newServerSettings(cmd, layers)
+	if err != nil {
+		t.Fatalf("newServerSettings() error = %v", err)
+	}
+
+	if settings.Address != "0.0.0.0:9000" || settings.AccessLog {
+		t.Errorf("settings = %+v, want the custom config's address and access log", settings)
+	}
+
+	if settings.ScoreRateLimit != 5 {
+		t.Errorf("ScoreRateLimit = %v, want the flag's 5 over the custom config's 1", settings.ScoreRateLimit)
+	}
+
+	if settings.GenerateRateBurst != defaultGenerateRateBurst {
+		t.Errorf("GenerateRateBurst = %d, want the default %d", settings.GenerateRateBurst, defaultGenerateRateBurst)
+	}
+}
+
+func TestNewServerSettingsErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		serverCfg map[string]any
+	}{
+		{"unknown key", map[string]any{"port": 8080}},
+		{"cert without key", map[string]any{tlsCertFileKey: "cert.pem"}},
+		{"client CA without cert", map[string]any{tlsClientCAFileKey: "ca.pem"}},
+		{"negative rate", map[string]any{generateRateLimitKey: -1}},
+		{"zero burst", map[string]any{scoreRateBurstKey: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cmd := &cobra.Command{}
+			addServerFlags(cmd.Flags())
+			if _, err := newServerSettings(cmd, &configLayers{serverCfg: tt.serverCfg}); err == nil {
+				t.Error("newServerSettings() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestCheckCleartextTokens(t *testing.T) {
+	t.Parallel()
+
+	listen := func(network, address string) net.Listener {
+		ln, err := net.Listen(network, address)
+		if err != nil {
+			t.Fatalf("Listen(%s, %s) error = %v", network, address, err)
+		}
+		t.Cleanup(func() { ln.Close() })
+
+		return ln
+	}
+
+	loopback := listen("tcp", "127.0.0.1:0")
+	all := listen("tcp", "0.0.0.0:0")
+	unix := listen("unix", filepath.Join(t.TempDir(), "mempass.sock"))
+
+	tests := []struct {
+		name     string
+		settings serverSettings
+		lns      []net.Listener
+		wantErr  bool
+	}{
+		{"no tokens", serverSettings{}, []net.Listener{all}, false},
+		{"loopback", serverSettings{AuthTokenFile: "tokens"}, []net.Listener{loopback}, false},
+		{"unix socket", serverSettings{AuthTokenFile: "tokens"}, []net.Listener{unix}, false},
+		{"tls", serverSettings{AuthTokenFile: "tokens", TLSCertFile: "cert.pem"}, []net.Listener{all}, false},
+		{"network", serverSettings{AuthTokenFile: "tokens"}, []net.Listener{loopback, all}, true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.settings.checkCleartextTokens(tt.lns); (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkCleartextTokens() error = %v, wantErr %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
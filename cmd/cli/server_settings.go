@@ -0,0 +1,271 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Key of the custom config section holding the server settings
+const serverConfigKey string = "server"
+
+// Server setting keys, shared by the flags and the server section of a custom
+// config
+const (
+	addressKey           string = "address"
+	authTokenFileKey     string = "auth_token_file"
+	tlsCertFileKey       string = "tls_cert_file"
+	tlsKeyFileKey        string = "tls_key_file"
+	tlsClientCAFileKey   string = "tls_client_ca_file"
+	generateRateLimitKey string = "generate_rate_limit"
+	generateRateBurstKey string = "generate_rate_burst"
+	scoreRateLimitKey    string = "score_rate_limit"
+	scoreRateBurstKey    string = "score_rate_burst"
+	accessLogKey         string = "access_log"
+	uiKey                string = "ui"
+	unixSocketKey        string = "unix_socket"
+	unixSocketModeKey    string = "unix_socket_mode"
+	unixSocketOwnerKey   string = "unix_socket_owner"
+	unixSocketGroupKey   string = "unix_socket_group"
+)
+
+// Default server settings, the rate limits are off until set
+const (
+	defaultAddress           string  = "127.0.0.1:8080"
+	defaultGenerateRateLimit float64 = 0
+	defaultGenerateRateBurst int     = 10
+	defaultScoreRateLimit    float64 = 0
+	defaultScoreRateBurst    int     = 10
+	defaultAccessLog         bool    = true
+	defaultUnixSocketMode    string  = "0660"
+)
+
+// Server setting keys, which are never passed to libpass
+var serverSettingKeys = map[string]struct{}{
+	addressKey:           {},
+	authTokenFileKey:     {},
+	tlsCertFileKey:       {},
+	tlsKeyFileKey:        {},
+	tlsClientCAFileKey:   {},
+	generateRateLimitKey: {},
+	generateRateBurstKey: {},
+	scoreRateLimitKey:    {},
+	scoreRateBurstKey:    {},
+	accessLogKey:         {},
+	uiKey:                {},
+	unixSocketKey:        {},
+	unixSocketModeKey:    {},
+	unixSocketOwnerKey:   {},
+	unixSocketGroupKey:   {},
+}
+
+type serverSettings struct {
+	// The host:port to listen on
+	Address string `json:"address"`
+	// File of bearer tokens clients must present, one per line
+	AuthTokenFile string `json:"auth_token_file"`
+	// Certificate and key to serve TLS with
+	TLSCertFile string `json:"tls_cert_file"`
+	TLSKeyFile  string `json:"tls_key_file"`
+	// CA bundle client certificates must be signed by, enables mTLS
+	TLSClientCAFile string `json:"tls_client_ca_file"`
+	// Generate requests per second allowed per client, 0 for no limit
+	GenerateRateLimit float64 `json:"generate_rate_limit"`
+	// Generate requests a client may make in a single burst
+	GenerateRateBurst int `json:"generate_rate_burst"`
+	// Score requests per second allowed per client, 0 for no limit
+	ScoreRateLimit float64 `json:"score_rate_limit"`
+	// Score requests a client may make in a single burst
+	ScoreRateBurst int `json:"score_rate_burst"`
+	// Whether to log each request, never including bodies or passwords
+	AccessLog bool `json:"access_log"`
+	// Whether to serve the web UI
+	UI bool `json:"ui"`
+	// Unix socket to listen on instead of the address
+	UnixSocket string `json:"unix_socket"`
+	// Octal mode, owner and group of the Unix socket
+	UnixSocketMode  string `json:"unix_socket_mode"`
+	UnixSocketOwner string `json:"unix_socket_owner"`
+	UnixSocketGroup string `json:"unix_socket_group"`
+}
+
+// Returns the server settings used when nothing else is set
+func defaultServerSettings() *serverSettings {
+	return &serverSettings{
+		Address:           defaultAddress,
+		GenerateRateLimit: defaultGenerateRateLimit,
+		GenerateRateBurst: defaultGenerateRateBurst,
+		ScoreRateLimit:    defaultScoreRateLimit,
+		ScoreRateBurst:    defaultScoreRateBurst,
+		AccessLog:         defaultAccessLog,
+		UnixSocketMode:    defaultUnixSocketMode,
+	}
+}
+
+// Adds the server setting flags to a flag set
+func addServerFlags(flags *pflag.FlagSet) {
+	flags.String(addressKey, defaultAddress, "address to listen on, host:port")
+	flags.String(
+		authTokenFileKey,
+		"",
+		"file of bearer tokens clients must present, one per line as \"name token\" or just \"token\"",
+	)
+	flags.String(tlsCertFileKey, "", "certificate file to serve TLS with")
+	flags.String(tlsKeyFileKey, "", "private key file to serve TLS with")
+	flags.String(
+		tlsClientCAFileKey,
+		"",
+		"CA bundle which client certificates must be signed by, requires a client certificate on every request",
+	)
+	flags.Float64(
+		generateRateLimitKey,
+		defaultGenerateRateLimit,
+		"generate requests per second allowed per client, 0 for no limit",
+	)
+	flags.Int(generateRateBurstKey, defaultGenerateRateBurst, "generate requests a client may make in a single burst")
+	flags.Float64(
+		scoreRateLimitKey,
+		defaultScoreRateLimit,
+		"score requests per second allowed per client, 0 for no limit",
+	)
+	flags.Int(scoreRateBurstKey, defaultScoreRateBurst, "score requests a client may make in a single burst")
+	flags.Bool(accessLogKey, defaultAccessLog, "log each request to stderr, never including bodies or passwords")
+	flags.Bool(uiKey, false, "serve a web UI for generating and scoring passwords at /, it works offline")
+	flags.String(unixSocketKey, "", "Unix socket path to listen on instead of the address")
+	flags.String(unixSocketModeKey, defaultUnixSocketMode, "octal mode of the Unix socket")
+	flags.String(unixSocketOwnerKey, "", "user name or uid to own the Unix socket")
+	flags.String(unixSocketGroupKey, "", "group name or gid to own the Unix socket")
+}
+
+// newServerSettings layers the server section of the custom config and then
+// the explicitly set server flags over the defaults
+func newServerSettings(cmd *cobra.Command, layers *configLayers) (*serverSettings, error) {
+	flagCfg, err := collectCmdFlags(cmd, func(name string) bool {
+		_, ok := serverSettingKeys[name]
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make(map[string]any, len(layers.serverCfg)+len(flagCfg))
+	maps.Copy(merged, layers.serverCfg)
+	maps.Copy(merged, flagCfg)
+
+	js, err := json.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal server settings (%w)", err)
+	}
+
+	settings := defaultServerSettings()
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(settings); err != nil {
+		return nil, fmt.Errorf("invalid server settings (%w)", err)
+	}
+
+	if err := settings.validate(); err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// Checks the server settings for values which can't work together
+func (s *serverSettings) validate() error {
+	if (s.TLSCertFile == "") != (s.TLSKeyFile == "") {
+		return fmt.Errorf("%s and %s must be set together", tlsCertFileKey, tlsKeyFileKey)
+	}
+
+	if s.TLSClientCAFile != "" && s.TLSCertFile == "" {
+		return fmt.Errorf("%s requires %s and %s", tlsClientCAFileKey, tlsCertFileKey, tlsKeyFileKey)
+	}
+
+	if s.UnixSocket == "" && (s.UnixSocketOwner != "" || s.UnixSocketGroup != "") {
+		return fmt.Errorf("%s and %s require %s", unixSocketOwnerKey, unixSocketGroupKey, unixSocketKey)
+	}
+
+	if _, err := parseUnixSocketMode(s.UnixSocketMode); err != nil {
+		return err
+	}
+
+	if s.GenerateRateLimit < 0 || s.ScoreRateLimit < 0 {
+		return fmt.Errorf("%s and %s must be greater than or equal to 0", generateRateLimitKey, scoreRateLimitKey)
+	}
+
+	if s.GenerateRateBurst < 1 || s.ScoreRateBurst < 1 {
+		return fmt.Errorf("%s and %s must be greater than 0", generateRateBurstKey, scoreRateBurstKey)
+	}
+
+	return nil
+}
+
+// Checks that bearer tokens aren't sent in cleartext over the network, which
+// they would be with an auth token file and no TLS on anything but a loopback
+// address or a Unix socket
+func (s *serverSettings) checkCleartextTokens(lns []net.Listener) error {
+	if s.AuthTokenFile == "" || s.TLSCertFile != "" {
+		return nil
+	}
+
+	for _, ln := range lns {
+		addr, ok := ln.Addr().(*net.TCPAddr)
+		if ok && !addr.IP.IsLoopback() {
+			return fmt.Errorf(
+				"%s needs %s and %s to listen on %s, or bearer tokens would be sent in cleartext",
+				authTokenFileKey,
+				tlsCertFileKey,
+				tlsKeyFileKey,
+				addr,
+			)
+		}
+	}
+
+	return nil
+}
+
+// Returns the TLS config to serve with, or nil to serve plain HTTP. With a
+// client CA bundle every client must present a certificate signed by it.
+func (s *serverSettings) tlsConfig() (*tls.Config, error) {
+	if s.TLSCertFile == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(s.TLSCertFile, s.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate (%w)", err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if s.TLSClientCAFile == "" {
+		return cfg, nil
+	}
+
+	pem, err := os.ReadFile(s.TLSClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s (%w)", tlsClientCAFileKey, err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no certificates found in " + tlsClientCAFileKey)
+	}
+
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+
+	return cfg, nil
+}
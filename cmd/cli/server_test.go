@@ -0,0 +1,247 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eljamo/libpass/v8/config/option"
+)
+
+func newTestPasswordServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	return newTestPasswordServerWithSettings(t, defaultServerSettings(), log.New(io.Discard, "", 0))
+}
+
+func newTestPasswordServerWithSettings(t *testing.T, settings *serverSettings, logger *log.Logger) *httptest.Server {
+	t.Helper()
+
+	layers := &configLayers{preset: option.PresetDefault}
+	ps, err := newPasswordServer(layers, settings, logger)
+	if err != nil {
+		t.Fatalf("newPasswordServer() error = %v", err)
+	}
+
+	srv := httptest.NewServer(ps.handler())
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func postJSON(t *testing.T, url, body string) *http.Response {
+	t.Helper()
+
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST %s error = %v", url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp
+}
+
+func TestServerGenerate(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestPasswordServer(t)
+	resp := postJSON(t, srv.URL+"/v1/generate", `{"preset": "XKCD", "num_passwords": 2}`)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	if got := resp.Header.Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want \"no-store\"", got)
+	}
+
+	var body generateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if len(body.Passwords) != 2 {
+		t.Fatalf("got %d passwords, want 2", len(body.Passwords))
+	}
+
+	// the XKCD preset separates its words with a dash
+	if !strings.Contains(body.Passwords[0], "-") {
+		t.Errorf("password = %q, want the XKCD preset's dash separator", body.Passwords[0])
+	}
+}
+
+func TestServerGenerateInvalidConfig(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestPasswordServer(t)
+
+	for _, body := range []string{`{"num_words": 1}`, `{"unknown_key": 1}`, `not json`} {
+		resp := postJSON(t, srv.URL+"/v1/generate", body)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("POST %s status = %d, want %d", body, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestServerGenerateOverLimits(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestPasswordServer(t)
+
+	for _, body := range []string{
+		`{"num_words": 1000000000}`,
+		`{"word_length_min": 65, "word_length_max": 100}`,
+		`{"word_length_max": 2000000000}`,
+		`{"padding_digits_before": 1000000}`,
+		`{"padding_digits_after": 1000000}`,
+		`{"padding_type": "FIXED", "padding_characters_before": 1000000}`,
+		`{"padding_type": "FIXED", "padding_characters_after": 1000000}`,
+		`{"padding_type": "ADAPTIVE", "pad_to_length": 2000000000}`,
+	} {
+		resp := postJSON(t, srv.URL+"/v1/generate", body)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("POST %s status = %d, want %d", body, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+
+	resp := postJSON(t, srv.URL+"/v1/generate", `{"num_words": 64, "padding_type": "ADAPTIVE", "pad_to_length": 1024}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("POST at the limits status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestServerScore(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestPasswordServer(t)
+	resp := postJSON(t, srv.URL+"/v1/score", `{"passwords": ["password", "!!12&paper&SEA&onto&12!!"]}`)
+
+	var body scoreResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if len(body.Results) != 2 {
+		t.Fatalf("got %d results, want 2", len(body.Results))
+	}
+
+	if body.Results[0].UnthrottledPasswordEntryScore != 0 || body.Results[1].UnthrottledPasswordEntryScore != 4 {
+		t.Errorf("unthrottled scores = %d, %d, want 0, 4",
+			body.Results[0].UnthrottledPasswordEntryScore, body.Results[1].UnthrottledPasswordEntryScore)
+	}
+}
+
+func TestServerRequestTooLarge(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestPasswordServer(t)
+	body := `{"passwords": ["` + strings.Repeat("a", int(maxRequestBytes)) + `"]}`
+
+	if resp := postJSON(t, srv.URL+"/v1/score", body); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestServerHealthz(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestPasswordServer(t)
+	resp, err := http.Get(srv.URL + "/healthz")
+	if err != nil {
+		t.Fatalf("GET /healthz error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Cache-Control") != "no-store" {
+		t.Errorf("GET /healthz = %d with Cache-Control %q, want 200 and \"no-store\"",
+			resp.StatusCode, resp.Header.Get("Cache-Control"))
+	}
+}
+
+func TestServerGenerateEntropy(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestPasswordServer(t)
+	resp := postJSON(t, srv.URL+"/v1/generate", `{"preset": "XKCD"}`)
+
+	var body generateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if body.Entropy.Bits <= 0 || body.Entropy.TypicalLength <= 0 {
+		t.Errorf("entropy = %+v, want positive bits and typical length", body.Entropy)
+	}
+}
+
+func TestServerOptions(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestPasswordServer(t)
+	resp, err := http.Get(srv.URL + "/v1/options")
+	if err != nil {
+		t.Fatalf("GET /v1/options error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body optionsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if len(body.Presets) != len(option.Presets) || len(body.WordLists) != len(option.WordLists) {
+		t.Fatalf("got %d presets and %d word lists, want %d and %d",
+			len(body.Presets), len(body.WordLists), len(option.Presets), len(option.WordLists))
+	}
+
+	for _, p := range body.Presets {
+		if p.Name == option.PresetXKCD && p.Settings[option.ConfigKeySeparatorCharacter] != "-" {
+			t.Errorf("XKCD separator_character = %v, want \"-\"", p.Settings[option.ConfigKeySeparatorCharacter])
+		}
+	}
+}
+
+func TestServerUI(t *testing.T) {
+	t.Parallel()
+
+	settings := defaultServerSettings()
+	settings.AccessLog = false
+	settings.UI = true
+	srv := newTestPasswordServerWithSettings(t, settings, log.New(io.Discard, "", 0))
+
+	for _, path := range []string{"/", "/assets/app.js", "/assets/style.css"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", path, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s status = %d, want %d", path, resp.StatusCode, http.StatusOK)
+		}
+
+		if resp.Header.Get("Content-Security-Policy") != uiContentSecurityPolicy {
+			t.Errorf("GET %s Content-Security-Policy = %q, want %q",
+				path, resp.Header.Get("Content-Security-Policy"), uiContentSecurityPolicy)
+		}
+	}
+}
+
+func TestServerUIDisabled(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestPasswordServer(t)
+	resp, err := http.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatalf("GET / error = %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET / status = %d, want %d without --ui", resp.StatusCode, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/eljamo/libpass/v8/config"
+)
+
+// Prefix of a settings code, it carries the version of the encoding so a
+// future change to it can still read older codes
+const settingsCodePrefix string = "mempass1:"
+
+// Length of the CRC-32 checksum which trails the compressed settings
+const settingsCodeChecksumLen int = 4
+
+// Most bytes of settings JSON a settings code may decompress to, a code from
+// someone else could otherwise be a compression bomb
+const maxSettingsCodeJSONLen int64 = 64 * 1024
+
+var (
+	ErrSettingsCodeVersion  = errors.New("unsupported settings code version")
+	ErrSettingsCodeChecksum = errors.New("settings code checksum mismatch")
+	ErrSettingsCodeTooLarge = errors.New("settings code decompresses to too much data")
+)
+
+// encodeSettingsCode encodes cfg as a compact, versioned and checksummed
+// settings code: the prefix followed by the base64url encoding of the
+// deflated settings JSON and its CRC-32. Every setting is included, even zero
+// values, so the code reproduces cfg exactly when layered over any preset.
+func encodeSettingsCode(cfg *config.Settings) (string, error) {
+	js, err := json.Marshal(settingsToMap(cfg))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode settings (%w)", err)
+	}
+
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return "", fmt.Errorf("failed to compress settings (%w)", err)
+	}
+
+	if _, err := fw.Write(js); err != nil {
+		return "", fmt.Errorf("failed to compress settings (%w)", err)
+	}
+
+	if err := fw.Close(); err != nil {
+		return "", fmt.Errorf("failed to compress settings (%w)", err)
+	}
+
+	payload := binary.BigEndian.AppendUint32(buf.Bytes(), crc32.ChecksumIEEE(buf.Bytes()))
+
+	return settingsCodePrefix + base64.RawURLEncoding.EncodeToString(payload), nil
+}
+
+// decodeSettingsCode checks the version and checksum of a settings code and
+// returns the settings it carries as a config layer
+func decodeSettingsCode(code string) (map[string]any, error) {
+	encoded, ok := strings.CutPrefix(strings.TrimSpace(code), settingsCodePrefix)
+	if !ok {
+		return nil, ErrSettingsCodeVersion
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid settings code encoding (%w)", err)
+	}
+
+	if len(payload) < settingsCodeChecksumLen {
+		return nil, ErrSettingsCodeChecksum
+	}
+
+	data, sum := payload[:len(payload)-settingsCodeChecksumLen], payload[len(payload)-settingsCodeChecksumLen:]
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(sum) {
+		return nil, ErrSettingsCodeChecksum
+	}
+
+	js, err := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(data)), maxSettingsCodeJSONLen+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress settings (%w)", err)
+	}
+
+	if int64(len(js)) > maxSettingsCodeJSONLen {
+		return nil, ErrSettingsCodeTooLarge
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal(js, &m); err != nil {
+		return nil, fmt.Errorf("failed to decode settings (%w)", err)
+	}
+
+	return m, nil
+}
+
+// Returns every setting in cfg keyed by its config key
+func settingsToMap(cfg *config.Settings) map[string]any {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+
+	m := make(map[string]any, t.NumField())
+	for i := range t.NumField() {
+		m[t.Field(i).Tag.Get("key")] = v.Field(i).Interface()
+	}
+
+	return m
+}
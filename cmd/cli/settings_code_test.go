@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eljamo/libpass/v8/config"
+	"github.com/eljamo/libpass/v8/config/option"
+)
+
+func TestSettingsCodeRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	want, err := resolveConfigSource(option.PresetWiFi)
+	if err != nil {
+		t.Fatalf("resolveConfigSource() error = %v", err)
+	}
+	// zero values must survive being layered over a preset which sets them
+	want.PaddingDigitsBefore = 0
+
+	code, err := encodeSettingsCode(want)
+	if err != nil {
+		t.Fatalf("encodeSettingsCode() error = %v", err)
+	}
+
+	if !strings.HasPrefix(code, settingsCodePrefix) {
+		t.Errorf("encodeSettingsCode() = %q, want prefix %q", code, settingsCodePrefix)
+	}
+
+	codeCfg, err := decodeSettingsCode(code)
+	if err != nil {
+		t.Fatalf("decodeSettingsCode() error = %v", err)
+	}
+
+	basePreset, err := loadBasePreset(option.PresetXKCD)
+	if err != nil {
+		t.Fatalf("loadBasePreset() error = %v", err)
+	}
+
+	got, err := config.New(basePreset, codeCfg)
+	if err != nil {
+		t.Fatalf("config.New() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded settings = %+v, want %+v", got, want)
+	}
+}
+
+// Returns a settings code carrying js, however big it is
+func newTestSettingsCode(t *testing.T, js []byte) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		t.Fatalf("flate.NewWriter() error = %v", err)
+	}
+
+	if _, err := w.Write(js); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	payload := binary.BigEndian.AppendUint32(buf.Bytes(), crc32.ChecksumIEEE(buf.Bytes()))
+
+	return settingsCodePrefix + base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func TestDecodeSettingsCodeErrors(t *testing.T) {
+	t.Parallel()
+
+	code, err := encodeSettingsCode(config.DefaultSettings())
+	if err != nil {
+		t.Fatalf("encodeSettingsCode() error = %v", err)
+	}
+
+	// flip a character in the middle of the payload
+	i := len(settingsCodePrefix) + (len(code)-len(settingsCodePrefix))/2
+	flipped := byte('A')
+	if code[i] == 'A' {
+		flipped = 'B'
+	}
+	tampered := code[:i] + string(flipped) + code[i+1:]
+
+	tests := []struct {
+		name string
+		code string
+		want error
+	}{
+		{"tampered", tampered, ErrSettingsCodeChecksum},
+		{"compression bomb", newTestSettingsCode(t, bytes.Repeat([]byte(" "), 16<<20)), ErrSettingsCodeTooLarge},
+		{"unknown version", strings.Replace(code, "mempass1:", "mempass2:", 1), ErrSettingsCodeVersion},
+		{"truncated", settingsCodePrefix + "AA", ErrSettingsCodeChecksum},
+	}
+
+	for _, tt := range tests {
+		if _, err := decodeSettingsCode(tt.code); !errors.Is(err, tt.want) {
+			t.Errorf("decodeSettingsCode(%s) error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"crypto/sha512"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// SHA-512 crypt round limits and default, from the glibc crypt(3) spec
+const (
+	sha512CryptMinRounds     int = 1000
+	sha512CryptMaxRounds     int = 999999999
+	sha512CryptDefaultRounds int = 5000
+	sha512CryptMaxSaltLength int = 16
+)
+
+// Alphabet of the base64 variant used by crypt(3)
+const cryptAlphabet string = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// Order the digest bytes are encoded in, three at a time
+var sha512CryptOrder = [...][3]int{
+	{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4},
+	{47, 5, 26}, {6, 27, 48}, {28, 49, 7}, {50, 8, 29}, {9, 30, 51},
+	{31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13}, {56, 14, 35},
+	{15, 36, 57}, {37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19},
+	{62, 20, 41},
+}
+
+// sha512Crypt hashes pw the way crypt(3) does for the $6$ scheme, as stored
+// in /etc/shadow, following Ulrich Drepper's "Unix crypt using SHA-256 and
+// SHA-512". The salt is cut to 16 characters and the rounds clamped to the
+// allowed range, the same as crypt(3) does.
+func sha512Crypt(pw, salt string, rounds int) string {
+	if len(salt) > sha512CryptMaxSaltLength {
+		salt = salt[:sha512CryptMaxSaltLength]
+	}
+	rounds = min(max(rounds, sha512CryptMinRounds), sha512CryptMaxRounds)
+
+	p, s := []byte(pw), []byte(salt)
+
+	b := sha512.New()
+	b.Write(p)
+	b.Write(s)
+	b.Write(p)
+	digestB := b.Sum(nil)
+
+	a := sha512.New()
+	a.Write(p)
+	a.Write(s)
+	writeRepeated(a, digestB, len(p))
+	for n := len(p); n > 0; n >>= 1 {
+		if n&1 == 1 {
+			a.Write(digestB)
+		} else {
+			a.Write(p)
+		}
+	}
+	digestA := a.Sum(nil)
+
+	dp := sha512.New()
+	for range len(p) {
+		dp.Write(p)
+	}
+	pSeq := repeatToLength(dp.Sum(nil), len(p))
+
+	ds := sha512.New()
+	for range 16 + int(digestA[0]) {
+		ds.Write(s)
+	}
+	sSeq := repeatToLength(ds.Sum(nil), len(s))
+
+	c := sha512.New()
+	for i := range rounds {
+		c.Reset()
+		if i%2 == 1 {
+			c.Write(pSeq)
+		} else {
+			c.Write(digestA)
+		}
+		if i%3 != 0 {
+			c.Write(sSeq)
+		}
+		if i%7 != 0 {
+			c.Write(pSeq)
+		}
+		if i%2 == 1 {
+			c.Write(digestA)
+		} else {
+			c.Write(pSeq)
+		}
+		digestA = c.Sum(digestA[:0])
+	}
+
+	var out strings.Builder
+	out.WriteString("$6$")
+	if rounds != sha512CryptDefaultRounds {
+		out.WriteString("rounds=" + strconv.Itoa(rounds) + "$")
+	}
+	out.WriteString(salt + "$")
+	for _, o := range sha512CryptOrder {
+		writeCryptBase64(&out, uint(digestA[o[0]])<<16|uint(digestA[o[1]])<<8|uint(digestA[o[2]]), 4)
+	}
+	writeCryptBase64(&out, uint(digestA[63]), 2)
+
+	return out.String()
+}
+
+// Writes n bytes of digest to h, repeating it as needed
+func writeRepeated(h hash.Hash, digest []byte, n int) {
+	for ; n > len(digest); n -= len(digest) {
+		h.Write(digest)
+	}
+	h.Write(digest[:n])
+}
+
+// Returns digest repeated to n bytes
+func repeatToLength(digest []byte, n int) []byte {
+	seq := make([]byte, 0, n)
+	for len(seq) < n {
+		seq = append(seq, digest[:min(len(digest), n-len(seq))]...)
+	}
+
+	return seq
+}
+
+// Writes the low 6*n bits of w with the crypt(3) base64 alphabet, least
+// significant first
+func writeCryptBase64(out *strings.Builder, w uint, n int) {
+	for range n {
+		out.WriteByte(cryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+}
@@ -0,0 +1,397 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/eljamo/libpass/v8/config"
+	"github.com/eljamo/libpass/v8/config/option"
+	"github.com/eljamo/libpass/v8/service"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// Width of the score and entropy bars in cells
+const tuiBarWidth int = 20
+
+// Entropy at which the entropy bar is full
+const tuiMaxEntropyBits float64 = 128
+
+// Highest zxcvbn score
+const maxScore int = 4
+
+// Minimum number of words libpass accepts
+const minNumWords int = 2
+
+// Path of the process's controlling terminal
+const ttyPath string = "/dev/tty"
+
+// Escape sequences switching to and from the alternate screen, hiding the
+// cursor while it's shown
+const (
+	enterAltScreen string = "\x1b[?1049h\x1b[?25l"
+	exitAltScreen  string = "\x1b[?25h\x1b[?1049l"
+	clearScreen    string = "\x1b[H\x1b[2J"
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Pick and tune passwords in an interactive terminal UI",
+	Long: `Pick and tune passwords in an interactive terminal UI.
+
+Shows a batch of candidates with their zxcvbn scores and the entropy of the
+config, which updates as the config is changed with keys:
+
+  up/down, k/j  select a password
+  r             regenerate the selected password
+  R, space      regenerate every password
+  c             cycle the case transform
+  w             cycle the word list
+  s             cycle the separator character
+  p             cycle the padding type
+  +/-           add or remove a word
+  enter         print the selected password to stdout and exit
+  y             copy the selected password to the clipboard and exit
+  q, esc        exit without printing or copying anything
+
+The UI is drawn on the terminal rather than stdout, so the chosen password
+can be captured, e.g. pw=$(mempass tui). Copying works the same way as --clip
+does when generating passwords, clearing the clipboard after --clip_timeout`,
+	Args: cobra.NoArgs,
+	RunE: runTUICmd,
+}
+
+func runTUICmd(cmd *cobra.Command, args []string) error {
+	cfg, err := generateConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to generate config: %w", err)
+	}
+
+	m, err := newTUIModel(cfg, service.NewRNGService())
+	if err != nil {
+		return fmt.Errorf("failed to generate passwords: %w", err)
+	}
+
+	tty, err := os.OpenFile(ttyPath, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open terminal: %w", err)
+	}
+	defer tty.Close()
+
+	if err := runTUI(tty, m); err != nil {
+		return fmt.Errorf("failed to run terminal UI: %w", err)
+	}
+
+	switch {
+	case m.chosen == "":
+	case m.copy:
+		return clipPassword(cmd, m.chosen)
+	default:
+		fmt.Fprintln(cmd.OutOrStdout(), m.chosen)
+	}
+
+	return nil
+}
+
+// Shows the model on the terminal in raw mode, passing it each key pressed
+// until it quits
+func runTUI(tty *os.File, m *tuiModel) error {
+	fd := int(tty.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to set terminal to raw mode (%w)", err)
+	}
+	defer term.Restore(fd, state)
+
+	if _, err := io.WriteString(tty, enterAltScreen); err != nil {
+		return fmt.Errorf("failed to write to terminal (%w)", err)
+	}
+	defer io.WriteString(tty, exitAltScreen)
+
+	buf := make([]byte, 256)
+	for {
+		// raw mode doesn't turn \n into \r\n
+		view := clearScreen + strings.ReplaceAll(m.view(), "\n", "\r\n")
+		if _, err := io.WriteString(tty, view); err != nil {
+			return fmt.Errorf("failed to write to terminal (%w)", err)
+		}
+
+		n, err := tty.Read(buf)
+		if err != nil {
+			return fmt.Errorf("failed to read from terminal (%w)", err)
+		}
+
+		for _, key := range parseKeys(buf[:n]) {
+			if m.update(key) {
+				return nil
+			}
+		}
+	}
+}
+
+// Returns the names of the keys in input read from a terminal in raw mode.
+// Printable keys are named by their character, escape sequences for keys
+// other than up and down are dropped.
+func parseKeys(input []byte) []string {
+	var keys []string
+	for len(input) > 0 {
+		switch {
+		case bytes.HasPrefix(input, []byte("\x1b[A")), bytes.HasPrefix(input, []byte("\x1bOA")):
+			keys = append(keys, "up")
+			input = input[3:]
+		case bytes.HasPrefix(input, []byte("\x1b[B")), bytes.HasPrefix(input, []byte("\x1bOB")):
+			keys = append(keys, "down")
+			input = input[3:]
+		case bytes.HasPrefix(input, []byte("\x1b[")):
+			// skip to the final byte of the control sequence
+			end := bytes.IndexFunc(input[2:], func(r rune) bool { return r >= 0x40 && r <= 0x7e })
+			if end == -1 {
+				return keys
+			}
+			input = input[2+end+1:]
+		case input[0] == '\x1b' && len(input) == 1:
+			keys = append(keys, "esc")
+			input = input[1:]
+		case input[0] == '\x1b':
+			// alt with another key
+			_, size := utf8.DecodeRune(input[1:])
+			input = input[1+size:]
+		case input[0] == '\r', input[0] == '\n':
+			keys = append(keys, "enter")
+			input = input[1:]
+		case input[0] == 0x03:
+			keys = append(keys, "ctrl+c")
+			input = input[1:]
+		default:
+			r, size := utf8.DecodeRune(input)
+			keys = append(keys, string(r))
+			input = input[size:]
+		}
+	}
+
+	return keys
+}
+
+// A candidate password, its unthrottled zxcvbn score and its line as printed
+// by generate --score
+type tuiCandidate struct {
+	password string
+	score    int
+	line     string
+}
+
+// tuiModel is the state of the terminal UI. Every change to the config is
+// validated by libpass before it's applied, an invalid one is reported and
+// the previous config kept.
+type tuiModel struct {
+	cfg        *config.Settings
+	rngSvc     service.RNGService
+	candidates []tuiCandidate
+	selected   int
+	entropy    entropyEstimate
+	warning    string
+	err        error
+	chosen     string
+	copy       bool
+}
+
+// Creates the model with a first batch of candidates generated from cfg
+func newTUIModel(cfg *config.Settings, rngSvc service.RNGService) (*tuiModel, error) {
+	m := &tuiModel{cfg: cfg, rngSvc: rngSvc}
+	if err := m.regenerateAll(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Handles a key press, returning true when the UI should exit
+func (m *tuiModel) update(key string) bool {
+	m.err = nil
+	switch key {
+	case "ctrl+c", "q", "esc":
+		return true
+	case "enter":
+		m.chosen = m.candidates[m.selected].password
+		return true
+	case "y":
+		m.chosen = m.candidates[m.selected].password
+		m.copy = true
+		return true
+	case "up", "k":
+		m.selected = max(m.selected-1, 0)
+	case "down", "j":
+		m.selected = min(m.selected+1, len(m.candidates)-1)
+	case "r":
+		m.err = m.regenerate(m.selected)
+	case "R", " ":
+		m.err = m.regenerateAll()
+	case "c":
+		m.err = m.apply(func(cfg *config.Settings) {
+			cfg.CaseTransform = nextOption(option.TransformTypes, cfg.CaseTransform)
+		})
+	case "w":
+		m.err = m.apply(func(cfg *config.Settings) {
+			cfg.WordList = nextOption(option.WordLists, cfg.WordList)
+		})
+	case "s":
+		m.err = m.apply(func(cfg *config.Settings) {
+			cfg.SeparatorCharacter = nextOption(option.SeparatorCharacterOptions, cfg.SeparatorCharacter)
+		})
+	case "p":
+		m.err = m.apply(func(cfg *config.Settings) {
+			cfg.PaddingType = nextOption(option.PaddingTypes, cfg.PaddingType)
+		})
+	case "+", "=":
+		m.err = m.apply(func(cfg *config.Settings) { cfg.NumWords++ })
+	case "-":
+		m.err = m.apply(func(cfg *config.Settings) { cfg.NumWords = max(cfg.NumWords-1, minNumWords) })
+	}
+
+	return false
+}
+
+// Returns the option after current, wrapping around. A current value which
+// isn't an option moves to the first one.
+func nextOption(options []string, current string) string {
+	i := slices.Index(options, current)
+	return options[(i+1)%len(options)]
+}
+
+// Applies change to a copy of the config, and if libpass accepts it, switches
+// to it and regenerates every candidate
+func (m *tuiModel) apply(change func(cfg *config.Settings)) error {
+	next := *m.cfg
+	change(&next)
+
+	cfg, err := newConfig(option.PresetDefault, settingsToMap(&next))
+	if err != nil {
+		return err
+	}
+
+	prev := m.cfg
+	m.cfg = cfg
+	if err := m.regenerateAll(); err != nil {
+		m.cfg = prev
+		return err
+	}
+
+	return nil
+}
+
+// Generates n passwords from the current config
+func (m *tuiModel) generate(n int) ([]string, error) {
+	cfg := *m.cfg
+	cfg.NumPasswords = n
+
+	pgs, err := newPasswordGeneratorService(&cfg, m.rngSvc)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgs.Generate()
+}
+
+// Replaces every candidate and updates the entropy of the current config
+func (m *tuiModel) regenerateAll() error {
+	est, err := estimateEntropy(m.cfg)
+	if err != nil {
+		return err
+	}
+
+	pws, err := m.generate(m.cfg.NumPasswords)
+	if err != nil {
+		return err
+	}
+
+	m.entropy = est
+	m.candidates = make([]tuiCandidate, len(pws))
+	for i, p := range pws {
+		m.candidates[i].password = p
+	}
+	m.selected = min(m.selected, len(m.candidates)-1)
+	m.score()
+
+	return nil
+}
+
+// Replaces the candidate at i
+func (m *tuiModel) regenerate(i int) error {
+	pws, err := m.generate(1)
+	if err != nil {
+		return err
+	}
+
+	m.candidates[i].password = pws[0]
+	m.score()
+
+	return nil
+}
+
+// Scores every candidate and works out the batch's warning
+func (m *tuiModel) score() {
+	pws := make([]string, len(m.candidates))
+	for i, c := range m.candidates {
+		pws[i] = c.password
+	}
+
+	// scored once, for both the line's labels and the bar
+	results := scorePasswords(pws)
+	lines, warning := evaluateScores(pws, results, true)
+	for i := range m.candidates {
+		m.candidates[i].line = lines[i]
+		m.candidates[i].score = results[i].UnthrottledPasswordEntryScore
+	}
+	m.warning = warning
+}
+
+func (m *tuiModel) view() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "mempass  %d words from %s, %s case, separator %s, padding %s\n\n",
+		m.cfg.NumWords, m.cfg.WordList, m.cfg.CaseTransform, m.cfg.SeparatorCharacter, m.cfg.PaddingType)
+
+	fmt.Fprintf(&b, "Entropy  %s  %.1f bits, about %d characters\n\n",
+		bar(m.entropy.Bits/tuiMaxEntropyBits), m.entropy.Bits, m.entropy.TypicalLength)
+
+	for i, c := range m.candidates {
+		cursor := "  "
+		if i == m.selected {
+			cursor = "> "
+		}
+
+		fmt.Fprintf(&b, "%s%s  %s\n", cursor, bar(float64(c.score)/float64(maxScore)), c.line)
+	}
+
+	if m.warning != "" {
+		fmt.Fprintf(&b, "\n%s\n", m.warning)
+	}
+
+	if m.err != nil {
+		fmt.Fprintf(&b, "\nError: %v\n", m.err)
+	}
+
+	b.WriteString("\nr/R regenerate  c case  w word list  s separator  p padding  +/- words  enter print  y copy  q quit\n")
+
+	return b.String()
+}
+
+// Returns a bar tuiBarWidth cells wide, filled to fraction
+func bar(fraction float64) string {
+	filled := int(math.Round(math.Max(0, math.Min(1, fraction)) * float64(tuiBarWidth)))
+
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", tuiBarWidth-filled) + "]"
+}
+
+func init() {
+	addClipTimeoutFlag(tuiCmd.Flags())
+	addConfigFlags(tuiCmd.Flags())
+
+	rootCmd.AddCommand(tuiCmd)
+}
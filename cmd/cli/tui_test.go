@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eljamo/libpass/v8/config"
+	"github.com/eljamo/libpass/v8/config/option"
+)
+
+func newTestTUIModel(t *testing.T) *tuiModel {
+	t.Helper()
+
+	m, err := newTUIModel(config.DefaultSettings(), newSeededRNGService(1))
+	if err != nil {
+		t.Fatalf("newTUIModel() error = %v", err)
+	}
+
+	return m
+}
+
+// Presses each key in turn, returning whether the last one exited
+func pressKeys(m *tuiModel, keys ...string) bool {
+	var quit bool
+	for _, k := range keys {
+		quit = m.update(k)
+	}
+
+	return quit
+}
+
+func TestParseKeys(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"\x1b[A\x1bOB", []string{"up", "down"}},
+		{"\x1b", []string{"esc"}},
+		{"\x1b[1;5C+\r", []string{"+", "enter"}},
+		{"\x1bxR \x03", []string{"R", " ", "ctrl+c"}},
+		{"é", []string{"é"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseKeys([]byte(tt.input)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseKeys(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestNextOption(t *testing.T) {
+	t.Parallel()
+
+	options := []string{"a", "b", "c"}
+	tests := []struct {
+		current string
+		want    string
+	}{
+		{"a", "b"},
+		{"c", "a"},
+		{"unknown", "a"},
+	}
+
+	for _, tt := range tests {
+		if got := nextOption(options, tt.current); got != tt.want {
+			t.Errorf("nextOption(%q) = %q, want %q", tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestTUIModelRegenerateSelected(t *testing.T) {
+	t.Parallel()
+
+	m := newTestTUIModel(t)
+	before := make([]string, len(m.candidates))
+	for i, c := range m.candidates {
+		before[i] = c.password
+	}
+
+	pressKeys(m, "down", "r")
+
+	for i, c := range m.candidates {
+		changed := c.password != before[i]
+		if changed != (i == 1) {
+			t.Errorf("candidate %d changed = %t, want %t", i, changed, i == 1)
+		}
+	}
+}
+
+func TestTUIModelConfigKeys(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key   string
+		check func(cfg *config.Settings) bool
+	}{
+		{"c", func(cfg *config.Settings) bool {
+			return cfg.CaseTransform == nextOption(option.TransformTypes, config.DefaultSettings().CaseTransform)
+		}},
+		{"w", func(cfg *config.Settings) bool {
+			return cfg.WordList == nextOption(option.WordLists, config.DefaultSettings().WordList)
+		}},
+		{"+", func(cfg *config.Settings) bool { return cfg.NumWords == config.DefaultSettings().NumWords+1 }},
+		{"-", func(cfg *config.Settings) bool { return cfg.NumWords == config.DefaultSettings().NumWords-1 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			t.Parallel()
+
+			m := newTestTUIModel(t)
+			before := m.entropy.Bits
+			pressKeys(m, tt.key)
+
+			if m.err != nil {
+				t.Fatalf("update(%q) error = %v", tt.key, m.err)
+			}
+
+			if !tt.check(m.cfg) {
+				t.Errorf("update(%q) config = %+v", tt.key, m.cfg)
+			}
+
+			if tt.key == "+" && m.entropy.Bits <= before {
+				t.Errorf("entropy after + = %f, want more than %f", m.entropy.Bits, before)
+			}
+		})
+	}
+}
+
+func TestTUIModelChoose(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key      string
+		wantCopy bool
+	}{
+		{"enter", false},
+		{"y", true},
+	}
+
+	for _, tt := range tests {
+		m := newTestTUIModel(t)
+		want := m.candidates[1].password
+
+		if !pressKeys(m, "down", tt.key) {
+			t.Fatalf("update(%q) did not quit", tt.key)
+		}
+
+		if m.chosen != want {
+			t.Errorf("update(%q) chosen = %q, want %q", tt.key, m.chosen, want)
+		}
+
+		if m.copy != tt.wantCopy {
+			t.Errorf("update(%q) copy = %t, want %t", tt.key, m.copy, tt.wantCopy)
+		}
+	}
+}
+
+func TestTUIModelQuitChoosesNothing(t *testing.T) {
+	t.Parallel()
+
+	m := newTestTUIModel(t)
+	if !pressKeys(m, "q") {
+		t.Fatal("update(q) did not quit")
+	}
+
+	if m.chosen != "" {
+		t.Errorf("update(q) chosen = %q, want none", m.chosen)
+	}
+}
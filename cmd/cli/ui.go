@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/eljamo/libpass/v8/config/option"
+)
+
+// The web UI served by serve --ui, a single page with no external resources so
+// it works offline
+//
+//go:embed ui
+var uiFS embed.FS
+
+// Restricts the web UI to its own origin, it must never load anything from or
+// send anything to another one
+const uiContentSecurityPolicy string = "default-src 'self'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+
+// A named option with its description
+type describedOption struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// A built-in preset with the settings it produces
+type presetOption struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Settings    map[string]any `json:"settings"`
+}
+
+// Body of an options response, everything the web UI needs to build its form
+type optionsResponse struct {
+	Presets             []presetOption    `json:"presets"`
+	WordLists           []describedOption `json:"word_lists"`
+	CaseTransforms      []string          `json:"case_transforms"`
+	PaddingTypes        []string          `json:"padding_types"`
+	PaddingCharacters   []string          `json:"padding_characters"`
+	SeparatorCharacters []string          `json:"separator_characters"`
+}
+
+// Returns the web UI's routes, the page itself needs no auth as it holds no
+// secrets, the API calls it makes do
+func uiHandler() http.Handler {
+	assets, err := fs.Sub(uiFS, "ui")
+	if err != nil {
+		panic(err) // the embedded directory always exists
+	}
+
+	files := http.FileServerFS(assets)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", uiContentSecurityPolicy)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		files.ServeHTTP(w, r)
+	})
+}
+
+// Lists the built-in presets, with their settings, and the values allowed for
+// each option with a fixed set of them
+func (s *passwordServer) handleOptions(w http.ResponseWriter, r *http.Request) {
+	presets := make([]presetOption, 0, len(option.Presets))
+	for _, name := range option.Presets {
+		cfg, err := resolveConfigSource(name)
+		if err != nil {
+			s.writeError(w, err)
+			return
+		}
+
+		presets = append(presets, presetOption{name, option.PresetDescriptionMap[name], settingsToMap(cfg)})
+	}
+
+	wordLists := make([]describedOption, 0, len(option.WordLists))
+	for _, name := range option.WordLists {
+		wordLists = append(wordLists, describedOption{name, option.WordListDescriptionMap[name]})
+	}
+
+	s.writeJSON(w, http.StatusOK, optionsResponse{
+		Presets:             presets,
+		WordLists:           wordLists,
+		CaseTransforms:      option.TransformTypes,
+		PaddingTypes:        option.PaddingTypes,
+		PaddingCharacters:   option.PaddingCharacterOptions,
+		SeparatorCharacters: option.SeparatorCharacterOptions,
+	})
+}
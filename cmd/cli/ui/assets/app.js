@@ -0,0 +1,242 @@
+"use strict";
+
+// Labels for each zxcvbn score, the same as the CLI's --score
+const scoreLabels = ["Very Weak", "Weak", "Fair", "Strong", "Very Strong"];
+
+// Delay after the last edit before generating again
+const generateDelayMs = 300;
+
+const tokenStorageKey = "mempass-token";
+
+const form = document.getElementById("settings");
+const presetSelect = document.getElementById("preset");
+const tokenInput = document.getElementById("token");
+
+let presets = [];
+let generateTimer;
+let generation = 0;
+
+class UnauthorizedError extends Error {}
+
+async function api(method, path, body) {
+  const headers = {};
+  const token = sessionStorage.getItem(tokenStorageKey);
+  if (token) {
+    headers.Authorization = `Bearer ${token}`;
+  }
+  if (body !== undefined) {
+    headers["Content-Type"] = "application/json";
+  }
+
+  const resp = await fetch(path, {
+    method,
+    headers,
+    body: body === undefined ? undefined : JSON.stringify(body),
+    cache: "no-store",
+  });
+
+  if (resp.status === 401) {
+    document.getElementById("auth").hidden = false;
+    throw new UnauthorizedError("This server requires an access token");
+  }
+
+  const data = await resp.json();
+  if (!resp.ok) {
+    throw new Error(data.error || resp.statusText);
+  }
+
+  return data;
+}
+
+function fillSelect(select, values) {
+  select.replaceChildren(
+    ...values.map((v) => {
+      const name = typeof v === "string" ? v : v.name;
+      const option = new Option(name, name);
+      if (v.description) {
+        option.title = v.description;
+      }
+      return option;
+    }),
+  );
+}
+
+function fillDatalist(id, values) {
+  document.getElementById(id).replaceChildren(...values.map((v) => new Option(v)));
+}
+
+function applyPreset(name) {
+  const preset = presets.find((p) => p.name === name);
+  if (!preset) {
+    return;
+  }
+
+  document.getElementById("preset-description").textContent = preset.description;
+
+  for (const field of form.querySelectorAll("[data-type]")) {
+    const value = preset.settings[field.name];
+    if (field.dataset.type === "list") {
+      field.value = (value || []).join(",");
+    } else {
+      field.value = value ?? "";
+    }
+  }
+}
+
+// Returns the form as a generate request, the same keys as a custom config
+function collectSettings() {
+  const settings = { preset: presetSelect.value };
+
+  for (const field of form.querySelectorAll("[data-type]")) {
+    const value = field.value.trim();
+    switch (field.dataset.type) {
+      case "int":
+        if (value !== "") {
+          settings[field.name] = Number.parseInt(value, 10);
+        }
+        break;
+      case "list":
+        settings[field.name] = value === "" ? [] : value.split(",").map((c) => c.trim()).filter(Boolean);
+        break;
+      default:
+        // a space is a valid separator or padding character, so don't trim
+        if (field.value !== "") {
+          settings[field.name] = field.value;
+        }
+    }
+  }
+
+  return settings;
+}
+
+function showMessage(id, text) {
+  const el = document.getElementById(id);
+  el.textContent = text || "";
+  el.hidden = !text;
+}
+
+function describeEntropy(bits) {
+  if (bits < 52) {
+    return "weak";
+  }
+  if (bits < 72) {
+    return "reasonable";
+  }
+  return "strong";
+}
+
+function renderPasswords(passwords) {
+  const template = document.getElementById("password-row");
+  const rows = passwords.map((password) => {
+    const row = template.content.firstElementChild.cloneNode(true);
+    row.querySelector(".password").textContent = password;
+
+    const copy = row.querySelector(".copy");
+    copy.addEventListener("click", async () => {
+      try {
+        await navigator.clipboard.writeText(password);
+        copy.textContent = "Copied";
+      } catch {
+        copy.textContent = "Copy failed";
+      }
+      setTimeout(() => {
+        copy.textContent = "Copy";
+      }, 1500);
+    });
+
+    return row;
+  });
+
+  document.getElementById("passwords").replaceChildren(...rows);
+
+  return rows;
+}
+
+async function generate() {
+  const current = ++generation;
+  showMessage("error", "");
+
+  try {
+    const result = await api("POST", "/v1/generate", collectSettings());
+    if (current !== generation) {
+      return;
+    }
+
+    const { bits, typical_length: length } = result.entropy;
+    document.getElementById("entropy-meter").value = bits;
+    document.getElementById("entropy-text").textContent =
+      `${bits.toFixed(1)} bits (${describeEntropy(bits)}), about ${length} characters`;
+    showMessage("warning", result.warning);
+
+    const rows = renderPasswords(result.passwords);
+    const scores = await api("POST", "/v1/score", { passwords: result.passwords });
+    if (current !== generation) {
+      return;
+    }
+
+    scores.results.forEach((r, i) => {
+      const score = r.unthrottled_password_entry_score;
+      rows[i].querySelector(".score").value = score;
+      rows[i].querySelector(".score-text").textContent =
+        `${scoreLabels[score]}, offline crack time ${r.crack_times_display.offline_fast_hashing_1e13_per_second}`;
+    });
+  } catch (err) {
+    if (current === generation) {
+      showMessage("error", err.message);
+    }
+  }
+}
+
+function scheduleGenerate() {
+  clearTimeout(generateTimer);
+  generateTimer = setTimeout(generate, generateDelayMs);
+}
+
+async function init() {
+  tokenInput.value = sessionStorage.getItem(tokenStorageKey) || "";
+
+  let options;
+  try {
+    options = await api("GET", "/v1/options");
+  } catch (err) {
+    showMessage("error", err.message);
+    return;
+  }
+
+  presets = options.presets;
+  fillSelect(presetSelect, presets);
+  fillSelect(form.elements.word_list, options.word_lists);
+  fillSelect(form.elements.case_transform, options.case_transforms);
+  fillSelect(form.elements.padding_type, options.padding_types);
+  fillDatalist("separator-characters", options.separator_characters);
+  fillDatalist("padding-characters", options.padding_characters);
+
+  applyPreset(presetSelect.value);
+  await generate();
+}
+
+tokenInput.addEventListener("change", () => {
+  sessionStorage.setItem(tokenStorageKey, tokenInput.value);
+  if (presets.length === 0) {
+    init();
+  } else {
+    generate();
+  }
+});
+
+presetSelect.addEventListener("change", () => {
+  applyPreset(presetSelect.value);
+  generate();
+});
+
+form.addEventListener("input", (event) => {
+  if (event.target !== presetSelect && event.target !== tokenInput) {
+    scheduleGenerate();
+  }
+});
+
+form.addEventListener("submit", (event) => event.preventDefault());
+
+document.getElementById("generate").addEventListener("click", generate);
+
+init();
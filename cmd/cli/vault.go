@@ -0,0 +1,300 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Constant for the vault file flag key
+const vaultFileKey string = "vault_file"
+
+// Version of the vault contents, bumped when they change incompatibly
+const vaultVersion int = 1
+
+// scrypt work factor (log2 of N) for new vaults, age's default, about a
+// second on a laptop
+const vaultScryptWorkFactor int = 18
+
+// ErrWrongVaultPassphrase is returned when the vault can't be decrypted with
+// the passphrase given
+var ErrWrongVaultPassphrase = errors.New("wrong vault passphrase")
+
+var vaultCmd = &cobra.Command{
+	Use:   "vault",
+	Short: "Keep generated passwords in an encrypted local vault",
+	Long: `Keep generated passwords under labels in a local vault file, encrypted with a
+passphrase. The vault is an age file, the passphrase is stretched with scrypt
+and the contents sealed with ChaCha20-Poly1305, so it can also be decrypted
+with the age tool. It's in $XDG_DATA_HOME/mempass/vault.age unless
+--vault_file is given`,
+}
+
+// vault is the decrypted contents of a vault file
+type vault struct {
+	Version int          `json:"version"`
+	Entries []vaultEntry `json:"entries"`
+}
+
+// vaultEntry is a password stored in the vault under a label
+type vaultEntry struct {
+	Label    string    `json:"label"`
+	Password string    `json:"password"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+}
+
+// Returns the index of the entry with the label, -1 when there isn't one
+func (v *vault) find(label string) int {
+	return slices.IndexFunc(v.Entries, func(e vaultEntry) bool { return e.Label == label })
+}
+
+// Returns the entry with the label
+func (v *vault) get(label string) (vaultEntry, error) {
+	i := v.find(label)
+	if i < 0 {
+		return vaultEntry{}, fmt.Errorf("%s isn't in the vault", label)
+	}
+
+	return v.Entries[i], nil
+}
+
+// Adds an entry for the label, which mustn't be in the vault yet
+func (v *vault) add(label, pw string, now time.Time) error {
+	if v.find(label) >= 0 {
+		return fmt.Errorf("%s is already in the vault, use vault rotate to change its password", label)
+	}
+
+	v.Entries = append(v.Entries, vaultEntry{Label: label, Password: pw, Created: now, Updated: now})
+	slices.SortFunc(v.Entries, func(a, b vaultEntry) int { return strings.Compare(a.Label, b.Label) })
+
+	return nil
+}
+
+// Replaces the password of the entry with the label
+func (v *vault) rotate(label, pw string, now time.Time) error {
+	i := v.find(label)
+	if i < 0 {
+		return fmt.Errorf("%s isn't in the vault, use vault add to add it", label)
+	}
+
+	v.Entries[i].Password = pw
+	v.Entries[i].Updated = now
+
+	return nil
+}
+
+// Removes the entry with the label
+func (v *vault) remove(label string) error {
+	i := v.find(label)
+	if i < 0 {
+		return fmt.Errorf("%s isn't in the vault", label)
+	}
+
+	v.Entries = slices.Delete(v.Entries, i, i+1)
+
+	return nil
+}
+
+// Returns the vault encrypted with the passphrase, as an age file
+func encryptVault(v *vault, passphrase string, workFactor int) ([]byte, error) {
+	r, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use passphrase (%w)", err)
+	}
+	r.SetWorkFactor(workFactor)
+
+	plain, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode vault (%w)", err)
+	}
+
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start encryption (%w)", err)
+	}
+
+	if _, err := w.Write(plain); err != nil {
+		return nil, fmt.Errorf("failed to encrypt vault (%w)", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish encryption (%w)", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Returns the vault decrypted from an age file with the passphrase
+func decryptVault(data []byte, passphrase string) (*vault, error) {
+	id, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use passphrase (%w)", err)
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(data), id)
+	var noMatch *age.NoIdentityMatchError
+	if errors.As(err, &noMatch) {
+		return nil, ErrWrongVaultPassphrase
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt vault (%w)", err)
+	}
+
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt vault (%w)", err)
+	}
+
+	var v vault
+	if err := json.Unmarshal(plain, &v); err != nil {
+		return nil, fmt.Errorf("failed to decode vault (%w)", err)
+	}
+
+	if v.Version != vaultVersion {
+		return nil, fmt.Errorf("vault version (%d) isn't supported, only %d is", v.Version, vaultVersion)
+	}
+
+	return &v, nil
+}
+
+// Returns the path of the vault file
+func getVaultFileFlag(cmd *cobra.Command) (string, error) {
+	path, err := cmd.Flags().GetString(vaultFileKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to get %s flag: %w", vaultFileKey, err)
+	}
+
+	if path != "" {
+		return path, nil
+	}
+
+	data, err := userDataDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(data, "mempass", "vault.age"), nil
+}
+
+// An open vault, along with where it's saved and the passphrase it's
+// encrypted with
+type openVault struct {
+	*vault
+	path       string
+	passphrase string
+}
+
+// Reads the passphrase and decrypts the vault file. When create is set and
+// there's no vault yet, a new passphrase is read, twice when typed, and an
+// empty vault returned.
+func loadVault(cmd *cobra.Command, create bool) (*openVault, error) {
+	path, err := getVaultFileFlag(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if !create {
+			return nil, fmt.Errorf("there's no vault at %s, use vault add to create it", path)
+		}
+
+		passphrase, err := readNewSecret(cmd, "vault passphrase")
+		if err != nil {
+			return nil, err
+		}
+
+		return &openVault{&vault{Version: vaultVersion}, path, string(passphrase)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault: %w", err)
+	}
+
+	passphrase, err := readSecret(cmd, "Vault passphrase: ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault passphrase: %w", err)
+	}
+
+	v, err := decryptVault(data, string(passphrase))
+	if err != nil {
+		return nil, err
+	}
+
+	return &openVault{v, path, string(passphrase)}, nil
+}
+
+// Reads a new secret, asking for it again to confirm it when it's typed at a
+// terminal. The name says what it is in prompts and errors.
+func readNewSecret(cmd *cobra.Command, name string) ([]byte, error) {
+	secret, err := readSecret(cmd, "New "+name+": ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("%s must not be empty", name)
+	}
+
+	if isTerminalInput(cmd) {
+		again, err := readSecret(cmd, "Confirm "+name+": ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+
+		if !bytes.Equal(secret, again) {
+			return nil, fmt.Errorf("%ss don't match", name)
+		}
+	}
+
+	return secret, nil
+}
+
+// Encrypts and saves the vault, replacing its file atomically
+func (v *openVault) save() error {
+	data, err := encryptVault(v.vault, v.passphrase, vaultScryptWorkFactor)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt vault: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(v.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create vault directory: %w", err)
+	}
+
+	if err := writeFileAtomic(v.path, data, 0o600, true); err != nil {
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+
+	return nil
+}
+
+// Returns an error for a label which can't be stored in the vault
+func validateVaultLabel(label string) error {
+	if label == "" || strings.ContainsFunc(label, func(r rune) bool { return r < ' ' || r == 0x7f }) {
+		return fmt.Errorf("label (%q) must not be empty or contain control characters", label)
+	}
+
+	return nil
+}
+
+// Adds the vault file flag to a flag set
+func addVaultFileFlag(flags *pflag.FlagSet) {
+	flags.String(vaultFileKey, "", "vault file to use, default $XDG_DATA_HOME/mempass/vault.age")
+}
+
+func init() {
+	rootCmd.AddCommand(vaultCmd)
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var vaultAddCmd = &cobra.Command{
+	Use:   "add LABEL",
+	Short: "Generate a password and store it in the vault",
+	Long: `Generate a password with the given preset, custom config and flags, and store
+it in the vault under LABEL. The vault is created when it doesn't exist yet,
+with a new passphrase. Use vault get to see the password`,
+	Args: cobra.ExactArgs(1),
+	RunE: runVaultAddCmd,
+}
+
+func runVaultAddCmd(cmd *cobra.Command, args []string) error {
+	label := args[0]
+	if err := validateVaultLabel(label); err != nil {
+		return err
+	}
+
+	pws, err := generatePasswords(cmd, 1)
+	if err != nil {
+		return err
+	}
+
+	v, err := loadVault(cmd, true)
+	if err != nil {
+		return err
+	}
+
+	if err := v.add(label, pws[0], time.Now().UTC()); err != nil {
+		return err
+	}
+
+	if err := v.save(); err != nil {
+		return err
+	}
+
+	cmd.PrintErrf("Added %s to %s\n", label, v.path)
+
+	return nil
+}
+
+// Returns n passwords generated with the command's config, for storing
+// rather than printing
+func generatePasswords(cmd *cobra.Command, n int) ([]string, error) {
+	cfg, err := generateConfig(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate config: %w", err)
+	}
+
+	pws, err := generateInBatches(cmd, cfg, n)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, warning := evaluatePasswords(pws, false); warning != "" {
+		cmd.PrintErrln(warning)
+		cmd.PrintErrln()
+	}
+
+	return pws, nil
+}
+
+func init() {
+	addVaultFileFlag(vaultAddCmd.Flags())
+
+	addConfigFlags(vaultAddCmd.Flags())
+
+	vaultCmd.AddCommand(vaultAddCmd)
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var vaultGetCmd = &cobra.Command{
+	Use:   "get LABEL",
+	Short: "Output a password from the vault",
+	Long: `Output the password stored in the vault under LABEL, printed or in any of the
+ways a generated password can be output`,
+	Args: cobra.ExactArgs(1),
+	RunE: runVaultGetCmd,
+}
+
+func runVaultGetCmd(cmd *cobra.Command, args []string) error {
+	out, err := getPasswordOutput(cmd)
+	if err != nil {
+		return err
+	}
+
+	v, err := loadVault(cmd, false)
+	if err != nil {
+		return err
+	}
+
+	e, err := v.get(args[0])
+	if err != nil {
+		return err
+	}
+
+	return out.write(cmd, []string{e.Password})
+}
+
+func init() {
+	addVaultFileFlag(vaultGetCmd.Flags())
+
+	addPasswordOutputFlags(vaultGetCmd)
+
+	vaultCmd.AddCommand(vaultGetCmd)
+}
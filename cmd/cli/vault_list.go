@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var vaultListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the labels in the vault",
+	Long:  `List the labels in the vault, with when their passwords were added and last rotated`,
+	Args:  cobra.NoArgs,
+	RunE:  runVaultListCmd,
+}
+
+func runVaultListCmd(cmd *cobra.Command, args []string) error {
+	v, err := loadVault(cmd, false)
+	if err != nil {
+		return err
+	}
+
+	return writeVaultList(cmd.OutOrStdout(), v.vault)
+}
+
+// Writes a table of the vault's labels and their dates to w
+func writeVaultList(w io.Writer, v *vault) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "Label\tAdded\tRotated")
+	for _, e := range v.Entries {
+		rotated := "-"
+		if !e.Updated.Equal(e.Created) {
+			rotated = e.Updated.Format(time.DateOnly)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", e.Label, e.Created.Format(time.DateOnly), rotated)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write vault labels: %w", err)
+	}
+
+	return nil
+}
+
+func init() {
+	addVaultFileFlag(vaultListCmd.Flags())
+
+	vaultCmd.AddCommand(vaultListCmd)
+}
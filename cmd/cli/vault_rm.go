@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var vaultRmCmd = &cobra.Command{
+	Use:   "rm LABEL [LABEL...]",
+	Short: "Remove passwords from the vault",
+	Long:  `Remove the passwords stored in the vault under the labels`,
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runVaultRmCmd,
+}
+
+func runVaultRmCmd(cmd *cobra.Command, args []string) error {
+	v, err := loadVault(cmd, false)
+	if err != nil {
+		return err
+	}
+
+	for _, label := range args {
+		if err := v.remove(label); err != nil {
+			return err
+		}
+	}
+
+	if err := v.save(); err != nil {
+		return err
+	}
+
+	cmd.PrintErrf("Removed %d passwords from %s\n", len(args), v.path)
+
+	return nil
+}
+
+func init() {
+	addVaultFileFlag(vaultRmCmd.Flags())
+
+	vaultCmd.AddCommand(vaultRmCmd)
+}
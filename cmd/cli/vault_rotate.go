@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var vaultRotateCmd = &cobra.Command{
+	Use:   "rotate LABEL",
+	Short: "Replace a password in the vault with a new one",
+	Long: `Generate a new password with the given preset, custom config and flags, and
+replace the one stored in the vault under LABEL with it. Use vault get to see
+the new password`,
+	Args: cobra.ExactArgs(1),
+	RunE: runVaultRotateCmd,
+}
+
+func runVaultRotateCmd(cmd *cobra.Command, args []string) error {
+	label := args[0]
+
+	pws, err := generatePasswords(cmd, 1)
+	if err != nil {
+		return err
+	}
+
+	v, err := loadVault(cmd, false)
+	if err != nil {
+		return err
+	}
+
+	if err := v.rotate(label, pws[0], time.Now().UTC()); err != nil {
+		return err
+	}
+
+	if err := v.save(); err != nil {
+		return err
+	}
+
+	cmd.PrintErrf("Rotated the password of %s in %s\n", label, v.path)
+
+	return nil
+}
+
+func init() {
+	addVaultFileFlag(vaultRotateCmd.Flags())
+
+	addConfigFlags(vaultRotateCmd.Flags())
+
+	vaultCmd.AddCommand(vaultRotateCmd)
+}
@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVaultEncryptDecrypt(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	v := &vault{Version: vaultVersion}
+	if err := v.add("github", "one-two", now); err != nil {
+		t.Fatalf("add() error = %v", err)
+	}
+
+	data, err := encryptVault(v, "correct horse", 10)
+	if err != nil {
+		t.Fatalf("encryptVault() error = %v", err)
+	}
+
+	if bytes.Contains(data, []byte("one-two")) || bytes.Contains(data, []byte("github")) {
+		t.Errorf("encryptVault() = %q, want no plaintext", data)
+	}
+
+	got, err := decryptVault(data, "correct horse")
+	if err != nil {
+		t.Fatalf("decryptVault() error = %v", err)
+	}
+
+	e, err := got.get("github")
+	if err != nil || e.Password != "one-two" || !e.Created.Equal(now) {
+		t.Errorf("decryptVault().get() = %+v, %v, want the entry added", e, err)
+	}
+
+	if _, err := decryptVault(data, "wrong horse"); !errors.Is(err, ErrWrongVaultPassphrase) {
+		t.Errorf("decryptVault() with the wrong passphrase error = %v, want %v", err, ErrWrongVaultPassphrase)
+	}
+
+	v.Version = vaultVersion + 1
+	if data, err = encryptVault(v, "correct horse", 10); err != nil {
+		t.Fatalf("encryptVault() error = %v", err)
+	}
+
+	if _, err := decryptVault(data, "correct horse"); err == nil {
+		t.Errorf("decryptVault() of version %d error = nil, want one", v.Version)
+	}
+}
+
+func TestVaultEntries(t *testing.T) {
+	t.Parallel()
+
+	added := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	rotated := added.AddDate(0, 1, 0)
+	v := &vault{Version: vaultVersion}
+
+	for _, label := range []string{"mail", "aws", "github"} {
+		if err := v.add(label, label+"-pw", added); err != nil {
+			t.Fatalf("add(%s) error = %v", label, err)
+		}
+	}
+
+	if err := v.add("aws", "other", added); err == nil {
+		t.Errorf("add(aws) again error = nil, want one")
+	}
+
+	if err := v.rotate("github", "new-pw", rotated); err != nil {
+		t.Errorf("rotate(github) error = %v", err)
+	}
+
+	if err := v.rotate("gitlab", "new-pw", rotated); err == nil {
+		t.Errorf("rotate(gitlab) error = nil, want one")
+	}
+
+	if err := v.remove("mail"); err != nil {
+		t.Errorf("remove(mail) error = %v", err)
+	}
+
+	if err := v.remove("mail"); err == nil {
+		t.Errorf("remove(mail) again error = nil, want one")
+	}
+
+	if e, err := v.get("github"); err != nil || e.Password != "new-pw" || !e.Created.Equal(added) || !e.Updated.Equal(rotated) {
+		t.Errorf("get(github) = %+v, %v, want the rotated entry", e, err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeVaultList(&buf, v); err != nil {
+		t.Fatalf("writeVaultList() error = %v", err)
+	}
+
+	want := strings.Join([]string{
+		"Label   Added       Rotated",
+		"aws     2026-01-02  -",
+		"github  2026-01-02  2026-02-02",
+		"",
+	}, "\n")
+	if buf.String() != want {
+		t.Errorf("writeVaultList() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestValidateVaultLabel(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		label   string
+		wantErr bool
+	}{
+		{"github", false},
+		{"work/aws root", false},
+		{"", true},
+		{"line\nbreak", true},
+	}
+
+	for _, tt := range tests {
+		if err := validateVaultLabel(tt.label); (err != nil) != tt.wantErr {
+			t.Errorf("validateVaultLabel(%q) error = %v, wantErr %t", tt.label, err, tt.wantErr)
+		}
+	}
+}
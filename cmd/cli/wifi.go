@@ -0,0 +1,360 @@
+package cli
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/eljamo/libpass/v8/asset"
+	"github.com/eljamo/libpass/v8/config"
+	"github.com/eljamo/libpass/v8/config/option"
+	"github.com/spf13/cobra"
+)
+
+// Constants for the wifi flag keys
+const (
+	ssidKey    string = "ssid"
+	snippetKey string = "snippet"
+	pskKey     string = "psk"
+)
+
+// WPA-PSK limits from IEEE 802.11
+const (
+	wpaPassphraseMinLength int = 8
+	wpaPassphraseMaxLength int = 63
+	ssidMaxLength          int = 32
+	wpaPSKIterations       int = 4096
+	wpaPSKLength           int = 32
+)
+
+// Constants for the config snippet formats
+const (
+	snippetHostapd        string = "hostapd"
+	snippetWPASupplicant  string = "wpa_supplicant"
+	snippetNetworkManager string = "networkmanager"
+)
+
+var snippetFormats = []string{snippetHostapd, snippetWPASupplicant, snippetNetworkManager}
+
+var wifiCmd = &cobra.Command{
+	Use:   "wifi",
+	Short: "Generate a WPA passphrase for a Wi-Fi network",
+	Long: `Generate a passphrase for a WPA-PSK Wi-Fi network, using the WIFI preset
+unless another config is given. WPA passphrases must be 8 to 63 printable ASCII
+characters, so configs which could generate anything else, such as ones using a
+word list with accented words, are refused.
+
+With --snippet the passphrase is printed as a ready to use hostapd.conf,
+wpa_supplicant.conf or NetworkManager keyfile snippet, and with --psk the
+snippet holds the PSK derived from the passphrase and SSID instead of the
+passphrase itself`,
+	Args: cobra.NoArgs,
+	RunE: runWiFiCmd,
+}
+
+func runWiFiCmd(cmd *cobra.Command, args []string) error {
+	ssid, snippet, withPSK, err := getWiFiFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	clip, err := getClipFlag(cmd)
+	if err != nil {
+		return err
+	}
+
+	ephemeral, err := getEphemeralFlag(cmd)
+	if err != nil {
+		return err
+	}
+
+	qr, err := getQRFlags(cmd)
+	if err != nil {
+		return err
+	}
+	qr.ssid = ssid
+
+	cfg, err := generateConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to generate config: %w", err)
+	}
+	cfg.NumPasswords = 1
+
+	if err := validateWiFiConfig(cfg); err != nil {
+		return err
+	}
+
+	pgs, err := newCmdPasswordGeneratorService(cmd, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create password generator service: %w", err)
+	}
+
+	pws, err := pgs.Generate()
+	if err != nil {
+		return fmt.Errorf("failed to generate passwords: %w", err)
+	}
+
+	pw := pws[0]
+	if err := validateWPAPassphrase(pw); err != nil {
+		return fmt.Errorf("generated an invalid passphrase: %w", err)
+	}
+
+	lines, warning := evaluatePasswords(pws, false)
+	if warning != "" {
+		cmd.PrintErrln(warning)
+		cmd.PrintErrln()
+	}
+
+	if clip {
+		return clipPassword(cmd, pw)
+	}
+
+	var psk []byte
+	if withPSK {
+		if psk, err = wpaPSK(pw, ssid); err != nil {
+			return fmt.Errorf("failed to derive PSK: %w", err)
+		}
+	}
+
+	switch {
+	case snippet != "":
+		lines = strings.Split(wifiSnippet(snippet, ssid, pw, psk), "\n")
+	case withPSK:
+		lines = append(lines, fmt.Sprintf("PSK: %x", psk))
+	}
+
+	lines, err = qr.apply(cmd, pws, lines)
+	if err != nil {
+		return err
+	}
+
+	if ephemeral {
+		return showEphemeral(cmd, lines)
+	}
+
+	for _, l := range lines {
+		fmt.Fprintln(cmd.OutOrStdout(), l)
+	}
+
+	return nil
+}
+
+// Returns the wifi flags, rejecting an SSID which isn't allowed or which
+// would break a config snippet
+func getWiFiFlags(cmd *cobra.Command) (string, string, bool, error) {
+	ssid, err := cmd.Flags().GetString(ssidKey)
+	if err != nil {
+		return "", "", false, fmt.Errorf("failed to get %s flag: %w", ssidKey, err)
+	}
+
+	if ssid == "" || len(ssid) > ssidMaxLength {
+		return "", "", false, fmt.Errorf("%s (%q) must be 1 to %d bytes long", ssidKey, ssid, ssidMaxLength)
+	}
+
+	if strings.ContainsFunc(ssid, func(r rune) bool { return r < ' ' || r == 0x7f }) {
+		return "", "", false, fmt.Errorf("%s (%q) cannot contain control characters", ssidKey, ssid)
+	}
+
+	snippet, err := cmd.Flags().GetString(snippetKey)
+	if err != nil {
+		return "", "", false, fmt.Errorf("failed to get %s flag: %w", snippetKey, err)
+	}
+
+	snippet = strings.ToLower(snippet)
+	if snippet != "" && !slices.Contains(snippetFormats, snippet) {
+		return "", "", false, fmt.Errorf(
+			"%s (%s) must be one of %s",
+			snippetKey,
+			snippet,
+			strings.Join(snippetFormats, ", "),
+		)
+	}
+
+	withPSK, err := cmd.Flags().GetBool(pskKey)
+	if err != nil {
+		return "", "", false, fmt.Errorf("failed to get %s flag: %w", pskKey, err)
+	}
+
+	return ssid, snippet, withPSK, nil
+}
+
+// Returns an error when cfg could generate a passphrase which WPA doesn't
+// allow, so it fails every time rather than on an unlucky pick
+func validateWiFiConfig(cfg *config.Settings) error {
+	if n := minPasswordLength(cfg); n < wpaPassphraseMinLength {
+		return fmt.Errorf(
+			"config can generate %d character passphrases, WPA needs at least %d, add words or padding",
+			n,
+			wpaPassphraseMinLength,
+		)
+	}
+
+	if n := maxPasswordLength(cfg); n > wpaPassphraseMaxLength {
+		return fmt.Errorf(
+			"config can generate %d character passphrases, WPA allows at most %d, use fewer or shorter words, or less padding",
+			n,
+			wpaPassphraseMaxLength,
+		)
+	}
+
+	wl, err := asset.GetFilteredWordList(cfg.WordList, cfg.WordLengthMin, cfg.WordLengthMax)
+	if err != nil {
+		return fmt.Errorf("failed to load word list: %w", err)
+	}
+
+	for _, w := range wl {
+		if !isPrintableASCII(w) {
+			return fmt.Errorf(
+				"%s (%s) has words which WPA passphrases can't contain, such as %q, use an ASCII word list such as %s",
+				option.ConfigKeyWordList,
+				cfg.WordList,
+				w,
+				option.WordListEN,
+			)
+		}
+	}
+
+	characters := slices.Concat(
+		[]string{cfg.SeparatorCharacter, cfg.PaddingCharacter},
+		cfg.SeparatorAlphabet,
+		cfg.SymbolAlphabet,
+	)
+	for _, c := range characters {
+		if !isPrintableASCII(c) {
+			return fmt.Errorf("%q can't be in a WPA passphrase, only printable ASCII characters are allowed", c)
+		}
+	}
+
+	return nil
+}
+
+// Returns an error when pw isn't a WPA passphrase, 8 to 63 printable ASCII
+// characters
+func validateWPAPassphrase(pw string) error {
+	if !isPrintableASCII(pw) {
+		return errors.New("passphrase has characters other than printable ASCII")
+	}
+
+	if len(pw) < wpaPassphraseMinLength || len(pw) > wpaPassphraseMaxLength {
+		return fmt.Errorf(
+			"passphrase is %d characters, it must be %d to %d",
+			len(pw),
+			wpaPassphraseMinLength,
+			wpaPassphraseMaxLength,
+		)
+	}
+
+	return nil
+}
+
+// Returns whether s only has printable ASCII characters, space to tilde
+func isPrintableASCII(s string) bool {
+	return !strings.ContainsFunc(s, func(r rune) bool { return r < ' ' || r > '~' })
+}
+
+// Returns the 256 bit PSK which WPA derives from a passphrase and SSID
+func wpaPSK(passphrase, ssid string) ([]byte, error) {
+	return pbkdf2.Key(sha1.New, passphrase, []byte(ssid), wpaPSKIterations, wpaPSKLength)
+}
+
+// Returns a config snippet for the network in format, with the PSK in place
+// of the passphrase when one is given
+func wifiSnippet(format, ssid, pw string, psk []byte) string {
+	var b strings.Builder
+
+	switch format {
+	case snippetHostapd:
+		b.WriteString("# add to /etc/hostapd/hostapd.conf\n")
+		b.WriteString("ssid=" + ssid + "\n")
+		b.WriteString("wpa=2\nwpa_key_mgmt=WPA-PSK\nrsn_pairwise=CCMP\n")
+		if psk != nil {
+			b.WriteString("wpa_psk=" + hex.EncodeToString(psk))
+		} else {
+			b.WriteString("wpa_passphrase=" + pw)
+		}
+	case snippetWPASupplicant:
+		// quoted values end at the last quote on the line, so quotes inside
+		// them don't need escaping
+		b.WriteString("# add to /etc/wpa_supplicant/wpa_supplicant.conf\n")
+		b.WriteString("network={\n")
+		b.WriteString("\tssid=\"" + ssid + "\"\n")
+		b.WriteString("\tkey_mgmt=WPA-PSK\n")
+		if psk != nil {
+			b.WriteString("\tpsk=" + hex.EncodeToString(psk) + "\n")
+		} else {
+			b.WriteString("\tpsk=\"" + pw + "\"\n")
+		}
+		b.WriteString("}")
+	case snippetNetworkManager:
+		b.WriteString("# save as /etc/NetworkManager/system-connections/" + ssid + ".nmconnection, readable only by root\n")
+		b.WriteString("[connection]\nid=" + escapeKeyfileValue(ssid) + "\ntype=wifi\n\n")
+		b.WriteString("[wifi]\nmode=infrastructure\nssid=" + keyfileSSID(ssid) + "\n\n")
+		b.WriteString("[wifi-security]\nkey-mgmt=wpa-psk\n")
+		if psk != nil {
+			b.WriteString("psk=" + hex.EncodeToString(psk) + "\n\n")
+		} else {
+			b.WriteString("psk=" + escapeKeyfileValue(pw) + "\n\n")
+		}
+		b.WriteString("[ipv4]\nmethod=auto\n\n[ipv6]\nmethod=auto")
+	}
+
+	return b.String()
+}
+
+// Escapes a NetworkManager keyfile value, which is read with GKeyFile rules
+func escapeKeyfileValue(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	if strings.HasPrefix(s, " ") {
+		s = `\s` + s[1:]
+	}
+
+	return s
+}
+
+// Returns the SSID for a NetworkManager keyfile, which reads one with a
+// semicolon in as a list of bytes, so one is written that way
+func keyfileSSID(ssid string) string {
+	if !strings.Contains(ssid, ";") {
+		return escapeKeyfileValue(ssid)
+	}
+
+	var b strings.Builder
+	for _, c := range []byte(ssid) {
+		b.WriteString(strconv.Itoa(int(c)) + ";")
+	}
+
+	return b.String()
+}
+
+func init() {
+	wifiCmd.Flags().String(ssidKey, "", "name of the Wi-Fi network, 1 to 32 bytes")
+	wifiCmd.Flags().String(
+		snippetKey,
+		"",
+		fmt.Sprintf("print a config snippet for the network, valid values: %s", strings.Join(snippetFormats, ", ")),
+	)
+	wifiCmd.Flags().Bool(
+		pskKey,
+		false,
+		"print the PSK derived from the passphrase and SSID, in place of the passphrase in a snippet",
+	)
+	_ = wifiCmd.MarkFlagRequired(ssidKey) // only fails for an unknown flag
+
+	addClipFlags(wifiCmd.Flags())
+	addEphemeralFlags(wifiCmd)
+	addQRFlags(wifiCmd)
+	wifiCmd.MarkFlagsMutuallyExclusive(clipKey, snippetKey)
+
+	addConfigFlags(wifiCmd.Flags())
+	// default to the preset built for WPA's limits
+	preset := wifiCmd.Flags().Lookup(option.ConfigKeyPreset)
+	preset.DefValue = option.PresetWiFi
+	_ = preset.Value.Set(option.PresetWiFi) // any string is a valid value
+
+	rootCmd.AddCommand(wifiCmd)
+}
@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/eljamo/libpass/v8/config/option"
+)
+
+func TestWPAPSK(t *testing.T) {
+	t.Parallel()
+
+	// test vectors from IEEE 802.11i annex H.4
+	tests := []struct {
+		passphrase string
+		ssid       string
+		want       string
+	}{
+		{"password", "IEEE", "f42c6fc52df0ebef9ebb4b90b38a5f902e83fe1b135a70e23aed762e9710a12e"},
+		{"ThisIsAPassword", "ThisIsASSID", "0dc0d6eb90555ed6419756b9a15ec3e3209b63df707dd508d14581f8982721af"},
+	}
+
+	for _, tt := range tests {
+		psk, err := wpaPSK(tt.passphrase, tt.ssid)
+		if err != nil {
+			t.Fatalf("wpaPSK(%q, %q) error = %v", tt.passphrase, tt.ssid, err)
+		}
+
+		if got := hex.EncodeToString(psk); got != tt.want {
+			t.Errorf("wpaPSK(%q, %q) = %s, want %s", tt.passphrase, tt.ssid, got, tt.want)
+		}
+	}
+}
+
+func TestValidateWPAPassphrase(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pw      string
+		wantErr bool
+	}{
+		{"12345678", false},
+		{strings.Repeat("a", 63), false},
+		{"1234567", true},
+		{strings.Repeat("a", 64), true},
+		{"crème-brûlée-42", true},
+		{"tab\there-42", true},
+	}
+
+	for _, tt := range tests {
+		if err := validateWPAPassphrase(tt.pw); (err != nil) != tt.wantErr {
+			t.Errorf("validateWPAPassphrase(%q) error = %v, wantErr %t", tt.pw, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateWiFiConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		layer   map[string]any
+		wantErr bool
+	}{
+		{"wifi preset", nil, false},
+		{"accented words", map[string]any{option.ConfigKeyWordList: option.WordList40k}, true},
+		// its one non-ASCII word is too long for the WIFI preset's word lengths
+		{"filtered words", map[string]any{option.ConfigKeyWordList: option.WordListSunborn}, false},
+		{"long words", map[string]any{
+			option.ConfigKeyWordList:      option.WordListSunborn,
+			option.ConfigKeyNumWords:      3,
+			option.ConfigKeyWordLengthMax: 14,
+		}, true},
+		{"too long", map[string]any{option.ConfigKeyPadToLength: 70}, true},
+		{"non-ascii separator", map[string]any{option.ConfigKeySeparatorCharacter: "§"}, true},
+	}
+
+	for _, tt := range tests {
+		cfg, err := newConfig(option.PresetWiFi, tt.layer)
+		if err != nil {
+			t.Fatalf("%s: newConfig() error = %v", tt.name, err)
+		}
+
+		if err := validateWiFiConfig(cfg); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validateWiFiConfig() error = %v, wantErr %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWiFiSnippet(t *testing.T) {
+	t.Parallel()
+
+	psk := []byte{0xf4, 0x2c}
+	tests := []struct {
+		format string
+		psk    []byte
+		want   []string
+	}{
+		{snippetHostapd, nil, []string{"ssid=Home\n", "wpa_passphrase=pass word\\1"}},
+		{snippetHostapd, psk, []string{"wpa_psk=f42c"}},
+		{snippetWPASupplicant, nil, []string{"\tssid=\"Home\"\n", "\tpsk=\"pass word\\1\"\n"}},
+		{snippetWPASupplicant, psk, []string{"\tpsk=f42c\n"}},
+		{snippetNetworkManager, nil, []string{"id=Home\n", "ssid=Home\n", "psk=pass word\\\\1\n"}},
+		{snippetNetworkManager, psk, []string{"psk=f42c\n"}},
+	}
+
+	for _, tt := range tests {
+		got := wifiSnippet(tt.format, "Home", `pass word\1`, tt.psk)
+		for _, w := range tt.want {
+			if !strings.Contains(got, w) {
+				t.Errorf("wifiSnippet(%s, psk %t) = %q, want it to contain %q", tt.format, tt.psk != nil, got, w)
+			}
+		}
+
+		if tt.psk != nil && strings.Contains(got, "pass word") {
+			t.Errorf("wifiSnippet(%s) = %q, want no passphrase with a PSK", tt.format, got)
+		}
+	}
+}
+
+func TestKeyfileSSID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		ssid string
+		want string
+	}{
+		{"Home", "Home"},
+		{` \x`, `\s\\x`},
+		{"a;b", "97;59;98;"},
+	}
+
+	for _, tt := range tests {
+		if got := keyfileSSID(tt.ssid); got != tt.want {
+			t.Errorf("keyfileSSID(%q) = %q, want %q", tt.ssid, got, tt.want)
+		}
+	}
+}